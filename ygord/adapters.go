@@ -0,0 +1,164 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+// ChatOutboxSize is the number of messages an adapter can have waiting to be
+// sent, further messages are dropped.
+const ChatOutboxSize = 64
+
+// ChatAdapter is the interface used by all the chat backends (IRC,
+// Mattermost, etc.).  An adapter is responsible for feeding InputMessages to
+// the server's InputQueue and for delivering the OutputMessages addressed to
+// it.
+type ChatAdapter interface {
+	// Name returns the unique name of this adapter.  InputMessages and
+	// OutputMessages refer to their adapter using this name.
+	Name() string
+
+	// Start is called once when ygord starts.  Adapters that are not
+	// configured should return without error and do nothing.
+	Start(*Server) error
+
+	// Stop is called when ygord shuts down.
+	Stop() error
+
+	// Send delivers a single message to the chat system.
+	Send(*OutputMessage) error
+}
+
+// RegisterChatAdapter adds a chat adapter to our global registry.  There could
+// be only one adapter registered for each name.
+func (srv *Server) RegisterChatAdapter(adapter ChatAdapter) {
+	srv.ChatAdapters[adapter.Name()] = adapter
+}
+
+// GetChatAdapter returns a registered chat adapter or nil.
+func (srv *Server) GetChatAdapter(name string) ChatAdapter {
+	if adapter, ok := srv.ChatAdapters[name]; ok {
+		return adapter
+	}
+
+	return nil
+}
+
+// StartChatAdapters starts all the registered chat adapters.
+func (srv *Server) StartChatAdapters() error {
+	for name, adapter := range srv.ChatAdapters {
+		log.Printf("starting chat adapter: %s", name)
+		err := adapter.Start(srv)
+		if err != nil {
+			return errors.New(name + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// StopChatAdapters stops all the registered chat adapters, errors are logged
+// but do not prevent other adapters from stopping.
+func (srv *Server) StopChatAdapters() {
+	for name, adapter := range srv.ChatAdapters {
+		err := adapter.Stop()
+		if err != nil {
+			log.Printf("failed to stop chat adapter %s: %s", name,
+				err.Error())
+		}
+	}
+}
+
+// SendToChatAdapter delivers an OutputMessage to the adapter it is addressed
+// to.  Messages without adapter (e.g. generated by the screensaver) have no
+// chat destination and are silently dropped.
+func (srv *Server) SendToChatAdapter(msg *OutputMessage) {
+	if msg.Adapter == "" {
+		return
+	}
+
+	adapter := srv.GetChatAdapter(msg.Adapter)
+	if adapter == nil {
+		// Failures to reach the admin channel are not forwarded there.
+		logf := srv.Errorf
+		if srv.IsAdminChannel(msg) {
+			logf = log.Printf
+		}
+		logf("no chat adapter named '%s'", msg.Adapter)
+		return
+	}
+
+	err := adapter.Send(msg)
+	if err != nil {
+		srv.logSendError(msg, err)
+	}
+}
+
+// logSendError reports a message which could not be delivered.  Failures to
+// reach the admin channel are not forwarded there.
+func (srv *Server) logSendError(msg *OutputMessage, err error) {
+	logf := srv.Errorf
+	if srv.IsAdminChannel(msg) {
+		logf = log.Printf
+	}
+
+	logf("%s: failed to send message to %s: %s", msg.Adapter,
+		msg.Channel, err.Error())
+}
+
+// chatOutbox delivers the messages of an adapter from a goroutine of its own
+// so that a slow or unreachable chat API does not hold the main loop.  The
+// messages are sent in order and the failures are logged.
+type chatOutbox struct {
+	queue   chan *OutputMessage
+	pending sync.WaitGroup
+}
+
+// startOutbox starts delivering the pushed messages with the given function.
+func (outbox *chatOutbox) startOutbox(srv *Server, send func(*OutputMessage) error) {
+	queue := make(chan *OutputMessage, ChatOutboxSize)
+	outbox.queue = queue
+
+	go func() {
+		for msg := range queue {
+			err := send(msg)
+			if err != nil {
+				srv.logSendError(msg, err)
+			}
+			outbox.pending.Done()
+		}
+	}()
+}
+
+// stopOutbox stops the delivery once the pending messages are sent.
+func (outbox *chatOutbox) stopOutbox() {
+	if outbox.queue != nil {
+		close(outbox.queue)
+		outbox.queue = nil
+	}
+}
+
+// push queues the message for delivery, without ever waiting.
+func (outbox *chatOutbox) push(msg *OutputMessage) error {
+	if outbox.queue == nil {
+		return errors.New("not connected")
+	}
+
+	outbox.pending.Add(1)
+	select {
+	case outbox.queue <- msg:
+		return nil
+	default:
+		outbox.pending.Done()
+		return errors.New("too many messages waiting, message dropped")
+	}
+}
+
+// waitOutbox waits until all the pushed messages are delivered.
+func (outbox *chatOutbox) waitOutbox() {
+	outbox.pending.Wait()
+}
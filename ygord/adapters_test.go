@@ -0,0 +1,155 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatAdapterReply(t *testing.T) {
+	srv := CreateTestServer()
+	adapter := srv.GetTestAdapter()
+
+	msg := &InputMessage{Adapter: "test", ReplyTo: "#test"}
+	srv.Reply(msg, "hello")
+	srv.ReplyAction(msg, "waves")
+	srv.DispatchOutputQueue()
+
+	msgs := adapter.Flush()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "#test", msgs[0].Channel)
+		assert.Equal(t, OutputMsgTypePrivMsg, msgs[0].Type)
+		assert.Equal(t, "hello", msgs[0].Body)
+		assert.Equal(t, "#test", msgs[1].Channel)
+		assert.Equal(t, OutputMsgTypeAction, msgs[1].Type)
+		assert.Equal(t, "waves", msgs[1].Body)
+	}
+}
+
+func TestChatAdapterUnknown(t *testing.T) {
+	srv := CreateTestServer()
+	adapter := srv.GetTestAdapter()
+
+	srv.Reply(&InputMessage{Adapter: "irc", ReplyTo: "#test"}, "hello")
+	srv.Reply(&InputMessage{ReplyTo: "#test"}, "hello")
+	srv.DispatchOutputQueue()
+
+	assert.Empty(t, adapter.Flush())
+}
+
+func TestChatOutbox(t *testing.T) {
+	srv := CreateTestServer()
+	srv.Config.AdminChannel = "#admin"
+	srv.Config.AdminAdapter = "test"
+
+	release := make(chan bool)
+	var sent []string
+	outbox := &chatOutbox{}
+	outbox.startOutbox(srv, func(msg *OutputMessage) error {
+		<-release
+		sent = append(sent, msg.Body)
+		if msg.Body == "0" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	// Pushing never waits for a slow chat API, messages are dropped once
+	// too many of them are waiting.
+	var err error
+	for i := 0; err == nil; i++ {
+		err = outbox.push(&OutputMessage{
+			Adapter: "test",
+			Channel: "#test",
+			Body:    strconv.Itoa(i),
+		})
+	}
+	assert.EqualError(t, err, "too many messages waiting, message dropped")
+
+	close(release)
+	outbox.waitOutbox()
+	if assert.True(t, len(sent) >= ChatOutboxSize) {
+		assert.Equal(t, "0", sent[0])
+		assert.Equal(t, "1", sent[1])
+	}
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#admin", msgs[0].Channel)
+		assert.Equal(t, "test: failed to send message to #test: boom",
+			msgs[0].Body)
+	}
+
+	outbox.stopOutbox()
+	assert.EqualError(t, outbox.push(&OutputMessage{}), "not connected")
+}
+
+func TestChatAdapterCommandNotFound(t *testing.T) {
+	srv := CreateTestServer()
+	adapter := srv.GetTestAdapter()
+
+	srv.IRCMessageHandler(&InputMessage{
+		Adapter: "test",
+		ReplyTo: "#test",
+		Command: "wat",
+	})
+	srv.DispatchOutputQueue()
+
+	msgs := adapter.Flush()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#test", msgs[0].Channel)
+		assert.Equal(t, OutputMsgTypeError, msgs[0].Type)
+		assert.Equal(t, "command not found: wat", msgs[0].Body)
+	}
+}
+
+func TestOutputMessageRendering(t *testing.T) {
+	card := Attachment{Fallback: "now playing: freshpots.mp3"}
+
+	for _, test := range []struct {
+		msg       OutputMessage
+		plainText string
+		markdown  string
+	}{
+		{
+			OutputMessage{Type: OutputMsgTypePrivMsg, Body: "hello"},
+			"hello",
+			"hello",
+		},
+		{
+			OutputMessage{Type: OutputMsgTypeAction, Body: "waves"},
+			"waves",
+			"*waves*",
+		},
+		{
+			OutputMessage{Type: OutputMsgTypeError, Body: "unknown alias"},
+			"error: unknown alias",
+			"**error:** unknown alias",
+		},
+		{
+			OutputMessage{Type: OutputMsgTypeList, Items: []string{"a", "b"}},
+			"a, b",
+			"`a`, `b`",
+		},
+		{
+			OutputMessage{Type: OutputMsgTypeList, Body: "aliases",
+				Items: []string{"a"}},
+			"aliases: a",
+			"**aliases:** `a`",
+		},
+		{
+			OutputMessage{Type: OutputMsgTypeMediaCard, Body: "ok",
+				Attachments: []Attachment{card}},
+			"ok\nnow playing: freshpots.mp3",
+			"ok\nnow playing: freshpots.mp3",
+		},
+	} {
+		assert.Equal(t, test.plainText, test.msg.PlainText())
+		assert.Equal(t, test.markdown, test.msg.Markdown())
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the logging sink forwarding the warnings and errors to
+// the AdminChannel, in addition to the standard log.  Messages below the
+// configured severity are only logged and the number of messages forwarded
+// is limited to avoid flooding the channel.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity is the importance of a log message.
+type Severity int
+
+// Severities of the log messages, by increasing importance.
+const (
+	SeverityInfo    Severity = iota
+	SeverityWarning Severity = iota
+	SeverityError   Severity = iota
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+// String returns the name of the severity (e.g. "warning").
+func (severity Severity) String() string {
+	if severity < 0 || int(severity) >= len(severityNames) {
+		return fmt.Sprintf("severity%d", severity)
+	}
+	return severityNames[severity]
+}
+
+// ParseSeverity returns the Severity with the given name.
+func ParseSeverity(name string) (Severity, error) {
+	for i, severityName := range severityNames {
+		if severityName == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityInfo, errors.New("unknown severity '" + name +
+		"' (expected " + strings.Join(severityNames, ", ") + ")")
+}
+
+// AdminLog decides which log messages are forwarded to the AdminChannel: only
+// the ones at least as important as MinSeverity, at most Quota per Period.
+type AdminLog struct {
+	sync.Mutex
+
+	MinSeverity Severity
+	Quota       int
+	Period      time.Duration
+
+	// Times the recent messages were forwarded, and number of messages
+	// dropped since the last one.
+	sent    []time.Time
+	dropped int
+}
+
+// NewAdminLog creates an AdminLog from its configuration, using the defaults
+// ("warning", 5 per minute) for empty values.
+func NewAdminLog(level string, quota int, period string) (*AdminLog, error) {
+	adminLog := &AdminLog{
+		MinSeverity: SeverityWarning,
+		Quota:       5,
+		Period:      time.Minute,
+	}
+
+	var err error
+
+	if level != "" {
+		adminLog.MinSeverity, err = ParseSeverity(level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if quota < 0 {
+		return nil, errors.New("quota must be positive")
+	} else if quota > 0 {
+		adminLog.Quota = quota
+	}
+
+	if period != "" {
+		adminLog.Period, err = ParseDuration(period)
+		if err != nil {
+			return nil, errors.New("period: " + err.Error())
+		}
+	}
+
+	return adminLog, nil
+}
+
+// Allow returns true if a message of the given severity can be forwarded
+// now, and how many messages were dropped since the last forwarded one.
+func (adminLog *AdminLog) Allow(now time.Time, severity Severity) (bool, int) {
+	adminLog.Lock()
+	defer adminLog.Unlock()
+
+	if severity < adminLog.MinSeverity {
+		return false, 0
+	}
+
+	var sent []time.Time
+	for _, t := range adminLog.sent {
+		if now.Sub(t) < adminLog.Period {
+			sent = append(sent, t)
+		}
+	}
+	adminLog.sent = sent
+
+	if len(adminLog.sent) >= adminLog.Quota {
+		adminLog.dropped++
+		return false, 0
+	}
+
+	adminLog.sent = append(adminLog.sent, now)
+	dropped := adminLog.dropped
+	adminLog.dropped = 0
+
+	return true, dropped
+}
+
+// Logf logs a message and forwards it to the AdminChannel if its severity
+// and the rate limit allow it.
+func (srv *Server) Logf(severity Severity, format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	log.Printf("%s: %s", severity, text)
+
+	if srv.Config.AdminChannel == "" {
+		return
+	}
+
+	ok, dropped := srv.AdminLog.Allow(time.Now(), severity)
+	if !ok {
+		return
+	}
+
+	msg := &OutputMessage{
+		Type:    OutputMsgTypePrivMsg,
+		Adapter: srv.Config.AdminAdapter,
+		Channel: srv.Config.AdminChannel,
+		Body:    severity.String() + ": " + text,
+	}
+	if severity == SeverityError {
+		msg.Type = OutputMsgTypeError
+		msg.Body = text
+	}
+	if dropped > 0 {
+		msg.Body += fmt.Sprintf(" (%d more messages dropped)", dropped)
+	}
+
+	// This could be called from the main loop, never wait for the queue.
+	select {
+	case srv.OutputQueue <- msg:
+	default:
+		log.Printf("output queue full, not forwarding to %s",
+			srv.Config.AdminChannel)
+	}
+}
+
+// Warningf logs a warning, see Logf.
+func (srv *Server) Warningf(format string, args ...interface{}) {
+	srv.Logf(SeverityWarning, format, args...)
+}
+
+// Errorf logs an error, see Logf.
+func (srv *Server) Errorf(format string, args ...interface{}) {
+	srv.Logf(SeverityError, format, args...)
+}
+
+// IsAdminChannel returns true if the message is addressed to the
+// AdminChannel through the AdminAdapter.
+func (srv *Server) IsAdminChannel(msg *OutputMessage) bool {
+	return srv.Config.AdminChannel != "" &&
+		msg.Channel == srv.Config.AdminChannel &&
+		msg.Adapter == srv.Config.AdminAdapter
+}
@@ -0,0 +1,109 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAdminLog(t *testing.T) {
+	adminLog, err := NewAdminLog("", 0, "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, SeverityWarning, adminLog.MinSeverity)
+		assert.Equal(t, 5, adminLog.Quota)
+		assert.Equal(t, time.Minute, adminLog.Period)
+	}
+
+	adminLog, err = NewAdminLog("error", 2, "30s")
+	if assert.Nil(t, err) {
+		assert.Equal(t, SeverityError, adminLog.MinSeverity)
+		assert.Equal(t, 2, adminLog.Quota)
+		assert.Equal(t, 30*time.Second, adminLog.Period)
+	}
+
+	_, err = NewAdminLog("debug", 0, "")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "unknown severity 'debug' (expected info, warning, error)", err.Error())
+	}
+
+	_, err = NewAdminLog("", -1, "")
+	assert.NotNil(t, err)
+}
+
+func TestAdminLogAllow(t *testing.T) {
+	adminLog, _ := NewAdminLog("warning", 2, "1m")
+
+	ok, _ := adminLog.Allow(fakeNow, SeverityInfo)
+	assert.False(t, ok)
+
+	ok, dropped := adminLog.Allow(fakeNow, SeverityWarning)
+	assert.True(t, ok)
+	assert.Equal(t, 0, dropped)
+	ok, _ = adminLog.Allow(fakeNow.Add(time.Second), SeverityError)
+	assert.True(t, ok)
+
+	ok, _ = adminLog.Allow(fakeNow.Add(2*time.Second), SeverityError)
+	assert.False(t, ok)
+	ok, _ = adminLog.Allow(fakeNow.Add(3*time.Second), SeverityWarning)
+	assert.False(t, ok)
+
+	ok, dropped = adminLog.Allow(fakeNow.Add(time.Minute), SeverityWarning)
+	assert.True(t, ok)
+	assert.Equal(t, 2, dropped)
+}
+
+func TestServerLogf(t *testing.T) {
+	srv := CreateTestServer()
+	adapter := srv.GetTestAdapter()
+
+	// Nothing is forwarded without admin channel.
+	srv.Errorf("boom")
+	assert.Empty(t, srv.FlushOutputQueue())
+
+	srv.Config.AdminChannel = "#admin"
+	srv.Config.AdminAdapter = "test"
+	srv.AdminLog.Quota = 2
+
+	srv.Logf(SeverityInfo, "hello")
+	srv.Warningf("client %s is dead", "dummy")
+	srv.Errorf("boom")
+	srv.Errorf("boom again")
+	srv.DispatchOutputQueue()
+
+	msgs := adapter.Flush()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "#admin", msgs[0].Channel)
+		assert.Equal(t, OutputMsgTypePrivMsg, msgs[0].Type)
+		assert.Equal(t, "warning: client dummy is dead", msgs[0].Body)
+		assert.Equal(t, "#admin", msgs[1].Channel)
+		assert.Equal(t, OutputMsgTypeError, msgs[1].Type)
+		assert.Equal(t, "boom", msgs[1].Body)
+	}
+}
+
+func TestServerLogfSendFailure(t *testing.T) {
+	srv := CreateTestServer()
+	adapter := srv.GetTestAdapter()
+	srv.Config.AdminChannel = "#admin"
+	srv.Config.AdminAdapter = "test"
+
+	srv.SendToChatAdapter(&OutputMessage{Adapter: "nope", Channel: "#test", Body: "hi"})
+	srv.DispatchOutputQueue()
+
+	msgs := adapter.Flush()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#admin", msgs[0].Channel)
+		assert.Equal(t, "error: no chat adapter named 'nope'", msgs[0].PlainText())
+	}
+
+	// Failing to reach the admin channel does not loop.
+	srv.Config.AdminAdapter = "nope"
+	srv.Errorf("boom")
+	srv.DispatchOutputQueue()
+	assert.Empty(t, srv.FlushOutputQueue())
+	assert.Empty(t, adapter.Flush())
+}
@@ -4,6 +4,7 @@
 package alias
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -25,7 +26,7 @@ func TestAliasResolve(t *testing.T) {
 
 	a.Add("noodly", "image http://i.imgur.com/uVIqN.jpg", "fsm", time.Now())
 	a.Save()
-	line, err := a.Resolve("noodly appendage")
+	line, err := a.Resolve("noodly appendage", 0)
 	if err != nil {
 		t.Error(err)
 	}
@@ -54,7 +55,7 @@ func TestAliasResolveRecursive(t *testing.T) {
 	a.Add("image", "web", "fsm", time.Now())
 	a.Add("noodly", "image http://i.imgur.com/uVIqN.jpg", "fsm", time.Now())
 	a.Save()
-	line, err := a.Resolve("noodly appendage")
+	line, err := a.Resolve("noodly appendage", 0)
 	if err != nil {
 		t.Error(err)
 	}
@@ -66,3 +67,28 @@ func TestAliasResolveRecursive(t *testing.T) {
 		t.Errorf("output does not match: %s != %s", expected, line)
 	}
 }
+
+func TestAliasConcurrentAccess(t *testing.T) {
+	a, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			a.All()
+			a.Find("alias")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("alias%d", i)
+		a.Add(name, "say hi", "fsm", time.Now())
+		a.Get(name)
+		a.Delete(name)
+	}
+
+	<-done
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/truveris/ygor/ygord/lexer"
@@ -36,8 +37,10 @@ const (
 )
 
 // File wraps your alias file, it abstracts the serialization of aliases and
-// keeps an in-memory cache to avoid frequent reads.
+// keeps an in-memory cache to avoid frequent reads.  It is safe for
+// concurrent use, the alias checker reads it from its own goroutine.
 type File struct {
+	sync.Mutex
 	path    string
 	cache   map[string]*Alias
 	lastMod time.Time
@@ -77,6 +80,9 @@ func (file *File) needsReload() bool {
 
 // Get returns the alias given its name.  Returns nil if not found.
 func (file *File) Get(name string) *Alias {
+	file.Lock()
+	defer file.Unlock()
+
 	if file.needsReload() {
 		file.reload()
 	}
@@ -92,6 +98,12 @@ func (file *File) Get(name string) *Alias {
 
 // Names returns a sorted list of all the alias names.
 func (file *File) Names() []string {
+	file.Lock()
+	defer file.Unlock()
+	return file.names()
+}
+
+func (file *File) names() []string {
 	idx := 0
 	names := make([]string, len(file.cache))
 	for name := range file.cache {
@@ -105,6 +117,12 @@ func (file *File) Names() []string {
 // Add creates a new alias in the in-memory cache.  It will be saved
 // permanently once Save is called.
 func (file *File) Add(name, value, author string, time time.Time) {
+	file.Lock()
+	defer file.Unlock()
+	file.add(name, value, author, time)
+}
+
+func (file *File) add(name, value, author string, time time.Time) {
 	alias := &Alias{}
 	alias.Name = name
 	alias.Value = value
@@ -116,11 +134,16 @@ func (file *File) Add(name, value, author string, time time.Time) {
 // Delete removes an alias by name from the local cache. It will not be saved
 // permanently until Save is called.
 func (file *File) Delete(name string) {
+	file.Lock()
+	defer file.Unlock()
 	delete(file.cache, name)
 }
 
 // Save all the aliases to disk.
 func (file *File) Save() error {
+	file.Lock()
+	defer file.Unlock()
+
 	if file.path == ":memory:" {
 		return nil
 	}
@@ -189,7 +212,7 @@ func (file *File) reload() error {
 			date = time.Now()
 		}
 
-		file.Add(tokens[0], tokens[1], tokens[2], date)
+		file.add(tokens[0], tokens[1], tokens[2], date)
 	}
 
 	return nil
@@ -230,6 +253,9 @@ func (file *File) Resolve(line string, depth int) (string, error) {
 func (file *File) All() ([]Alias, error) {
 	var aliases []Alias
 
+	file.Lock()
+	defer file.Unlock()
+
 	if file.needsReload() {
 		err := file.reload()
 		if err != nil {
@@ -237,7 +263,7 @@ func (file *File) All() ([]Alias, error) {
 		}
 	}
 
-	names := file.Names()
+	names := file.names()
 
 	for _, name := range names {
 		alias := *file.cache[name]
@@ -0,0 +1,206 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the alias checker, a background job going through all
+// the aliases to find the media URLs which stopped working (e.g. 404), so
+// that they can be fixed before someone triggers them on the screens.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/truveris/ygor/ygord/alias"
+	"github.com/truveris/ygor/ygord/lexer"
+)
+
+var (
+	// Formats accepted by the commands whose URLs are checked.
+	aliasCheckerFormats = map[string][]string{
+		"image": imageFormats,
+		"play":  playFormats,
+	}
+)
+
+// BrokenAlias describes an alias referring to a media URL which failed to
+// resolve.
+type BrokenAlias struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value"`
+	URL     string    `json:"url"`
+	Error   string    `json:"error"`
+	Checked time.Time `json:"checked"`
+}
+
+// AliasChecker checks the media URLs of all the aliases every Interval.
+type AliasChecker struct {
+	sync.Mutex
+
+	Interval time.Duration
+
+	// Result of the last check, by alias name.
+	broken  map[string]BrokenAlias
+	checked time.Time
+}
+
+// NewAliasChecker creates an AliasChecker from its configuration, using the
+// default ("24h") for an empty interval.  A zero interval disables the
+// periodic checks.
+func NewAliasChecker(interval string) (*AliasChecker, error) {
+	checker := &AliasChecker{
+		Interval: 24 * time.Hour,
+		broken:   make(map[string]BrokenAlias),
+	}
+
+	if interval != "" {
+		var err error
+		checker.Interval, err = ParseDuration(interval)
+		if err != nil {
+			return nil, errors.New("interval: " + err.Error())
+		}
+	}
+
+	return checker, nil
+}
+
+// Start runs the checks in the background every Interval, starting now.
+func (checker *AliasChecker) Start(srv *Server) {
+	if checker.Interval == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			checker.Check(srv)
+			time.Sleep(checker.Interval)
+		}
+	}()
+}
+
+// aliasURLs returns the media URLs found in the play and image sentences of
+// the alias value, with the formats accepted for each of them.
+func aliasURLs(value string) (map[string][]string, error) {
+	sentences, err := lexer.Split(value)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string][]string)
+	for _, sentence := range sentences {
+		formats, ok := aliasCheckerFormats[sentence[0]]
+		if !ok {
+			continue
+		}
+
+		for _, word := range sentence[1:] {
+			uri, err := url.ParseRequestURI(word)
+			if err != nil || uri.Scheme == "" {
+				continue
+			}
+			urls[word] = formats
+		}
+	}
+
+	return urls, nil
+}
+
+// probeMedia resolves the URL afresh, returning the reason it cannot be
+// played if any.  The cached resolution is only refreshed if this one
+// succeeds, a temporary failure does not break the URL for everyone.
+func probeMedia(srv *Server, link string, formats []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		srv.MediaQueue.Timeout)
+	defer cancel()
+
+	media := &Media{srv: srv, ctx: ctx, acceptableFormats: formats,
+		refresh: true}
+	err := media.SetSrc(link)
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("timed out after " +
+			shortDuration(srv.MediaQueue.Timeout))
+	}
+
+	return err
+}
+
+// Check goes through all the aliases and records the broken ones.  Each URL
+// is only probed once per set of accepted formats, even if it is used by
+// several aliases.
+func (checker *AliasChecker) Check(srv *Server) error {
+	aliases, err := srv.Aliases.All()
+	if err != nil {
+		srv.Warningf("alias checker: %s", err.Error())
+		return err
+	}
+
+	broken := make(map[string]BrokenAlias)
+	results := make(map[string]error)
+
+	for _, a := range aliases {
+		urls, err := aliasURLs(a.Value)
+		if err != nil {
+			continue
+		}
+
+		for link, formats := range urls {
+			key := link + " " + strings.Join(formats, ",")
+			perr, ok := results[key]
+			if !ok {
+				perr = probeMedia(srv, link, formats)
+				results[key] = perr
+			}
+			if perr != nil {
+				broken[a.Name] = BrokenAlias{
+					Name:    a.Name,
+					Value:   a.Value,
+					URL:     link,
+					Error:   perr.Error(),
+					Checked: time.Now().UTC(),
+				}
+			}
+		}
+	}
+
+	checker.Lock()
+	checker.broken = broken
+	checker.checked = time.Now()
+	checker.Unlock()
+
+	if len(broken) > 0 {
+		srv.Logf(SeverityInfo, "alias checker: %d broken aliases out "+
+			"of %d", len(broken), len(aliases))
+	}
+
+	return nil
+}
+
+// Broken returns the broken aliases found by the last check, sorted by name,
+// and the time of this check (zero if none ran yet).  The aliases modified
+// or deleted since then are left out.
+func (checker *AliasChecker) Broken(aliases *alias.File) ([]BrokenAlias, time.Time) {
+	checker.Lock()
+	defer checker.Unlock()
+
+	list := []BrokenAlias{}
+	for _, b := range checker.broken {
+		if a := aliases.Get(b.Name); a != nil && a.Value == b.Value {
+			list = append(list, b)
+		}
+	}
+	sort.Sort(brokenAliasesByName(list))
+
+	return list, checker.checked
+}
+
+type brokenAliasesByName []BrokenAlias
+
+func (list brokenAliasesByName) Len() int           { return len(list) }
+func (list brokenAliasesByName) Swap(i, j int)      { list[i], list[j] = list[j], list[i] }
+func (list brokenAliasesByName) Less(i, j int) bool { return list[i].Name < list[j].Name }
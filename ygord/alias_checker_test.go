@@ -0,0 +1,102 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasURLs(t *testing.T) {
+	urls, err := aliasURLs("play http://example.com/a.mp3 1:23-2:05; " +
+		"say http://example.com/b.mp3; image lib:cat; play not-a-url")
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string][]string{
+			"http://example.com/a.mp3": playFormats,
+			"lib:cat":                  imageFormats,
+		}, urls)
+	}
+}
+
+func TestAliasChecker(t *testing.T) {
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Method+" "+r.URL.Path]++
+		switch r.URL.Path {
+		case "/cat.gif":
+			w.Header().Set("Content-Type", "image/gif")
+		case "/song.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	srv := CreateTestServer()
+	(&AliasModule{}).Init(srv)
+	now := time.Now()
+	srv.Aliases.Add("cat", "image "+ts.URL+"/cat.gif", "alice", now)
+	srv.Aliases.Add("gone", "play "+ts.URL+"/gone.mp3; say oh no", "alice", now)
+	srv.Aliases.Add("gone2", "play "+ts.URL+"/gone.mp3", "bob", now)
+	srv.Aliases.Add("song", "image "+ts.URL+"/song.mp3", "bob", now)
+	srv.Aliases.Add("text", "say "+ts.URL+"/gone.mp3", "bob", now)
+	// The same URL is fine for play, even though image rejects it.
+	srv.Aliases.Add("tune", "play "+ts.URL+"/song.mp3", "bob", now)
+
+	srv.RunCommand("broken-aliases", &InputMessage{ReplyTo: "#test"})
+	if msgs := srv.FlushOutputQueue(); assert.Len(t, msgs, 1) {
+		assert.Equal(t, "the aliases have not been checked yet", msgs[0].Body)
+	}
+
+	// A stale cache entry does not hide the broken URL.
+	srv.MediaCache.Set(now, &MediaCacheEntry{
+		URL:     ts.URL + "/gone.mp3",
+		Src:     ts.URL + "/gone.mp3",
+		Format:  "audio",
+		Expires: now.Add(time.Hour),
+	})
+
+	assert.Nil(t, srv.AliasChecker.Check(srv))
+	assert.Equal(t, 1, requests["HEAD /gone.mp3"])
+
+	// The failures are not written to the cache, the successes are.
+	entry, ok := srv.MediaCache.Peek(ts.URL + "/gone.mp3")
+	if assert.True(t, ok) {
+		assert.Equal(t, "audio", entry.Format)
+		assert.Empty(t, entry.Error)
+	}
+	entry, ok = srv.MediaCache.Peek(ts.URL + "/cat.gif")
+	if assert.True(t, ok) {
+		assert.Equal(t, "img", entry.Format)
+	}
+
+	broken, checked := srv.AliasChecker.Broken(srv.Aliases)
+	assert.False(t, checked.IsZero())
+	if assert.Len(t, broken, 3) {
+		assert.Equal(t, "gone", broken[0].Name)
+		assert.Equal(t, ts.URL+"/gone.mp3", broken[0].URL)
+		assert.Equal(t, "response status code is 404", broken[0].Error)
+		assert.Equal(t, "gone2", broken[1].Name)
+		assert.Equal(t, "song", broken[2].Name)
+		assert.Equal(t, "content-type (audio/mpeg) not supported by "+
+			"this command", broken[2].Error)
+	}
+
+	// Fixed aliases are no longer reported.
+	srv.Aliases.Add("gone2", "play "+ts.URL+"/song.mp3", "bob", now)
+
+	srv.RunCommand("broken-aliases", &InputMessage{ReplyTo: "#test"})
+	if msgs := srv.FlushOutputQueue(); assert.Len(t, msgs, 1) {
+		assert.Equal(t, "2 broken aliases (checked 0s ago)", msgs[0].Body)
+		assert.Equal(t, []string{
+			"gone (response status code is 404)",
+			"song (content-type (audio/mpeg) not supported by this command)",
+		}, msgs[0].Items)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// api_client.go contains the helper used by the chat adapters to talk to the
+// JSON APIs of their chat systems.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// apiRequest sends a single request to a JSON API with the given
+// Authorization header.  If input is not nil it is sent as JSON body, if
+// output is not nil the JSON response is decoded into it.  Any non-2xx
+// response is an error.
+func apiRequest(client *http.Client, method, endpoint, authorization string, input, output interface{}) error {
+	var body io.Reader
+	if input != nil {
+		buf, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Read entire body to completion to re-use keep-alive
+		// connections.
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	if output == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(output)
+}
@@ -0,0 +1,392 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the declarative argument parsing used by all the
+// commands.  Each command defines its flags and positional arguments, they
+// are validated and converted before the command is executed.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArgType defines how a positional argument is validated and converted.
+type ArgType int
+
+// Types of positional arguments.  Strings are accepted as-is, URLs must be
+// absolute, durations are either a number of seconds (e.g. "90" or "1.5"), a
+// timestamp (e.g. "1:30" or "1:02:03") or a Go duration (e.g. "1m30s"),
+// time ranges are a duration (the end), or two durations separated by a dash
+// with either of them optional (e.g. "1:23-2:05", "1m-" or "-90"),
+// percentages are rounded values between 0% and 100% (e.g. "42%") and
+// integers are strictly positive.
+const (
+	ArgTypeString     ArgType = iota
+	ArgTypeURL        ArgType = iota
+	ArgTypeDuration   ArgType = iota
+	ArgTypeTimeRange  ArgType = iota
+	ArgTypePercentage ArgType = iota
+	ArgTypeInteger    ArgType = iota
+)
+
+var (
+	// ErrUsage is returned by ParseArgs when the number of arguments or
+	// the flags do not match the specification.
+	ErrUsage = errors.New("invalid usage")
+
+	errEndBeforeStart = errors.New("end before start")
+
+	rePercentage = regexp.MustCompile(`^\d{1,3}%$`)
+	reSeconds    = regexp.MustCompile(`^-?(?:\d+(?:\.\d*)?|\.\d+)$`)
+	reTimestamp  = regexp.MustCompile(`^(?:(\d+):)?(\d+):(\d{1,2}(?:\.\d+)?)$`)
+)
+
+// ArgSpec is the specification of a positional argument of a command.
+type ArgSpec struct {
+	// Name of the argument, shown in the usage and used to retrieve its
+	// value from the Params.
+	Name string
+	Type ArgType
+
+	// Optional arguments can be omitted, they have to come after all the
+	// required arguments.
+	Optional bool
+
+	// A variadic argument takes all the remaining words, it has to be the
+	// last argument.
+	Variadic bool
+}
+
+// FlagSpec is the specification of a flag of a command (e.g. "-v voice").
+// Only the declared flags are recognized, before the positional arguments,
+// "--" can be used to stop looking for them.
+type FlagSpec struct {
+	// Name of the flag without its dash (e.g. "v").
+	Name string
+
+	// Name of the value of this flag, shown in the usage.  Flags without
+	// Value are booleans.
+	Value string
+}
+
+// Params holds the arguments of a message once parsed according to the
+// specification of its command, see InputMessage.Params.
+type Params struct {
+	values map[string]interface{}
+}
+
+// Has returns true if the given argument or flag was provided.
+func (params *Params) Has(name string) bool {
+	if params == nil {
+		return false
+	}
+	_, ok := params.values[name]
+	return ok
+}
+
+// String returns the value of a string or URL argument, or of a flag.
+func (params *Params) String(name string) string {
+	value, _ := params.get(name).(string)
+	return value
+}
+
+// Strings returns all the words of a variadic argument.
+func (params *Params) Strings(name string) []string {
+	value, _ := params.get(name).([]string)
+	return value
+}
+
+// Duration returns the value of a duration argument.
+func (params *Params) Duration(name string) time.Duration {
+	value, _ := params.get(name).(time.Duration)
+	return value
+}
+
+// TimeRange returns the value of a time range argument.
+func (params *Params) TimeRange(name string) TimeRange {
+	value, _ := params.get(name).(TimeRange)
+	return value
+}
+
+// Percentage returns the value of a percentage argument (e.g. 42 for "42%").
+func (params *Params) Percentage(name string) int {
+	value, _ := params.get(name).(int)
+	return value
+}
+
+// Int returns the value of an integer argument.
+func (params *Params) Int(name string) int {
+	value, _ := params.get(name).(int)
+	return value
+}
+
+// Bool returns true if the given boolean flag was provided.
+func (params *Params) Bool(name string) bool {
+	value, _ := params.get(name).(bool)
+	return value
+}
+
+func (params *Params) get(name string) interface{} {
+	if params == nil {
+		return nil
+	}
+	return params.values[name]
+}
+
+// ParseDuration converts a duration as accepted by ArgTypeDuration.
+func ParseDuration(value string) (time.Duration, error) {
+	var duration time.Duration
+	var err error
+
+	if tokens := reTimestamp.FindStringSubmatch(value); tokens != nil {
+		hours, _ := strconv.ParseFloat("0"+tokens[1], 64)
+		minutes, _ := strconv.ParseFloat(tokens[2], 64)
+		seconds, _ := strconv.ParseFloat(tokens[3], 64)
+		duration, err = secondsToDuration(hours*3600 + minutes*60 + seconds)
+	} else if reSeconds.MatchString(value) {
+		seconds, _ := strconv.ParseFloat(value, 64)
+		duration, err = secondsToDuration(seconds)
+	} else {
+		duration, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return 0, errors.New("invalid duration")
+	}
+
+	if duration < 0 {
+		return 0, errors.New("negative duration")
+	}
+
+	return duration, nil
+}
+
+// secondsToDuration converts a number of seconds, refusing the values which
+// do not fit in a time.Duration (including NaN and the infinities).
+func secondsToDuration(seconds float64) (time.Duration, error) {
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, errors.New("not a finite number")
+	}
+
+	if math.Abs(seconds) > float64(math.MaxInt64)/float64(time.Second) {
+		return 0, errors.New("out of range")
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// TimeRange is a portion of the timeline of a media, Start and End are zero
+// when not set.
+type TimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseTimeRange converts a time range as accepted by ArgTypeTimeRange.
+func ParseTimeRange(value string) (TimeRange, error) {
+	var timeRange TimeRange
+	var err error
+
+	bounds := strings.Split(value, "-")
+	switch len(bounds) {
+	case 1:
+		timeRange.End, err = ParseDuration(value)
+		return timeRange, err
+	case 2:
+	default:
+		return timeRange, errors.New("invalid time range")
+	}
+
+	if bounds[0] == "" && bounds[1] == "" {
+		return timeRange, errors.New("invalid time range")
+	}
+
+	if bounds[0] != "" {
+		timeRange.Start, err = ParseDuration(bounds[0])
+		if err != nil {
+			return timeRange, err
+		}
+	}
+
+	if bounds[1] != "" {
+		timeRange.End, err = ParseDuration(bounds[1])
+		if err != nil {
+			return timeRange, err
+		}
+		if timeRange.End <= timeRange.Start {
+			return timeRange, errEndBeforeStart
+		}
+	}
+
+	return timeRange, nil
+}
+
+// FormatSeconds returns the duration as a number of seconds (e.g. "90.5"), as
+// expected by the minions.
+func FormatSeconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)
+}
+
+// FormatTimestamp returns the duration as a timestamp for the users (e.g.
+// "3:42" or "1:02:03").
+func FormatTimestamp(duration time.Duration) string {
+	seconds := int(duration.Seconds())
+	hours, minutes := seconds/3600, seconds/60%60
+	seconds = seconds % 60
+
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// convertArg validates and converts a single positional argument.
+func convertArg(spec ArgSpec, value string) (interface{}, error) {
+	switch spec.Type {
+	case ArgTypeURL:
+		uri, err := url.ParseRequestURI(value)
+		if err != nil || uri.Scheme == "" {
+			return nil, fmt.Errorf("%s: not a valid URL", spec.Name)
+		}
+	case ArgTypeDuration:
+		duration, err := ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: must be a duration (e.g. 90, "+
+				"1:30 or 1m30s)", spec.Name)
+		}
+		return duration, nil
+	case ArgTypeTimeRange:
+		timeRange, err := ParseTimeRange(value)
+		if err == errEndBeforeStart {
+			return nil, fmt.Errorf("%s: the end must be after the "+
+				"start", spec.Name)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: must be a duration or a "+
+				"range (e.g. 90, 1:30 or 1:23-2:05)", spec.Name)
+		}
+		return timeRange, nil
+	case ArgTypePercentage:
+		percentage, _ := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if !rePercentage.MatchString(value) || percentage > 100 {
+			return nil, fmt.Errorf("%s: must be a rounded percentage "+
+				"(e.g. 42%%)", spec.Name)
+		}
+		return percentage, nil
+	case ArgTypeInteger:
+		integer, err := strconv.Atoi(value)
+		if err != nil || integer <= 0 {
+			return nil, fmt.Errorf("%s: must be a positive integer",
+				spec.Name)
+		}
+		return integer, nil
+	}
+
+	return value, nil
+}
+
+// ParseArgs parses the words of a message according to the given flags and
+// positional arguments.  ErrUsage is returned if the arguments do not match
+// the specification, any other error describes an invalid value.
+func ParseArgs(args []string, flags []FlagSpec, specs []ArgSpec) (*Params, error) {
+	params := &Params{values: make(map[string]interface{})}
+
+	// Flags come first, the first word which is not one of them starts
+	// the positional arguments (e.g. "-5" or "-2:05").
+	for len(args) > 0 && len(flags) > 0 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+
+		var flag *FlagSpec
+		for i := range flags {
+			if "-"+flags[i].Name == args[0] {
+				flag = &flags[i]
+			}
+		}
+		if flag == nil {
+			break
+		}
+		args = args[1:]
+
+		if flag.Value == "" {
+			params.values[flag.Name] = true
+			continue
+		}
+
+		if len(args) == 0 {
+			return nil, ErrUsage
+		}
+		params.values[flag.Name] = args[0]
+		args = args[1:]
+	}
+
+	for _, spec := range specs {
+		if len(args) == 0 {
+			if spec.Optional {
+				break
+			}
+			return nil, ErrUsage
+		}
+
+		if spec.Variadic {
+			for _, arg := range args {
+				if _, err := convertArg(spec, arg); err != nil {
+					return nil, err
+				}
+			}
+			params.values[spec.Name] = args
+			args = nil
+			break
+		}
+
+		value, err := convertArg(spec, args[0])
+		if err != nil {
+			return nil, err
+		}
+		params.values[spec.Name] = value
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		return nil, ErrUsage
+	}
+
+	return params, nil
+}
+
+// GenerateUsage returns the syntax of the given flags and positional
+// arguments (e.g. "[-v voice] sentence ...").
+func GenerateUsage(flags []FlagSpec, specs []ArgSpec) string {
+	var words []string
+
+	for _, flag := range flags {
+		if flag.Value == "" {
+			words = append(words, "[-"+flag.Name+"]")
+		} else {
+			words = append(words, "[-"+flag.Name+" "+flag.Value+"]")
+		}
+	}
+
+	for _, spec := range specs {
+		word := spec.Name
+		if spec.Variadic {
+			word += " ..."
+		}
+		if spec.Optional {
+			word = "[" + word + "]"
+		}
+		words = append(words, word)
+	}
+
+	return strings.Join(words, " ")
+}
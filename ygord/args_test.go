@@ -0,0 +1,173 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testFlagSpecs = []FlagSpec{
+		{Name: "v", Value: "voice"},
+		{Name: "q"},
+	}
+	testArgSpecs = []ArgSpec{
+		{Name: "url", Type: ArgTypeURL},
+		{Name: "end", Type: ArgTypeDuration, Optional: true},
+		{Name: "volume", Type: ArgTypePercentage, Optional: true},
+		{Name: "words", Optional: true, Variadic: true},
+	}
+)
+
+func TestParseArgs(t *testing.T) {
+	params, err := ParseArgs([]string{"-q", "-v", "bruce",
+		"http://example.com/a.mp3", "1:30", "42%", "hello", "world"},
+		testFlagSpecs, testArgSpecs)
+	if assert.Nil(t, err) {
+		assert.True(t, params.Bool("q"))
+		assert.Equal(t, "bruce", params.String("v"))
+		assert.Equal(t, "http://example.com/a.mp3", params.String("url"))
+		assert.Equal(t, 90*time.Second, params.Duration("end"))
+		assert.Equal(t, 42, params.Percentage("volume"))
+		assert.Equal(t, []string{"hello", "world"}, params.Strings("words"))
+	}
+
+	params, err = ParseArgs([]string{"--", "http://example.com/a.mp3"},
+		testFlagSpecs, testArgSpecs)
+	if assert.Nil(t, err) {
+		assert.False(t, params.Bool("q"))
+		assert.False(t, params.Has("v"))
+		assert.False(t, params.Has("end"))
+		assert.Nil(t, params.Strings("words"))
+	}
+
+	params, err = ParseArgs([]string{"-q", "--", "-v", "bruce"},
+		testFlagSpecs, []ArgSpec{{Name: "words", Variadic: true}})
+	if assert.Nil(t, err) {
+		assert.True(t, params.Bool("q"))
+		assert.False(t, params.Has("v"))
+		assert.Equal(t, []string{"-v", "bruce"}, params.Strings("words"))
+	}
+}
+
+func TestParseArgsDashedValues(t *testing.T) {
+	// Words starting with a dash which are not declared flags are
+	// positional arguments.
+	spec := []ArgSpec{{Name: "range", Type: ArgTypeTimeRange}}
+	for value, expected := range map[string]TimeRange{
+		"-5":    {End: 5 * time.Second},
+		"-2:05": {End: 125 * time.Second},
+	} {
+		params, err := ParseArgs([]string{value}, testFlagSpecs, spec)
+		if assert.Nil(t, err, value) {
+			assert.Equal(t, expected, params.TimeRange("range"), value)
+		}
+	}
+
+	params, err := ParseArgs([]string{"-q", "-5", "degrees"}, testFlagSpecs,
+		[]ArgSpec{{Name: "words", Variadic: true}})
+	if assert.Nil(t, err) {
+		assert.True(t, params.Bool("q"))
+		assert.Equal(t, []string{"-5", "degrees"}, params.Strings("words"))
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{}, ErrUsage.Error()},
+		{[]string{"-x", "http://example.com/"}, "url: not a valid URL"},
+		{[]string{"-v"}, ErrUsage.Error()},
+		{[]string{"example.com"}, "url: not a valid URL"},
+		{[]string{"http://example.com/", "soon"},
+			"end: must be a duration (e.g. 90, 1:30 or 1m30s)"},
+		{[]string{"http://example.com/", "1", "101%"},
+			"volume: must be a rounded percentage (e.g. 42%)"},
+	} {
+		_, err := ParseArgs(test.args, testFlagSpecs, testArgSpecs)
+		if assert.NotNil(t, err, "%v", test.args) {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+
+	_, err := ParseArgs([]string{"a", "b"}, nil, []ArgSpec{{Name: "a"}})
+	assert.Equal(t, ErrUsage, err)
+
+	integer := []ArgSpec{{Name: "n", Type: ArgTypeInteger}}
+	params, err := ParseArgs([]string{"12"}, nil, integer)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 12, params.Int("n"))
+	}
+	_, err = ParseArgs([]string{"0"}, nil, integer)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "n: must be a positive integer", err.Error())
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"30":      30 * time.Second,
+		"1.5":     1500 * time.Millisecond,
+		"1:23":    83 * time.Second,
+		"1:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+		"1m30s":   90 * time.Second,
+	} {
+		duration, err := ParseDuration(value)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, duration, value)
+	}
+
+	for _, value := range []string{"", "-1", "1:xx", "soon", "NaN", "nan",
+		"Inf", "+Inf", "-Inf", "infinity", "1e9", "0x10", "99999999999",
+		"9999999:00:00"} {
+		_, err := ParseDuration(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	for value, expected := range map[string]TimeRange{
+		"30":        {End: 30 * time.Second},
+		"1:23-2:05": {Start: 83 * time.Second, End: 125 * time.Second},
+		"1m30s-":    {Start: 90 * time.Second},
+		"-90":       {End: 90 * time.Second},
+	} {
+		timeRange, err := ParseTimeRange(value)
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected, timeRange, value)
+	}
+
+	for _, value := range []string{"", "-", "1-2-3", "soon-", "2:05-1:23"} {
+		_, err := ParseTimeRange(value)
+		assert.NotNil(t, err, value)
+	}
+
+	spec := []ArgSpec{{Name: "range", Type: ArgTypeTimeRange}}
+	_, err := ParseArgs([]string{"2:05-1:23"}, nil, spec)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "range: the end must be after the start", err.Error())
+	}
+	_, err = ParseArgs([]string{"soon"}, nil, spec)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "range: must be a duration or a range (e.g. 90, 1:30 or 1:23-2:05)", err.Error())
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	assert.Equal(t, "0:00", FormatTimestamp(0))
+	assert.Equal(t, "0:09", FormatTimestamp(9500*time.Millisecond))
+	assert.Equal(t, "3:42", FormatTimestamp(222*time.Second))
+	assert.Equal(t, "1:02:03", FormatTimestamp(time.Hour+2*time.Minute+3*time.Second))
+}
+
+func TestGenerateUsage(t *testing.T) {
+	assert.Equal(t, "[-v voice] [-q] url [end] [volume] [words ...]",
+		GenerateUsage(testFlagSpecs, testArgSpecs))
+	assert.Equal(t, "", GenerateUsage(nil, nil))
+}
@@ -0,0 +1,272 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the audit log: every command executed is recorded with
+// its author and what was sent to the minions, as one JSON document per line
+// in an append-only file rotated by size.  The most recent entries are also
+// kept in memory to be browsed with the 'history' command and the /history
+// endpoint.
+//
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditLogRecentSize is the number of entries kept in memory.
+	AuditLogRecentSize = 1000
+)
+
+// AuditClientCommand is a command sent to the minions while executing a chat
+// command, with the IDs of the targeted clients.
+type AuditClientCommand struct {
+	ClientCommand
+	Clients []string `json:"clients"`
+}
+
+// AuditEntry is the record of a single executed command.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Adapter  string    `json:"adapter"`
+	Nickname string    `json:"nickname"`
+	Channel  string    `json:"channel"`
+
+	// Name of the command and body of the message once the aliases are
+	// expanded.
+	Command string `json:"command"`
+	Body    string `json:"body"`
+
+	ClientCommands []AuditClientCommand `json:"clientCommands"`
+}
+
+// NewAuditEntry creates the entry recording the execution of a message.
+func NewAuditEntry(cmd Command, msg *InputMessage) *AuditEntry {
+	return &AuditEntry{
+		Time:           time.Now().UTC(),
+		Adapter:        msg.Adapter,
+		Nickname:       msg.Nickname,
+		Channel:        msg.ReplyTo,
+		Command:        cmd.Name,
+		Body:           msg.Body,
+		ClientCommands: []AuditClientCommand{},
+	}
+}
+
+// AddClientCommand records a command sent to the given clients, it does
+// nothing if the entry is nil.
+func (entry *AuditEntry) AddClientCommand(cmd ClientCommand, clients []string) {
+	if entry == nil {
+		return
+	}
+	if clients == nil {
+		clients = []string{}
+	}
+	entry.ClientCommands = append(entry.ClientCommands,
+		AuditClientCommand{cmd, clients})
+}
+
+// String returns a one-line summary of the entry.
+func (entry *AuditEntry) String() string {
+	return fmt.Sprintf("%s %s/%s in %s: %s",
+		entry.Time.Format("2006-01-02 15:04:05"), entry.Adapter,
+		entry.Nickname, entry.Channel, entry.Body)
+}
+
+// AuditLog writes the entries to disk and keeps the most recent ones in
+// memory.  Without path, nothing is written.
+type AuditLog struct {
+	sync.Mutex
+
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+
+	recent []*AuditEntry
+}
+
+// OpenAuditLog opens (or creates) the audit log file at the given path and
+// loads its most recent entries.  Once maxSize bytes are reached, the file is
+// rotated (path.1, path.2, ...), keeping at most maxFiles old files.
+func OpenAuditLog(path string, maxSize int64, maxFiles int) (*AuditLog, error) {
+	auditLog := &AuditLog{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+
+	if path == "" {
+		return auditLog, nil
+	}
+
+	if err := auditLog.load(); err != nil {
+		return nil, err
+	}
+
+	if err := auditLog.open(); err != nil {
+		return nil, err
+	}
+
+	return auditLog, nil
+}
+
+// load reads the current file to fill the recent entries.  Invalid lines are
+// skipped.
+func (auditLog *AuditLog) load() error {
+	file, err := os.Open(auditLog.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			continue
+		}
+		auditLog.remember(entry)
+	}
+
+	return scanner.Err()
+}
+
+// open opens the current file for appending.
+func (auditLog *AuditLog) open() error {
+	file, err := os.OpenFile(auditLog.path,
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	auditLog.file = file
+	auditLog.size = info.Size()
+
+	return nil
+}
+
+// rotate moves the current file to path.1 (shifting the older files) and
+// starts a new one.
+func (auditLog *AuditLog) rotate() error {
+	if err := auditLog.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", auditLog.path, auditLog.maxFiles))
+	for i := auditLog.maxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", auditLog.path, i),
+			fmt.Sprintf("%s.%d", auditLog.path, i+1))
+	}
+
+	if auditLog.maxFiles > 0 {
+		err := os.Rename(auditLog.path, auditLog.path+".1")
+		if err != nil {
+			return err
+		}
+	} else {
+		os.Remove(auditLog.path)
+	}
+
+	return auditLog.open()
+}
+
+// remember adds the entry to the recent entries.
+func (auditLog *AuditLog) remember(entry *AuditEntry) {
+	auditLog.recent = append(auditLog.recent, entry)
+	if len(auditLog.recent) > AuditLogRecentSize {
+		auditLog.recent = auditLog.recent[1:]
+	}
+}
+
+// Append records an entry.
+func (auditLog *AuditLog) Append(entry *AuditEntry) error {
+	auditLog.Lock()
+	defer auditLog.Unlock()
+
+	auditLog.remember(entry)
+
+	if auditLog.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if auditLog.maxSize > 0 && auditLog.size > 0 &&
+		auditLog.size+int64(len(data)) > auditLog.maxSize {
+		if err := auditLog.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := auditLog.file.Write(data)
+	auditLog.size += int64(n)
+
+	return err
+}
+
+// Recent returns up to n of the most recent entries, most recent first.  If
+// channel is set, only the entries from this channel are returned.
+func (auditLog *AuditLog) Recent(n int, channel string) []*AuditEntry {
+	auditLog.Lock()
+	defer auditLog.Unlock()
+
+	entries := []*AuditEntry{}
+	for i := len(auditLog.recent) - 1; i >= 0 && len(entries) < n; i-- {
+		entry := auditLog.recent[i]
+		if channel != "" && entry.Channel != channel {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Close closes the file of the audit log.
+func (auditLog *AuditLog) Close() error {
+	auditLog.Lock()
+	defer auditLog.Unlock()
+
+	if auditLog.file == nil {
+		return nil
+	}
+
+	err := auditLog.file.Close()
+	auditLog.file = nil
+
+	return err
+}
+
+// SendToMinions sends a command to the minions of the channel the message
+// came from, and records it in the audit entry of the message.
+func (srv *Server) SendToMinions(msg *InputMessage, cmd ClientCommand) {
+	clients := srv.SendToChannelMinions(msg.ReplyTo, cmd)
+	msg.Audit.AddClientCommand(cmd, clients)
+}
+
+// Audit records the execution of a message in the audit log.
+func (srv *Server) Audit(entry *AuditEntry) {
+	if err := srv.AuditLog.Append(entry); err != nil {
+		srv.Errorf("audit log error: %s", err.Error())
+	}
+}
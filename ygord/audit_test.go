@@ -0,0 +1,98 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAuditEntry(channel, body string) *AuditEntry {
+	return &AuditEntry{
+		Time:     fakeNow,
+		Adapter:  "irc",
+		Nickname: "bob",
+		Channel:  channel,
+		Command:  strings.Fields(body)[0],
+		Body:     body,
+	}
+}
+
+func TestAuditLogRecent(t *testing.T) {
+	auditLog, err := OpenAuditLog("", 0, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	auditLog.Append(newTestAuditEntry("#test", "play a.mp3"))
+	auditLog.Append(newTestAuditEntry("#lobby", "play b.mp3"))
+	auditLog.Append(newTestAuditEntry("#test", "skip"))
+
+	entries := auditLog.Recent(2, "")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "skip", entries[0].Body)
+		assert.Equal(t, "play b.mp3", entries[1].Body)
+	}
+
+	entries = auditLog.Recent(10, "#test")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "skip", entries[0].Body)
+		assert.Equal(t, "play a.mp3", entries[1].Body)
+		assert.Equal(t, "1982-10-20 19:00:00 irc/bob in #test: play a.mp3",
+			entries[1].String())
+	}
+}
+
+func TestAuditLogRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ygord-audit")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// Every entry is about 170 bytes, rotate after two.
+	auditLog, err := OpenAuditLog(path, 400, 2)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, body := range []string{"play 1", "play 2", "play 3", "play 4", "play 5", "play 6", "play 7"} {
+		assert.Nil(t, auditLog.Append(newTestAuditEntry("#test", body)))
+	}
+	assert.Nil(t, auditLog.Close())
+
+	for suffix, bodies := range map[string][]string{
+		"":   {"play 7"},
+		".1": {"play 5", "play 6"},
+		".2": {"play 3", "play 4"},
+	} {
+		data, err := ioutil.ReadFile(path + suffix)
+		if assert.Nil(t, err, suffix) {
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if assert.Len(t, lines, len(bodies), suffix) {
+				for i, body := range bodies {
+					assert.Contains(t, lines[i], `"body":"`+body+`"`)
+				}
+			}
+		}
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// The current file is loaded on startup.
+	auditLog, err = OpenAuditLog(path, 400, 2)
+	if assert.Nil(t, err) {
+		entries := auditLog.Recent(10, "")
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "play 7", entries[0].Body)
+			assert.Equal(t, fakeNow, entries[0].Time)
+		}
+		auditLog.Close()
+	}
+}
@@ -3,6 +3,10 @@
 
 package main
 
+import (
+	"strings"
+)
+
 // IRCMessageFunction is used as a type of function that receives a message from IRC
 type IRCMessageFunction func(*Server, *InputMessage)
 
@@ -20,6 +24,11 @@ type Command struct {
 	// If this command should be triggered by regexp.
 	ToggleFunction ToggleFunction
 
+	// Order in which the toggle commands are tried when no command matches
+	// the exact name of a message, highest first.  Commands with the same
+	// priority are tried by name.
+	Priority int
+
 	// Function executed when the command is called from IRC.
 	PrivMsgFunction IRCMessageFunction
 
@@ -32,23 +41,100 @@ type Command struct {
 
 	// Set to true of this command can be issued in a channel.
 	AllowChannel bool
+
+	// Immediate commands are run as soon as they are received.  The others
+	// wait for the media still being resolved in the channel, so that
+	// "play x; skip" skips x (see MediaQueue.After).  The commands which
+	// resolve media keep their order through the MediaQueue anyway.
+	Immediate bool
+
+	// Minimum role required to run this command.  Admin commands can
+	// only be issued from the AdminChannel.
+	Role  Role
+	Admin bool
+
+	// Default limit of how often this command can be run, see RateLimit.
+	RateLimit RateLimit
+
+	// Flags and positional arguments accepted by this command.  They are
+	// parsed into msg.Params before PrivMsgFunction is called, the usage
+	// of the command is generated from them.
+	Flags []FlagSpec
+	Args  []ArgSpec
+
+	// Short description of what this command does.
+	Description string
+
+	// Examples of complete invocations, shown by 'help'.
+	Examples []string
 }
 
-// IRCMessageMatches checks if the given Message matches the command.
-func (cmd Command) IRCMessageMatches(srv *Server, msg *InputMessage) bool {
-	// Not even the right command.
-	if cmd.ToggleFunction != nil {
-		if !cmd.ToggleFunction(srv, msg) {
-			return false
+// UsageText returns the complete syntax of the command (e.g. "play url
+// [range]").
+func (cmd Command) UsageText() string {
+	return strings.TrimSpace(cmd.Name + " " +
+		GenerateUsage(cmd.Flags, cmd.Args))
+}
+
+// Run checks the permissions of the author, parses the arguments of the
+// message into msg.Params, enforces the rate limit of the command, executes
+// it and records it in the audit log.  If the user is not allowed to run it,
+// the arguments are invalid or the user has to wait, they get the reason or
+// the usage of the command instead.
+func (cmd Command) Run(srv *Server, msg *InputMessage) {
+	if err := srv.CheckPermission(cmd, msg); err != nil {
+		srv.Logf(SeverityInfo, "%s: %s/%s", err.Error(), msg.Adapter,
+			msg.Nickname)
+		srv.ReplyError(msg, err.Error())
+		return
+	}
+
+	params, err := ParseArgs(msg.Args, cmd.Flags, cmd.Args)
+	if err == ErrUsage {
+		srv.Reply(msg, "usage: "+cmd.UsageText())
+		return
+	} else if err != nil {
+		srv.ReplyError(msg, err.Error())
+		return
+	}
+
+	if err := srv.CheckRateLimit(cmd, msg); err != nil {
+		srv.Logf(SeverityInfo, "throttled %s: %s/%s", cmd.Name,
+			msg.Adapter, msg.Nickname)
+		if msg.Nickname != "" {
+			srv.Reply(msg, "sorry "+msg.Nickname+", "+err.Error())
+		} else {
+			srv.Reply(msg, "sorry, "+err.Error())
 		}
-	} else if cmd.Name != msg.Command {
-		return false
+		return
 	}
 
+	msg.Params = params
+	msg.Audit = NewAuditEntry(cmd, msg)
+	cmd.PrivMsgFunction(srv, msg)
+	if msg.pending == 0 {
+		srv.Audit(msg.Audit)
+	}
+}
+
+// IsAllowed checks if the command can be issued from the given message
+// (e.g. in private).
+func (cmd Command) IsAllowed(msg *InputMessage) bool {
 	// Check if the command forbids private messages.
-	if !cmd.AllowPrivate && msg.Type == InputMsgTypeIRCPrivate {
+	if !cmd.AllowPrivate && msg.Type == InputMsgTypePrivate {
 		return false
 	}
 
 	return true
 }
+
+type commandsByPriority []Command
+
+func (cmds commandsByPriority) Len() int      { return len(cmds) }
+func (cmds commandsByPriority) Swap(i, j int) { cmds[i], cmds[j] = cmds[j], cmds[i] }
+func (cmds commandsByPriority) Less(i, j int) bool {
+	if cmds[i].Priority != cmds[j].Priority {
+		return cmds[i].Priority > cmds[j].Priority
+	}
+	return cmds[i].Name < cmds[j].Name
+}
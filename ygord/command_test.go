@@ -0,0 +1,81 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func registerDispatchTestCommands(srv *Server, calls *[]string) {
+	always := func(srv *Server, msg *InputMessage) bool { return true }
+	record := func(name string) IRCMessageFunction {
+		return func(srv *Server, msg *InputMessage) {
+			*calls = append(*calls, name)
+		}
+	}
+
+	srv.RegisterCommand(Command{
+		Name:            "stop",
+		PrivMsgFunction: record("stop"),
+		Args:            []ArgSpec{{Name: "words", Optional: true, Variadic: true}},
+	})
+	srv.RegisterCommand(Command{
+		Name:            "toggle-low",
+		ToggleFunction:  always,
+		PrivMsgFunction: record("toggle-low"),
+	})
+	srv.RegisterCommand(Command{
+		Name:            "toggle-high-b",
+		ToggleFunction:  always,
+		Priority:        10,
+		PrivMsgFunction: record("toggle-high-b"),
+		Args:            []ArgSpec{{Name: "words", Optional: true, Variadic: true}},
+	})
+	srv.RegisterCommand(Command{
+		Name:            "toggle-high-a",
+		ToggleFunction:  always,
+		Priority:        10,
+		PrivMsgFunction: record("toggle-high-a"),
+		Args:            []ArgSpec{{Name: "words", Optional: true, Variadic: true}},
+	})
+}
+
+func TestServerDispatchDeterministic(t *testing.T) {
+	var calls []string
+
+	// Map iteration order is random, try enough times to catch it.
+	for i := 0; i < 50; i++ {
+		srv := CreateTestServer()
+		registerDispatchTestCommands(srv, &calls)
+
+		srv.IRCMessageHandler(&InputMessage{Command: "stop", Args: []string{"it"}})
+		srv.IRCMessageHandler(&InputMessage{Command: "stahp", Args: []string{"it"}})
+	}
+
+	if assert.Len(t, calls, 100) {
+		for i := 0; i < len(calls); i += 2 {
+			assert.Equal(t, "stop", calls[i])
+			assert.Equal(t, "toggle-high-a", calls[i+1])
+		}
+	}
+}
+
+func TestServerDispatchToggleAllowPrivate(t *testing.T) {
+	var calls []string
+
+	srv := CreateTestServer()
+	registerDispatchTestCommands(srv, &calls)
+	cmd := srv.RegisteredCommands["toggle-low"]
+	cmd.AllowPrivate = true
+	srv.RegisterCommand(cmd)
+
+	srv.IRCMessageHandler(&InputMessage{
+		Type:    InputMsgTypePrivate,
+		Command: "stop",
+	})
+
+	assert.Equal(t, []string{"toggle-low"}, calls)
+}
@@ -6,7 +6,9 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"sort"
 
 	"github.com/jessevdk/go-flags"
 )
@@ -14,10 +16,59 @@ import (
 // CmdLine is a singleton used to store the command-line parameters.
 type CmdLine struct {
 	ConfigFile string `short:"c" description:"Configuration file" default:"/etc/ygord.conf"`
+
+	// Console mode, used to talk to ygor from stdin/stdout.
+	Console         bool   `long:"console" description:"Read commands from stdin and print replies to stdout"`
+	ConsoleNickname string `long:"console-nickname" description:"Nickname used for commands read from stdin" default:"console"`
+	ConsoleChannel  string `long:"console-channel" description:"Channel used for commands read from stdin" default:"#ygor"`
 }
 
 // ChannelCfg represents a per-channel grouping of minions.
 type ChannelCfg struct {
+	// Matrix room ID (e.g. "!abcdef:matrix.org") bridged to this channel.
+	MatrixRoomID string
+
+	// Discord channel ID (e.g. "175928847299117063") bridged to this
+	// channel.
+	DiscordChannelID string
+
+	// Roles granted in this channel only, same format as Config.Roles.
+	Roles map[string]map[string]string
+}
+
+// MattermostCfg represents a single Mattermost integration: an outgoing
+// webhook and/or a slash command to receive messages, and either an incoming
+// webhook or a bot account to reply.
+type MattermostCfg struct {
+	// Name of this integration, used in logs.
+	Name string
+
+	// Token of the outgoing webhook (e.g. "ygor: play ...").
+	Token string
+
+	// Token of the slash command (e.g. "/ygor play ...") and type of its
+	// responses: "in_channel" (default) or "ephemeral".
+	SlashCommandToken string
+	SlashResponseType string
+
+	// Incoming webhook used to reply, with the name and icon used for the
+	// posts.
+	Webhook  string
+	Username string
+	IconURL  string
+
+	// If both are defined, ygor replies as a bot account through the
+	// Mattermost REST API instead of the webhook, in the channel the
+	// request came from.  Replies are threaded if ReplyInThread is set.
+	URL           string
+	AccessToken   string
+	ReplyInThread bool
+
+	// Maps Mattermost channel names to ygor channels (e.g.
+	// "town-square": "#ygor").  Unmapped channels are used as-is for
+	// the requests, but the messages not replying to a request (e.g.
+	// admin log) only reach the mapped channels.
+	Channels map[string]string
 }
 
 // Config is a singleton used to store the file configuration.
@@ -33,15 +84,86 @@ type Config struct {
 	Nickname string
 
 	// Try to send debug information to this channel in lieu of log file.
+	// The commands managing the roles can only be used from there.
 	AdminChannel string
 
-	// Any chatter from these nicks will be dropped (other bots).
+	// Chat adapter used to reach the AdminChannel (default "irc").  Log
+	// messages at least as severe as AdminLogLevel ("info", "warning" or
+	// "error", default "warning") are forwarded there, at most
+	// AdminLogQuota (default 5) per AdminLogPeriod (default "1m").
+	AdminAdapter   string
+	AdminLogLevel  string
+	AdminLogQuota  int
+	AdminLogPeriod string
+
+	// If defined, every command executed is recorded in this file, one
+	// JSON document per line.  The file is rotated once it reaches
+	// AuditLogMaxSize megabytes (default 10), AuditLogMaxFiles old files
+	// are kept (default 5).
+	AuditLogPath     string
+	AuditLogMaxSize  int
+	AuditLogMaxFiles int
+
+	// Resolved media are cached by URL for MediaCacheTTL (default "24h"),
+	// rejected ones for MediaCacheNegativeTTL (default "10m"), keeping at
+	// most MediaCacheSize entries (default 1000).  If MediaCachePath is
+	// defined, the cache is saved to this file.
+	MediaCachePath        string
+	MediaCacheSize        int
+	MediaCacheTTL         string
+	MediaCacheNegativeTTL string
+
+	// If defined, the files uploaded to the media library are stored in
+	// this directory and served to the minions from LibraryURL (default
+	// "/library/file/").  Larger files than LibraryMaxFileSize megabytes
+	// are rejected (default 20).
+	LibraryDir         string
+	LibraryURL         string
+	LibraryMaxFileSize int
+
+	// If defined, the images, audio and video files are downloaded by
+	// ygord to this directory and served to the minions from
+	// MediaProxyURL (default "/media/proxy/").  At most MediaProxyMaxSize
+	// megabytes are kept (default 1024), larger files than
+	// MediaProxyMaxFileSize megabytes are rejected (default 100).
+	MediaProxyDir         string
+	MediaProxyURL         string
+	MediaProxyMaxSize     int
+	MediaProxyMaxFileSize int
+
+	// ygord only fetches the media from public addresses, the addresses
+	// or CIDR ranges of OutboundAllowlist are allowed as well (e.g. an
+	// intranet hosting media).  The URLs of the other hosts given as IP
+	// addresses are passed as-is to the minions.
+	OutboundAllowlist []string
+
+	// Media are resolved in the background by MediaWorkers workers
+	// (default 4), giving up after MediaTimeout (default "10s").
+	MediaWorkers int
+	MediaTimeout string
+
+	// Rate limits per command name, overriding the defaults of the
+	// commands.  The "*" limit applies to all the commands combined.
+	RateLimits map[string]RateLimitCfg
+
+	// Roles of the users for each chat adapter (e.g. "irc": {"bob":
+	// "admin"}).  Users without role can only run the commands open to
+	// everyone, see Role for the available roles.  Discord users are
+	// identified by their numeric user ID since usernames can change.
+	Roles map[string]map[string]string
+
+	// Any chatter from these nicks will be dropped (other bots).  Use the
+	// numeric user ID for Discord users, Discord bots are always dropped.
 	Ignore []string
 
 	// Where to find the alias file. Will use the local alias file found in
 	// the current directory by default.
 	AliasFilePath string
 
+	// The media URLs used by the aliases are checked every
+	// AliasCheckInterval (default "24h", "0" to disable).
+	AliasCheckInterval string
+
 	// Where to find the web files (static folder).
 	WebRoot string
 
@@ -60,7 +182,23 @@ type Config struct {
 	// "screensaver" alias if it exists.
 	ScreensaverDelay int
 
-	// Mattermost configuration.
+	// Matrix configuration, the adapter is enabled if both values are
+	// defined.  The access token is obtained by logging in as the bot
+	// user with any Matrix client.
+	MatrixHomeserverURL string
+	MatrixAccessToken   string
+
+	// Discord configuration, the adapter is enabled if a bot token is
+	// defined.  The API URL defaults to the official Discord API.
+	DiscordBotToken string
+	DiscordAPIURL   string
+
+	// All the Mattermost integrations, each talking to its own team or set
+	// of channels.
+	MattermostIntegrations []MattermostCfg
+
+	// Single Mattermost integration configuration, kept for compatibility,
+	// it is added to MattermostIntegrations as "default".
 	MattermostToken    string
 	MattermostIconURL  string
 	MattermostUsername string
@@ -83,6 +221,81 @@ func (cfg *Config) GetAutoJoinChannels() []string {
 	return channels.Array()
 }
 
+// GetChannelByMatrixRoomID returns the name of the channel bridged with the
+// given Matrix room, or an empty string if none is.
+func (cfg *Config) GetChannelByMatrixRoomID(roomID string) string {
+	if roomID == "" {
+		return ""
+	}
+
+	for name, channel := range cfg.Channels {
+		if channel.MatrixRoomID == roomID {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// GetChannelByDiscordChannelID returns the name of the channel bridged with
+// the given Discord channel, or an empty string if none is.
+func (cfg *Config) GetChannelByDiscordChannelID(channelID string) string {
+	if channelID == "" {
+		return ""
+	}
+
+	for name, channel := range cfg.Channels {
+		if channel.DiscordChannelID == channelID {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// GetMattermostIntegrationByToken returns the Mattermost integration using
+// the given outgoing webhook token (or slash command token if 'slash' is
+// set), or nil if there is none.
+func (cfg *Config) GetMattermostIntegrationByToken(token string, slash bool) *MattermostCfg {
+	if token == "" {
+		return nil
+	}
+
+	for i := range cfg.MattermostIntegrations {
+		integration := &cfg.MattermostIntegrations[i]
+		if slash && integration.SlashCommandToken == token {
+			return integration
+		}
+		if !slash && integration.Token == token {
+			return integration
+		}
+	}
+
+	return nil
+}
+
+// GetMattermostChannel returns the first Mattermost integration mapping one
+// of its channels to the given ygor channel, and the name of this Mattermost
+// channel.  The integration is nil if no channel is mapped.
+func (cfg *Config) GetMattermostChannel(channel string) (*MattermostCfg, string) {
+	for i := range cfg.MattermostIntegrations {
+		integration := &cfg.MattermostIntegrations[i]
+
+		var names []string
+		for name, target := range integration.Channels {
+			if target == channel {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			return integration, names[0]
+		}
+	}
+
+	return nil, ""
+}
+
 // ParseConfigFile reads our JSON config file and validates its values, also
 // populating defaults when possible.
 func ParseConfigFile(cmd *CmdLine) (*Config, error) {
@@ -113,6 +326,106 @@ func ParseConfigFile(cmd *CmdLine) (*Config, error) {
 		}
 	}
 
+	if cfg.AdminAdapter == "" {
+		cfg.AdminAdapter = "irc"
+	}
+
+	_, err = NewAdminLog(cfg.AdminLogLevel, cfg.AdminLogQuota,
+		cfg.AdminLogPeriod)
+	if err != nil {
+		return cfg, errors.New("admin log: " + err.Error())
+	}
+
+	_, err = NewMediaCache(cfg.MediaCacheSize, cfg.MediaCacheTTL,
+		cfg.MediaCacheNegativeTTL)
+	if err != nil {
+		return cfg, errors.New("media cache: " + err.Error())
+	}
+
+	_, err = NewOutboundPolicy(cfg.OutboundAllowlist)
+	if err != nil {
+		return cfg, errors.New("'OutboundAllowlist': " + err.Error())
+	}
+
+	_, err = NewAliasChecker(cfg.AliasCheckInterval)
+	if err != nil {
+		return cfg, errors.New("alias checker: " + err.Error())
+	}
+
+	_, err = NewMediaQueue(cfg.MediaWorkers, cfg.MediaTimeout)
+	if err != nil {
+		return cfg, errors.New("media queue: " + err.Error())
+	}
+
+	if err := validateRoles(cfg.Roles); err != nil {
+		return cfg, errors.New("'Roles': " + err.Error())
+	}
+
+	for name, channel := range cfg.Channels {
+		if err := validateRoles(channel.Roles); err != nil {
+			return cfg, errors.New("channel '" + name +
+				"': 'Roles': " + err.Error())
+		}
+	}
+
+	for name, limit := range cfg.RateLimits {
+		if _, err := limit.Parse(); err != nil {
+			return cfg, errors.New("rate limit '" + name + "': " +
+				err.Error())
+		}
+	}
+
+	if cfg.MattermostToken != "" {
+		cfg.MattermostIntegrations = append(cfg.MattermostIntegrations,
+			MattermostCfg{
+				Name:     "default",
+				Token:    cfg.MattermostToken,
+				Webhook:  cfg.MattermostWebhook,
+				Username: cfg.MattermostUsername,
+				IconURL:  cfg.MattermostIconURL,
+			})
+	}
+
+	for i := range cfg.MattermostIntegrations {
+		integration := &cfg.MattermostIntegrations[i]
+		if integration.Name == "" {
+			integration.Name = fmt.Sprintf("mattermost%d", i+1)
+		}
+		switch integration.SlashResponseType {
+		case "":
+			integration.SlashResponseType = "in_channel"
+		case "in_channel", "ephemeral":
+		default:
+			return cfg, errors.New("Mattermost integration '" +
+				integration.Name + "': 'SlashResponseType' must be" +
+				" 'in_channel' or 'ephemeral'")
+		}
+	}
+
+	if cfg.DiscordAPIURL == "" {
+		cfg.DiscordAPIURL = "https://discordapp.com/api"
+	}
+
+	if cfg.LibraryMaxFileSize == 0 {
+		cfg.LibraryMaxFileSize = 20
+	}
+
+	if cfg.MediaProxyMaxSize == 0 {
+		cfg.MediaProxyMaxSize = 1024
+	}
+
+	if cfg.MediaProxyMaxFileSize == 0 {
+		cfg.MediaProxyMaxFileSize = 100
+	}
+
+	if cfg.AuditLogMaxSize == 0 {
+		cfg.AuditLogMaxSize = 10
+	}
+
+	if cfg.AuditLogMaxFiles == 0 {
+		cfg.AuditLogMaxFiles = 5
+	}
+
 	// No delay configured == 15 minutes
 	if cfg.ScreensaverDelay == 0 {
 		cfg.ScreensaverDelay = 900
@@ -121,6 +434,19 @@ func ParseConfigFile(cmd *CmdLine) (*Config, error) {
 	return cfg, nil
 }
 
+// validateRoles checks all the role names of a Roles configuration.
+func validateRoles(roles map[string]map[string]string) error {
+	for adapter, users := range roles {
+		for nickname, name := range users {
+			if _, err := ParseRole(name); err != nil {
+				return fmt.Errorf("%s/%s: %s", adapter, nickname,
+					err.Error())
+			}
+		}
+	}
+	return nil
+}
+
 // ParseCommandLine parses the command line arguments and populate the global
 // cmd struct.
 func ParseCommandLine() *CmdLine {
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"github.com/truveris/ygor/ygord/alias"
 	"net/http"
+	"time"
 )
 
 // AliasListHandler is the HTTP Handler for the list of aliases.
@@ -21,6 +22,11 @@ type AliasListHandler struct {
 // on this endpoint.
 type AliasListResponse struct {
 	Aliases []alias.Alias `json:"aliases"`
+
+	// Broken lists the aliases which failed the last check, done at
+	// Checked (null if none ran yet).
+	Broken  []BrokenAlias `json:"broken"`
+	Checked *time.Time    `json:"checked"`
 }
 
 // ServeHTTP is a standard handler ServeHTTP request as expected by the
@@ -39,6 +45,11 @@ func (handler *AliasListHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	}
 
 	response := AliasListResponse{Aliases: aliases}
+	broken, checked := handler.AliasChecker.Broken(handler.Aliases)
+	response.Broken = broken
+	if !checked.IsZero() {
+		response.Checked = &checked
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -0,0 +1,57 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// http_command_list.go contains the API endpoint returning the list of
+// commands with their usage.
+//
+
+package main
+
+import (
+	"net/http"
+)
+
+// CommandListHandler is the HTTP Handler for the list of commands.
+type CommandListHandler struct {
+	*Server
+}
+
+type respCommand struct {
+	Name        string   `json:"name"`
+	Usage       string   `json:"usage"`
+	Description string   `json:"description"`
+	Examples    []string `json:"examples"`
+	Role        string   `json:"role"`
+}
+
+// CommandListResponse is the struct returned as JSON in response to a request
+// on this endpoint.
+type CommandListResponse struct {
+	Commands []respCommand `json:"commands"`
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *CommandListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, err := auth(r)
+	if err != nil {
+		errorHandler(w, "Authentication failed", err)
+		return
+	}
+
+	response := CommandListResponse{Commands: []respCommand{}}
+
+	for _, cmd := range handler.Server.GetUserCommands() {
+		response.Commands = append(response.Commands, respCommand{
+			Name:        cmd.Name,
+			Usage:       cmd.UsageText(),
+			Description: cmd.Description,
+			Examples:    cmd.Examples,
+			Role:        cmd.Role.String(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonHandler(w, response)
+}
@@ -0,0 +1,41 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// http_command_stats.go contains the API endpoint returning how many times
+// each command was run or throttled, in total and per user.
+//
+
+package main
+
+import (
+	"net/http"
+)
+
+// CommandStatsHandler is the HTTP Handler for the command counters.
+type CommandStatsHandler struct {
+	*Server
+}
+
+// CommandStatsResponse is the struct returned as JSON in response to a
+// request on this endpoint.  Users are named "adapter/nickname".
+type CommandStatsResponse struct {
+	Commands map[string]RateLimitCounter `json:"commands"`
+	Users    map[string]RateLimitCounter `json:"users"`
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *CommandStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, err := auth(r)
+	if err != nil {
+		errorHandler(w, "Authentication failed", err)
+		return
+	}
+
+	response := CommandStatsResponse{}
+	response.Commands, response.Users = handler.Server.RateLimiter.Counters()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonHandler(w, response)
+}
@@ -0,0 +1,56 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// http_history.go contains the API endpoint returning the most recent entries
+// of the audit log.
+//
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HistoryDefaultHTTPCount is the number of entries returned by default.
+const HistoryDefaultHTTPCount = 100
+
+// HistoryHandler is the HTTP Handler for the audit log.  The number of
+// entries can be given with the "n" parameter and filtered by "channel".
+type HistoryHandler struct {
+	*Server
+}
+
+// HistoryResponse is the struct returned as JSON in response to a request on
+// this endpoint, entries are sorted from the most recent.
+type HistoryResponse struct {
+	Entries []*AuditEntry `json:"entries"`
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, err := auth(r)
+	if err != nil {
+		errorHandler(w, "Authentication failed", err)
+		return
+	}
+
+	count := HistoryDefaultHTTPCount
+	if value := r.FormValue("n"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			http.Error(w, "'n' must be a positive integer", 400)
+			return
+		}
+	}
+
+	response := HistoryResponse{
+		Entries: handler.Server.AuditLog.Recent(count,
+			r.FormValue("channel")),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonHandler(w, response)
+}
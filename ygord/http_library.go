@@ -0,0 +1,148 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// http_library.go contains the endpoints of the media library: the upload,
+// the list of files and the files themselves, served to the minions.
+//
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// LibraryListHandler is the HTTP Handler for the list of library files.
+type LibraryListHandler struct {
+	*Server
+}
+
+// LibraryListResponse is the struct returned as JSON in response to a request
+// on this endpoint.
+type LibraryListResponse struct {
+	Items []*LibraryItem `json:"items"`
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *LibraryListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, err := auth(r)
+	if err != nil {
+		errorHandler(w, "Authentication failed", err)
+		return
+	}
+
+	response := LibraryListResponse{Items: []*LibraryItem{}}
+	if handler.Library != nil {
+		response.Items = handler.Library.List()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonHandler(w, response)
+}
+
+// LibraryUploadHandler is the HTTP Handler adding a file to the library,
+// expecting a multipart form with a "name" and a "file".
+type LibraryUploadHandler struct {
+	*Server
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *LibraryUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, err := auth(r)
+	if err != nil {
+		errorHandler(w, "Authentication failed", err)
+		return
+	}
+
+	if user == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ygord"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if handler.Library == nil {
+		http.Error(w, "the media library is not configured",
+			http.StatusNotFound)
+		return
+	}
+
+	limit := handler.Library.MaxFileSize + LibraryUploadOverhead
+	if r.ContentLength > limit {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "file too large",
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	name := r.FormValue("name")
+	item, err := handler.Library.Add(name, user, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handler.Logf(SeverityInfo, "%s uploaded %s%s to the library", user,
+		LibraryScheme, item.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonHandler(w, item)
+}
+
+// LibraryFileHandler is the HTTP Handler serving the files of the library, by
+// hash (e.g. /library/file/<hash>).
+type LibraryFileHandler struct {
+	*Server
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *LibraryFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, LibraryFilePath)
+	if handler.Library == nil || !isLibraryHash(hash) {
+		http.NotFound(w, r)
+		return
+	}
+
+	item, ok := handler.Library.GetByHash(hash)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(handler.Library.Path(hash))
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		errorHandler(w, "library error", err)
+		return
+	}
+	defer f.Close()
+
+	// The content of a hash never changes.
+	setMediaFileHeaders(w, item.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000")
+
+	http.ServeContent(w, r, "", item.Time, f)
+}
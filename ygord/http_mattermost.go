@@ -2,13 +2,12 @@
 // Use of this source code is governed by the ISC license in the LICENSE file.
 //
 // http_mattermost.go contains the API endpoint ingesting mattermost outgoing
-// webhook requests.
+// webhook and slash command requests.
 //
 
 package main
 
 import (
-	"encoding/json"
 	"net/http"
 )
 
@@ -17,19 +16,6 @@ type MattermostHandler struct {
 	*Server
 }
 
-func (handler *MattermostHandler) ReplyToMattermost(w http.ResponseWriter, channel, text string) {
-	response := handler.Server.NewMattermostResponse(channel, text)
-
-	w.Header().Set("Content-Type", "application/json")
-
-	encoder := json.NewEncoder(w)
-	err := encoder.Encode(response)
-	if err != nil {
-		errorHandler(w, "failed to encode response JSON", err)
-		return
-	}
-}
-
 // ServeHTTP is a standard handler ServeHTTP request as expected by the
 // standard http library.
 func (handler *MattermostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -54,13 +40,24 @@ func (handler *MattermostHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 
 	srv := handler.Server
 
-	token := r.Form.Get("token")
-	if token != srv.Config.MattermostToken {
+	// Slash commands have a "command" field (e.g. "/ygor"), outgoing
+	// webhooks don't.  Each has its own token, which identifies the
+	// integration.
+	slash := r.Form.Get("command") != ""
+	integration := srv.Config.GetMattermostIntegrationByToken(
+		r.Form.Get("token"), slash)
+	if integration == nil {
 		errorHandler(w, "invalid Mattermost token", err)
 		return
 	}
 
-	msgs := srv.NewMessagesFromMattermostRequest(r)
+	var msgs []*InputMessage
+	if slash {
+		msgs = srv.NewMessagesFromMattermostSlashCommand(integration, r)
+	} else {
+		msgs = srv.NewMessagesFromMattermostRequest(integration, r)
+	}
+
 	for _, msg := range msgs {
 		srv.InputQueue <- msg
 	}
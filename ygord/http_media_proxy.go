@@ -0,0 +1,63 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// http_media_proxy.go contains the endpoint serving the files of the media
+// proxy to the minions.
+//
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// MediaProxyHandler is the HTTP Handler serving the files of the media proxy,
+// by key (e.g. /media/proxy/<key>).
+type MediaProxyHandler struct {
+	*Server
+}
+
+// ServeHTTP is a standard handler ServeHTTP request as expected by the
+// standard http library.
+func (handler *MediaProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, MediaProxyPath)
+	if handler.MediaProxy == nil || !isMediaProxyKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, f, err := handler.MediaProxy.Open(key)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		handler.Warningf("media proxy error for %s: %s", key, err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		errorHandler(w, "media proxy error", err)
+		return
+	}
+
+	setMediaFileHeaders(w, file.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	// ServeContent handles the ranges used by the players to seek.
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// setMediaFileHeaders sets the headers of the files served to the minions.
+// The browsers must not guess another type than the one we checked, and a
+// file opened directly (e.g. an SVG image) must not be able to run scripts
+// on our origin.
+func setMediaFileHeaders(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+}
@@ -0,0 +1,307 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the media library: images, audio and video files
+// uploaded to ygord and played with the "lib:" URL scheme (e.g. "play
+// lib:airhorn"), so that the important ones do not depend on a remote host.
+// Files are stored under the hash of their content, an index maps the names
+// to the files.
+//
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LibraryScheme is the scheme of the URLs of the library files.
+	LibraryScheme = "lib:"
+
+	// LibraryFilePath is the path of the HTTP endpoint serving the files,
+	// followed by their hash.
+	LibraryFilePath = "/library/file/"
+
+	// LibraryUploadOverhead is the room left for the rest of the upload
+	// form on top of the size of the file.
+	LibraryUploadOverhead = 64 * 1024
+)
+
+var (
+	reLibraryName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+	reLibraryHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	// Formats of the files accepted in the library.
+	libraryFormats = []string{"img", "audio", "video"}
+)
+
+// LibraryItem is a named file of the library.
+type LibraryItem struct {
+	Name        string    `json:"name"`
+	Hash        string    `json:"hash"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Uploader    string    `json:"uploader"`
+	Time        time.Time `json:"time"`
+}
+
+// Library stores the uploaded files in Dir, files larger than MaxFileSize
+// are rejected.
+type Library struct {
+	sync.Mutex
+
+	Dir         string
+	MaxFileSize int64
+
+	// BaseURL is the prefix of the URLs given to the minions, defaults to
+	// the LibraryFilePath of the ygord serving them.
+	BaseURL string
+
+	items map[string]*LibraryItem
+}
+
+// OpenLibrary opens (or creates) the library stored in dir.
+func OpenLibrary(dir string, maxFileSize int64, baseURL string) (*Library, error) {
+	library := &Library{
+		Dir:         dir,
+		MaxFileSize: maxFileSize,
+		BaseURL:     baseURL,
+		items:       make(map[string]*LibraryItem),
+	}
+
+	if library.BaseURL == "" {
+		library.BaseURL = LibraryFilePath
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(library.indexPath())
+	if os.IsNotExist(err) {
+		return library, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &library.items); err != nil {
+		return nil, errors.New("library index: " + err.Error())
+	}
+
+	return library, nil
+}
+
+// indexPath returns the path of the file mapping the names to the files.
+func (library *Library) indexPath() string {
+	return filepath.Join(library.Dir, "index.json")
+}
+
+// Path returns the path of the file with the given hash.
+func (library *Library) Path(hash string) string {
+	return filepath.Join(library.Dir, hash)
+}
+
+// save writes the index of the library.
+func (library *Library) save() error {
+	data, err := json.MarshalIndent(library.items, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := library.indexPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, library.indexPath())
+}
+
+// libraryFormat returns the format of the files with the given Content-Type,
+// or an empty string if they are not accepted in the library.
+func libraryFormat(contentType string) string {
+	for _, format := range libraryFormats {
+		for _, mediaType := range supportedFormatsAndTypes[format] {
+			if contentType == mediaType {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// Add stores a file under the given name, replacing the previous file with
+// this name if any.  The Content-Type is always detected from the content,
+// the one declared by the uploader is not trusted since the files are served
+// back with it.
+func (library *Library) Add(name, uploader string, r io.Reader) (*LibraryItem, error) {
+	if !reLibraryName.MatchString(name) {
+		return nil, errors.New("invalid name (expected lowercase " +
+			"letters, digits, '.', '_' and '-')")
+	}
+
+	tmp, err := ioutil.TempFile(library.Dir, "upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash),
+		io.LimitReader(r, library.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > library.MaxFileSize {
+		return nil, errors.New("file too large")
+	}
+	if size == 0 {
+		return nil, errors.New("empty file")
+	}
+
+	head := make([]byte, MediaSniffSize)
+	n, _ := tmp.ReadAt(head, 0)
+	contentType := sniffContentType(head[:n])
+	if libraryFormat(contentType) == "" {
+		return nil, errors.New("unsupported content-type (" +
+			contentType + ")")
+	}
+
+	item := &LibraryItem{
+		Name:        name,
+		Hash:        hex.EncodeToString(hash.Sum(nil)),
+		ContentType: contentType,
+		Size:        size,
+		Uploader:    uploader,
+		Time:        time.Now().UTC(),
+	}
+
+	library.Lock()
+	defer library.Unlock()
+
+	if err := os.Rename(tmp.Name(), library.Path(item.Hash)); err != nil {
+		return nil, err
+	}
+
+	previous := library.items[name]
+	library.items[name] = item
+	if previous != nil {
+		library.collect(previous.Hash)
+	}
+
+	return item, library.save()
+}
+
+// Delete removes the file with the given name, returning false if there is
+// none.
+func (library *Library) Delete(name string) (bool, error) {
+	library.Lock()
+	defer library.Unlock()
+
+	item, ok := library.items[name]
+	if !ok {
+		return false, nil
+	}
+
+	delete(library.items, name)
+	library.collect(item.Hash)
+
+	return true, library.save()
+}
+
+// collect removes the file with the given hash if no name refers to it
+// anymore.
+func (library *Library) collect(hash string) {
+	for _, item := range library.items {
+		if item.Hash == hash {
+			return
+		}
+	}
+	os.Remove(library.Path(hash))
+}
+
+// Get returns the file with the given name.
+func (library *Library) Get(name string) (*LibraryItem, bool) {
+	library.Lock()
+	defer library.Unlock()
+
+	item, ok := library.items[name]
+	return item, ok
+}
+
+// GetByHash returns a file with the given hash.
+func (library *Library) GetByHash(hash string) (*LibraryItem, bool) {
+	library.Lock()
+	defer library.Unlock()
+
+	for _, item := range library.items {
+		if item.Hash == hash {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// List returns all the files, sorted by name.
+func (library *Library) List() []*LibraryItem {
+	library.Lock()
+	defer library.Unlock()
+
+	items := []*LibraryItem{}
+	for _, item := range library.items {
+		items = append(items, item)
+	}
+	sort.Sort(libraryItemsByName(items))
+
+	return items
+}
+
+type libraryItemsByName []*LibraryItem
+
+func (items libraryItemsByName) Len() int           { return len(items) }
+func (items libraryItemsByName) Swap(i, j int)      { items[i], items[j] = items[j], items[i] }
+func (items libraryItemsByName) Less(i, j int) bool { return items[i].Name < items[j].Name }
+
+// isLibraryHash returns true if the value looks like the hash of a library
+// file, as found in the URLs of the files.
+func isLibraryHash(value string) bool {
+	return reLibraryHash.MatchString(value)
+}
+
+// isLibraryURL returns true if the URL refers to a file of the library (e.g.
+// "lib:airhorn").
+func isLibraryURL(link string) bool {
+	return strings.HasPrefix(link, LibraryScheme)
+}
+
+// setLibrarySrc points the media to the file of the library with the given
+// name.
+func (media *Media) setLibrarySrc(name string) error {
+	if media.srv == nil || media.srv.Library == nil {
+		return errors.New("the media library is not configured")
+	}
+
+	item, ok := media.srv.Library.Get(name)
+	if !ok {
+		return errors.New("no such file in the library: " + name)
+	}
+
+	media.Src = media.srv.Library.BaseURL + item.Hash
+	media.Format = libraryFormat(item.ContentType)
+	media.mediaType = item.ContentType
+	media.metadata.Title = item.Name
+
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testGIF = "GIF89a...."
+
+func newTestLibrary(t *testing.T) (*Library, func()) {
+	dir, err := ioutil.TempDir("", "ygord-library")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	library, err := OpenLibrary(dir, 100, "")
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return library, func() { os.RemoveAll(dir) }
+}
+
+func TestLibraryAdd(t *testing.T) {
+	library, cleanup := newTestLibrary(t)
+	defer cleanup()
+
+	// The Content-Type is detected from the content.
+	item, err := library.Add("cat", "alice", strings.NewReader(testGIF))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "cat", item.Name)
+		assert.Equal(t, "image/gif", item.ContentType)
+		assert.Equal(t, int64(10), item.Size)
+		assert.Equal(t, "alice", item.Uploader)
+		assert.Equal(t, mediaProxyKey(testGIF), item.Hash)
+	}
+
+	// Identical files are stored once.
+	_, err = library.Add("kitty", "bob", strings.NewReader(testGIF))
+	assert.Nil(t, err)
+	paths, _ := ioutil.ReadDir(library.Dir)
+	assert.Len(t, paths, 2) // index.json and the file
+
+	// The previous file is removed once no name refers to it.
+	_, err = library.Add("cat", "alice", strings.NewReader("ID3..."))
+	assert.Nil(t, err)
+	_, err = os.Stat(library.Path(mediaProxyKey(testGIF)))
+	assert.Nil(t, err)
+	ok, err := library.Delete("kitty")
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	_, err = os.Stat(library.Path(mediaProxyKey(testGIF)))
+	assert.True(t, os.IsNotExist(err))
+
+	items := library.List()
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "cat", items[0].Name)
+		assert.Equal(t, "audio/mpeg", items[0].ContentType)
+	}
+
+	// The index survives a restart.
+	reopened, err := OpenLibrary(library.Dir, 100, "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, items, reopened.List())
+	}
+}
+
+func TestLibraryAddRejects(t *testing.T) {
+	library, cleanup := newTestLibrary(t)
+	defer cleanup()
+
+	for _, test := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"Cat", testGIF, "invalid name (expected lowercase letters, digits, '.', '_' and '-')"},
+		{"../cat", testGIF, "invalid name (expected lowercase letters, digits, '.', '_' and '-')"},
+		{"cat", "<html></html>", "unsupported content-type (text/html)"},
+		{"cat", "<svg onload=\"alert(1)\"/>", "unsupported content-type (text/plain)"},
+		{"cat", "<?xml version=\"1.0\"?><svg/>", "unsupported content-type (text/xml)"},
+		{"cat", strings.Repeat("GIF89a", 100), "file too large"},
+		{"cat", "", "empty file"},
+	} {
+		_, err := library.Add(test.name, "alice",
+			strings.NewReader(test.content))
+		if assert.NotNil(t, err, test.name) {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+
+	assert.Empty(t, library.List())
+	paths, _ := ioutil.ReadDir(library.Dir)
+	assert.Empty(t, paths)
+}
+
+func newLibraryUploadRequest(user, name, content string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("name", name)
+	part, _ := writer.CreateFormFile("file", name+".gif")
+	part.Write([]byte(content))
+	writer.Close()
+
+	r, _ := http.NewRequest("POST", "/library/upload", body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	if user != "" {
+		r.SetBasicAuth(user, "secret")
+	}
+	return r
+}
+
+func TestLibraryUploadHandler(t *testing.T) {
+	library, cleanup := newTestLibrary(t)
+	defer cleanup()
+
+	srv := CreateTestServer()
+	srv.Library = library
+	handler := &LibraryUploadHandler{srv}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newLibraryUploadRequest("", "cat", testGIF))
+	assert.Equal(t, 401, w.Code)
+	assert.Empty(t, library.List())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newLibraryUploadRequest("alice", "cat", "nope"))
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "unsupported content-type (text/plain)\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newLibraryUploadRequest("alice", "cat", testGIF))
+	assert.Equal(t, 200, w.Code)
+	item := &LibraryItem{}
+	if assert.Nil(t, json.Unmarshal(w.Body.Bytes(), item)) {
+		assert.Equal(t, "cat", item.Name)
+		assert.Equal(t, "alice", item.Uploader)
+	}
+
+	// The file is then served by hash.
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", LibraryFilePath+item.Hash, nil)
+	(&LibraryFileHandler{srv}).ServeHTTP(w, r)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "default-src 'none'; sandbox",
+		w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, testGIF, w.Body.String())
+
+	for _, hash := range []string{mediaProxyKey("nope"), "../index.json",
+		strings.ToUpper(item.Hash)} {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", LibraryFilePath+hash, nil)
+		(&LibraryFileHandler{srv}).ServeHTTP(w, r)
+		assert.Equal(t, 404, w.Code, hash)
+	}
+}
+
+func TestLibraryUploadHandlerTooLarge(t *testing.T) {
+	library, cleanup := newTestLibrary(t)
+	defer cleanup()
+
+	srv := CreateTestServer()
+	srv.Library = library
+	handler := &LibraryUploadHandler{srv}
+	content := strings.Repeat("GIF89a", LibraryUploadOverhead)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newLibraryUploadRequest("alice", "cat", content))
+	assert.Equal(t, 413, w.Code)
+
+	// Without Content-Length, the body is cut once the limit is reached.
+	r := newLibraryUploadRequest("alice", "cat", content)
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, 413, w.Code)
+
+	assert.Empty(t, library.List())
+}
+
+func TestModulePlayLibrary(t *testing.T) {
+	library, cleanup := newTestLibrary(t)
+	defer cleanup()
+
+	srv := CreateTestServer()
+	srv.Library = library
+	client := srv.RegisterClient("dummy", "test")
+	(&PlayModule{}).Init(srv)
+
+	item, err := library.Add("airhorn", "alice", strings.NewReader("ID3..."))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, name := range []string{"airhorn", "nope"} {
+		srv.RunCommand("play", &InputMessage{
+			Adapter: "test",
+			ReplyTo: "#test",
+			Args:    []string{"lib:" + name},
+		})
+	}
+	srv.FlushMediaQueue()
+
+	if queue := client.FlushQueue(); assert.Len(t, queue, 1) {
+		media := queue[0].Data.(*Media)
+		assert.Equal(t, LibraryFilePath+item.Hash, media.Src)
+		assert.Equal(t, "audio", media.Format)
+		assert.Equal(t, "library", media.GetSource())
+	}
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "now playing: airhorn", msgs[0].Body)
+		assert.Equal(t, "no such file in the library: nope", msgs[1].Body)
+	}
+}
@@ -5,6 +5,9 @@ package main
 
 import (
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -14,12 +17,22 @@ func main() {
 		log.Fatal("config error: ", err.Error())
 	}
 
+	policy, err := NewOutboundPolicy(cfg.OutboundAllowlist)
+	if err != nil {
+		log.Fatal("outbound policy error: ", err.Error())
+	}
+	SetOutboundPolicy(policy)
+
 	srv := CreateServer(cfg)
 
 	log.Printf("registering modules")
+	srv.RegisterModule(&AdminModule{})
 	srv.RegisterModule(&AliasModule{})
 	srv.RegisterModule(&CommandsModule{})
+	srv.RegisterModule(&HelpModule{})
+	srv.RegisterModule(&HistoryModule{})
 	srv.RegisterModule(&ImageModule{})
+	srv.RegisterModule(&MediaCacheModule{})
 	srv.RegisterModule(&RebootModule{})
 	srv.RegisterModule(&NopModule{})
 	srv.RegisterModule(&PingModule{})
@@ -30,58 +43,66 @@ func main() {
 	srv.RegisterModule(&ShutUpModule{})
 	srv.RegisterModule(&VolumeModule{})
 
+	log.Printf("registering media resolvers")
+	srv.RegisterResolver(&GfycatResolver{})
+	srv.RegisterResolver(&ImgurResolver{})
+	srv.RegisterResolver(&SoundCloudResolver{ClientID: cfg.SoundCloudClientID})
+	srv.RegisterResolver(&VimeoResolver{})
+	srv.RegisterResolver(&YouTubeResolver{})
+
+	log.Printf("registering chat adapters")
+	srv.RegisterChatAdapter(&DiscordAdapter{})
+	srv.RegisterChatAdapter(&IRCAdapter{})
+	srv.RegisterChatAdapter(&MatrixAdapter{})
+	srv.RegisterChatAdapter(&MattermostAdapter{})
+	if cmdline.Console {
+		srv.RegisterChatAdapter(&ConsoleAdapter{
+			Input:    os.Stdin,
+			Output:   os.Stdout,
+			Nickname: cmdline.ConsoleNickname,
+			Channel:  cmdline.ConsoleChannel,
+		})
+	}
+
+	srv.AliasChecker.Start(srv)
+
 	err = srv.StartHTTPServer(cfg.HTTPServerAddress)
 	if err != nil {
 		log.Fatal("failed to start http server: ", err.Error())
 	}
 
-	if cfg.IRCServer != "" {
-		err = srv.StartIRCClient()
-		if err != nil {
-			log.Fatal("failed to start IRC client: ", err.Error())
-		}
+	err = srv.StartChatAdapters()
+	if err != nil {
+		log.Fatal("failed to start chat adapter: ", err.Error())
 	}
 
 	go waitForTraceRequest()
 
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
 	log.Printf("ready, entering main loop")
 	for {
 		select {
 		case msg := <-srv.InputQueue:
 			log.Printf("chat in  %s <%s> %s", msg.ReplyTo,
 				msg.Nickname, msg.Body)
-			switch msg.Type {
-			case InputMsgTypeIRCChannel:
-				srv.IRCMessageHandler(msg)
-			case InputMsgTypeIRCPrivate:
-				srv.IRCMessageHandler(msg)
-			case InputMsgTypeMattermost:
-				srv.IRCMessageHandler(msg)
-			case InputMsgTypeScreensaver:
-				srv.IRCMessageHandler(msg)
-			default:
-				log.Printf("main loop: un-handled "+
-					"input message type"+
-					" '%d'", msg.Type)
-			}
+			srv.IRCMessageHandler(msg)
 		case msg := <-srv.OutputQueue:
 			log.Printf("chat out %s <%s> %s", msg.Channel,
 				cfg.Nickname, msg.Body)
-			switch msg.Type {
-			case OutputMsgTypePrivMsg:
-				conn.Privmsg(msg.Channel, msg.Body)
-			case OutputMsgTypeAction:
-				conn.Action(msg.Channel, msg.Body)
-			case OutputMsgTypeMattermost:
-				srv.SendToMattermost(srv.NewMattermostResponse(msg.Channel,
-					msg.Body))
-			case OutputMsgTypeScreensaver:
-				// nothing to do
-			default:
-				log.Printf("main loop: un-handled "+
-					"output message type"+
-					" '%d'", msg.Type)
+			srv.SendToChatAdapter(msg)
+		case channel := <-srv.MediaQueue.Ready:
+			srv.MediaQueue.Deliver(channel)
+		case sig := <-quit:
+			log.Printf("received %s, stopping chat adapters", sig)
+			srv.MediaQueue.Cancel("")
+			srv.StopChatAdapters()
+			srv.AuditLog.Close()
+			if err := srv.MediaCache.Save(); err != nil {
+				log.Printf("media cache error: %s", err.Error())
 			}
+			return
 		}
 	}
 }
@@ -4,59 +4,18 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"path"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
-	// These are the known domains to check for, where special formatting of
-	// the passed URL is required so connected minions can most effectively
-	// embed and manipulate the desired content.
-	imgurHostNames = []string{
-		"i.imgur.com",
-		"www.imgur.com",
-		"imgur.com",
-	}
-	gfycatHostNames = []string{
-		"gfycat.com",
-		"www.gfycat.com",
-		"zippy.gfycat.com",
-		"fat.gfycat.com",
-		"giant.gfycat.com",
-		"center.gfycat.com",
-		"center2.gfycat.com",
-		"centre.gfycat.com",
-		"test.gfycat.com",
-		"upload.gfycat.com",
-	}
-	youtubeHostNames = []string{
-		"www.youtube.com",
-		"www.youtu.be",
-		"youtube.com",
-		"youtu.be",
-	}
-	vimeoHostNames = []string{
-		"vimeo.com",
-		"www.vimeo.com",
-		"player.vimeo.com",
-		"www.player.vimeo.com",
-	}
-	soundcloudHostNames = []string{
-		"soundcloud.com",
-		"www.soundcloud.com",
-		"api.soundcloud.com",
-		"www.api.soundcloud.com",
-	}
-
 	supportedFormatsAndTypes = map[string][]string{
 		"img": {
 			"image/bmp",
@@ -163,30 +122,6 @@ var (
 			".webm",
 		},
 	}
-
-	reYTVideoID = regexp.MustCompile(
-		`^.*(youtu.be\/|v\/|u\/\w\/|embed\/|watch\?v=|\&v=)([^#\&\?]*).*`)
-
-	// Reserved private IP address ranges
-	privIPRanges = map[string]map[string][]byte{
-		"RFC 1968 A": {
-			"low": net.ParseIP("10.0.0.0"),
-			"high": net.ParseIP("10.255.255.255"),
-		},
-		"RFC 1968 B": {
-			"low": net.ParseIP("172.16.0.0"),
-			"high": net.ParseIP("172.31.255.255"),
-		},
-		"RFC 1968 C": {
-			"low": net.ParseIP("192.168.0.0"),
-			"high": net.ParseIP("192.168.255.255"),
-		},
-		"RFC 6958": {
-			"low": net.ParseIP("100.64.0.0"),
-			"high": net.ParseIP("100.127.255.255"),
-		},
-	}
-
 )
 
 // Media represents the relevant data that will eventually be passed to
@@ -202,12 +137,17 @@ type Media struct {
 	Src  string `json:"src"`
 	url  string
 	host string
+	// metadata describes the content when known (e.g. SoundCloud track
+	// title), see GetTitle().
+	metadata MediaMetadata
 	// 'Format' tells the connected minions how to embed the desired content
 	// using 'Src'.
 	Format    string `json:"format"`
 	mediaType string
-	// End represents where in the desired content's timeline to stop playing.
-	End string `json:"end"`
+	// Start and End represent where in the desired content's timeline to
+	// start and stop playing, in seconds.
+	Start string `json:"start"`
+	End   string `json:"end"`
 	// Muted represents whether or not the desired content should be muted.
 	Muted bool `json:"muted"`
 	Loop  bool `json:"loop"`
@@ -216,6 +156,13 @@ type Media struct {
 	// will be checked against during SetSrc. If the determined media type
 	// is not acceptable, the url will be rejected.
 	acceptableFormats []string
+	// resolver is the Resolver that handled this URL, if any.
+	resolver Resolver
+	// ctx is the context of the resolution, see NewMediaWithContext.
+	ctx context.Context
+	// refresh ignores the cached resolution of the URL, and only replaces
+	// it if this one succeeds, see probeMedia.
+	refresh bool
 	// srv provides easy access to the Server, just in case.
 	srv *Server
 }
@@ -237,17 +184,16 @@ func (media *Media) checkFormatIsAcceptable() error {
 	}
 
 	// If it made it here, the determined media type must not be acceptable.
-	errMsg := "error: content-type (" + media.mediaType + ") not supported " +
+	errMsg := "content-type (" + media.mediaType + ") not supported " +
 		"by this command"
 	return errors.New(errMsg)
 }
 
 // SetSrc takes in a string that represents a URL. This function determines if
-// the URL is a valid URL, formats imgur links to use .mp4 instead of .gif(v),
-// and determines the Format that the URL represents.
+// the URL is a valid URL and determines the Format that the URL represents.
 //
 // The Media's 'Src' attribute will either be set to the passed URL, or the
-// formatted imgur URL (if it was an imgur link).
+// URL formatted by the Resolver handling it (e.g. a YouTube video ID).
 //
 // The Media's 'Src' attribute can be retrieved using the Media's
 // 'GetSrc()' function.
@@ -257,7 +203,7 @@ func (media *Media) checkFormatIsAcceptable() error {
 func (media *Media) SetSrc(link string) error {
 	uri, linkErr := url.ParseRequestURI(link)
 	if linkErr != nil {
-		errorMsg := "error: not a valid URL"
+		errorMsg := "not a valid URL"
 		return errors.New(errorMsg)
 	}
 	// Strip any query or fragment attached to the URL
@@ -265,23 +211,36 @@ func (media *Media) SetSrc(link string) error {
 	media.url = link
 	media.host = uri.Host
 
-	var header map[string][]string
+	// Files of the library are known, no need to query or cache them.
+	if isLibraryURL(link) {
+		if err := media.setLibrarySrc(uri.Opaque); err != nil {
+			return err
+		}
+	} else if err := media.resolve(); err != nil {
+		return err
+	}
+
+	merr := media.checkFormatIsAcceptable()
+	if merr != nil {
+		return merr
+	}
+
+	return nil
+}
+
+// resolveURL queries the host of the URL to determine its Format, then looks
+// up its metadata.
+func (media *Media) resolveURL() error {
+	var header http.Header
 
 	if !hostIsPrivateIP(media.host) {
-		// Check that the URL returns a status code of 200.
-		res, err := http.Head(media.Src)
+		var err error
+		header, err = media.fetchHeader()
 		if err != nil {
-			errMsg := "error: " + err.Error()
-			return errors.New(errMsg)
-		}
-		statusCode := strconv.Itoa(res.StatusCode)
-		if statusCode != "200" {
-			errMsg := "error: response status code is " + statusCode
-			return errors.New(errMsg)
+			return err
 		}
-		header = res.Header
 	} else {
-		header = map[string][]string{
+		header = http.Header{
 			"Content-Type": {
 				"unknown",
 			},
@@ -293,31 +252,7 @@ func (media *Media) SetSrc(link string) error {
 		return headErr
 	}
 
-	// If it's an imgur link, and the content-type contains "image/gif", modify
-	// the Media so minions embed the far more efficient mp4 version.
-	if media.isImgur() {
-		isGIF := strings.Contains(strings.ToLower(media.mediaType), "image/gif")
-		hasGIFVExt := media.GetExt() == ".gifv"
-		if (isGIF || hasGIFVExt) && media.hasMP4() {
-			media.replaceSrcExt(".mp4")
-			media.Format = "video"
-			media.mediaType = "video/mp4"
-		}
-	}
-
-	// If it's a Gfycat link, and the content-type isn't "video/mp4", attempt
-	// to find a mp4 version of this link using the Gfycat API.
-	if media.isGfycat() {
-		isMP4 := strings.Contains(strings.ToLower(media.mediaType), "video/mp4")
-		if !isMP4 {
-			media.resolveGfycatURL()
-		}
-	}
-
-	merr := media.checkFormatIsAcceptable()
-	if merr != nil {
-		return merr
-	}
+	media.fetchMetadata()
 
 	return nil
 }
@@ -334,82 +269,81 @@ func (media *Media) GetURL() string {
 	return media.url
 }
 
-// hostIsPrivateIP checks if the passed host is an IP address from a private IP
-// address range. If so, it returns true. Otherwise, it returns false.
-// 
-// This function is useful for determining if ygor can make a request at all to
-// the passed URL.
-func hostIsPrivateIP(host string) bool {
-	// Strip the port if there is one.
-	portIndex := strings.Index(host, ":")
-	if portIndex != -1 {
-		// There is a port.
-		host = host[:portIndex]
+// GetTitle returns the title of the content if it was resolved, otherwise the
+// name of the file in the original URL.
+func (media *Media) GetTitle() string {
+	if media.metadata.Title != "" {
+		return media.metadata.Title
 	}
-	ip := net.ParseIP(host)
-	if ip.To4() == nil {
-		// This is not an IPv4 address.
-		return false
+
+	uri, err := url.Parse(media.url)
+	if err != nil || path.Base(uri.Path) == "/" || path.Base(uri.Path) == "." {
+		return media.url
 	}
-	for _, IPRange := range privIPRanges {
-		if bytes.Compare(ip, IPRange["low"]) >= 0 && bytes.Compare(ip, IPRange["high"]) <= 0 {
-			// This is an IP address that is in a private range.
-			return true
-		}
+
+	return path.Base(uri.Path)
+}
+
+// GetArtist returns the author of the content, or an empty string if it is
+// unknown.
+func (media *Media) GetArtist() string {
+	return media.metadata.Artist
+}
+
+// GetDuration returns the length of the content, or zero if it is unknown.
+func (media *Media) GetDuration() time.Duration {
+	return media.metadata.Duration
+}
+
+// GetSource returns a human readable name of the service hosting the content,
+// or its host name if it is not a known service.
+func (media *Media) GetSource() string {
+	if media.resolver != nil {
+		return media.resolver.Name()
 	}
-	return false
+	if isLibraryURL(media.url) {
+		return "library"
+	}
+	return media.host
+}
+
+// GetThumbnail returns the URL of an image representing the content, or an
+// empty string if there is none.
+func (media *Media) GetThumbnail() string {
+	if media.metadata.Thumbnail != "" {
+		return media.metadata.Thumbnail
+	}
+
+	switch media.Format {
+	case "youtube":
+		return "https://img.youtube.com/vi/" + media.Src + "/default.jpg"
+	case "img":
+		return media.Src
+	}
+	return ""
 }
 
 // setFormat sets the 'Format' attribute of the Media. This tells the
 // connected minions what kind of content they should be trying to embed.
-func (media *Media) setFormat(header map[string][]string) error {
-	// If it's a YouTube link, check if there's a video ID we can grab.
-	if media.isYouTube() {
-		match := reYTVideoID.FindAllStringSubmatch(media.Src, -1)
-		if len(match) > 0 {
-			media.Src = match[0][2]
-			media.Format = "youtube"
-			media.mediaType = "youtube"
-			return nil
-		}
+//
+// If a Resolver handles this URL, it is in charge of setting the format,
+// otherwise it is determined from the header.
+func (media *Media) setFormat(header http.Header) error {
+	if media.srv != nil {
+		media.resolver = media.srv.GetResolver(media)
 	}
 
-	// If it's a Vimeo link, check if there's a video ID we can grab.
-	if media.isVimeo() {
-		// Vimeo video IDs are the last element in the URL (represented as an
-		// integer between 6 and 11 digits long) before the query string and/or
-		// fragment. media.Src has the query string and fragment stripped off,
-		// so if this is a link to a Vimeo video, potentialVideoID should be an
-		// integer between 6 and 11 digits long.
-		potentialVideoID := path.Base(media.Src)
-		// Check to see if it is between 6 and 11 characters long.
-		if 6 <= len(potentialVideoID) && len(potentialVideoID) <= 11 {
-			// Check to make sure it is a number.
-			if _, err := strconv.Atoi(potentialVideoID); err == nil {
-				// It is a number
-				media.Src = potentialVideoID
-				media.Format = "vimeo"
-				media.mediaType = "vimeo"
-				return nil
-			}
-		}
+	if media.resolver != nil {
+		return media.resolver.Resolve(media, header)
 	}
 
-	// If it's a SoundCloud URL, attempt to resolve it and get the link to
-	// embed the song.
-	if media.isSoundCloud() {
-		resolveErr := media.resolveSoundCloudURL()
-		if resolveErr != nil {
-			return resolveErr
-		}
-		if media.GetFormat() == "soundcloud" {
-			// The link was to a SoundCloud track.
-			return nil
-		}
-		// If it isn't a link to a SoundCloud track, continue on and handle the
-		// URL like any other.
-	}
+	return media.setFormatFromHeader(header)
+}
 
+// setFormatFromHeader sets the 'Format' attribute of the Media based on the
+// Content-Type found in the header, or on the extension of the URL if the
+// Content-Type is not recognized.
+func (media *Media) setFormatFromHeader(header http.Header) error {
 	// Is the media type in the contentType an image|audio|video type that
 	// Chromium supports?
 	if contentType, ok := header["Content-Type"]; ok {
@@ -440,14 +374,14 @@ func (media *Media) setFormat(header map[string][]string) error {
 		}
 
 		// If the media type isn't supported, return an error.
-		errMsg := "error: unsupported content-type " +
+		errMsg := "unsupported content-type " +
 			"(" + strings.Join(contentType, ", ") + ")"
-		return errors.New(errMsg)
+		return rejectMedia(errMsg)
 	}
 
 	// It will only get here if it didn't have a content-type in the header.
-	errMsg := "error: no content-type found"
-	return errors.New(errMsg)
+	errMsg := "no content-type found"
+	return rejectMedia(errMsg)
 }
 
 // GetFormat returnes the Media's 'Format' attribute. The 'Format'
@@ -488,188 +422,6 @@ func (media *Media) GetExt() string {
 	return strings.ToLower(path.Ext(cleanSrc))
 }
 
-// isImgur attempts to determine if the desired content is hosted on imgur.
-func (media *Media) isImgur() bool {
-	for _, d := range imgurHostNames {
-		if media.host == d {
-			return true
-		}
-	}
-	return false
-}
-
-// isGfycat attempts to determine if the desired content is hosted on gfycat.
-func (media *Media) isGfycat() bool {
-	for _, d := range gfycatHostNames {
-		if media.host == d {
-			return true
-		}
-	}
-	return false
-}
-
-// isYouTube attempts to determine if the desired content is a video hosted on
-// YouTube
-func (media *Media) isYouTube() bool {
-	for _, d := range youtubeHostNames {
-		if media.host == d {
-			return true
-		}
-	}
-	return false
-}
-
-// isVimeo attempts to determine if the desired content is a video hosted on
-// Vimeo
-func (media *Media) isVimeo() bool {
-	for _, d := range vimeoHostNames {
-		if media.host == d {
-			return true
-		}
-	}
-	return false
-}
-
-// isSoundCloud attempts to determine if the desired content is a song hosted
-// on SoundCloud.
-func (media *Media) isSoundCloud() bool {
-	for _, d := range soundcloudHostNames {
-		if media.host == d {
-			return true
-		}
-	}
-	return false
-}
-
-// hasMP4 checks if there is an mp4 version of the provided URL. It returns
-// true if there is, and false if there isn't.
-func (media *Media) hasMP4() bool {
-	mp4URL := media.Src[0:len(media.Src)-len(media.GetExt())] + ".mp4"
-	// Check that the URL returns a status code of 200.
-	res, err := http.Head(mp4URL)
-	if err != nil {
-		// There likely isn't a mp4 version.
-		return false
-	}
-	statusCode := strconv.Itoa(res.StatusCode)
-	if statusCode != "200" {
-		// There likely isn't an mp4 version.
-		return false
-	}
-	// An mp4 version has been found.
-	return true
-}
-
-// resolveSoundCloudURL attempts to find the track URI of the SoundCloud link
-// that was provided using SoundCloud's API along with the SoundCloudClientID
-// saved in the config file. If it finds a track URI, this is saved as the
-// Media's Src and its Format is set to "soundcloud". If there isn't a track
-// URI, this function does not return an error. Instead, it relies on the
-// function calling it to determne whether or not the Media's Format was set
-// to "soundcloud".
-func (media *Media) resolveSoundCloudURL() error {
-	if media.srv.Config.SoundCloudClientID == "" {
-		errMsg := "error: SoundCloudClientID is not configured"
-		return errors.New(errMsg)
-	}
-	resolveURL := "http://api.soundcloud.com/resolve?url=" + media.Src +
-		"&client_id=" + media.srv.Config.SoundCloudClientID
-	// Make the request.
-	res, err := http.Get(resolveURL)
-	if err != nil {
-		errMsg := "error: " + err.Error()
-		return errors.New(errMsg)
-	}
-	rBody, err := ioutil.ReadAll(res.Body)
-	res.Body.Close()
-	if err != nil {
-		errMsg := "error: malformed SoundCloud API response " +
-			"(could not read all)"
-		return errors.New(errMsg)
-	}
-	var dat map[string]interface{}
-	if err := json.Unmarshal(rBody, &dat); err != nil {
-		errMsg := "error: malformed SoundCloud API response " +
-			"(could not parse)"
-		return errors.New(errMsg)
-	}
-	if kind, hasKind := dat["kind"]; hasKind {
-		if kind == "track" {
-			if trackURI, hasTrackURI := dat["uri"]; hasTrackURI {
-				media.Src = trackURI.(string)
-				media.Format = "soundcloud"
-				media.mediaType = "soundcloud"
-				return nil
-			}
-			// If the API response says the kind is 'track', but there
-			// is no uri, return an error.
-			errMsg := "error: malformed SoundCloud API response " +
-				"(no track uri)"
-			return errors.New(errMsg)
-		}
-	}
-	// If it hasn't returned by now, it isn't a link to a SoundCloud track.
-	return nil
-}
-
-// resolveGfycatURL attempts to find get the URL of the mp4 version of the
-// provided Gfycat URL. If there is no mp4 URL, it falls back to the webm URL.
-// If there is no webm URL, it then falls back to the gif URL. If the Gfycat
-// API doesn't return any JSON to be parsed, then it isn't a link to a Gfycat
-// image/video, so nothing should be changed in the Media.
-func (media *Media) resolveGfycatURL() error {
-	gfyName := path.Base(media.Src)
-	resolveURL := "http://gfycat.com/cajax/get/" + gfyName
-	// Make the request.
-	res, err := http.Get(resolveURL)
-	if err != nil {
-		errMsg := "error: " + err.Error()
-		return errors.New(errMsg)
-	}
-	rBody, err := ioutil.ReadAll(res.Body)
-	res.Body.Close()
-	if err != nil {
-		errMsg := "error: malformed Gfycat API response " +
-			"(could not read all)"
-		return errors.New(errMsg)
-	}
-	var dat map[string]map[string]interface{}
-	if err := json.Unmarshal(rBody, &dat); err != nil {
-		errMsg := "error: malformed Gfycat API response " +
-			"(could not parse)"
-		return errors.New(errMsg)
-	}
-	if gfyItem, hasGfyItem := dat["gfyItem"]; hasGfyItem {
-		if mp4URL, hasMp4URL := gfyItem["mp4Url"]; hasMp4URL {
-			media.Src = mp4URL.(string)
-			media.Format = "video"
-			media.mediaType = "video/mp4"
-			return nil
-		} else if webmURL, hasWebmURL := gfyItem["webmUrl"]; hasWebmURL {
-			// If, for some reason, there isn't an mp4 URL, fallback to the
-			// webm URL.
-			media.Src = webmURL.(string)
-			media.Format = "video"
-			media.mediaType = "video/webm"
-			return nil
-		} else if gifURL, hasGifURL := gfyItem["gifUrl"]; hasGifURL {
-			// If, for some reason, there isn't a webm URL either, fallback to
-			// the gif URL.
-			media.Src = gifURL.(string)
-			media.Format = "image"
-			media.mediaType = "image/gif"
-			return nil
-		}
-		// If the API response says it has a 'gfyItem', but doesn't provide any
-		// URLs related to it, return an error.
-		errMsg := "error: malformed Gfycat API response " +
-			"(no content URLs provided)"
-		return errors.New(errMsg)
-	}
-	// If it hasn't returned by now, it isn't a link to a Gfycat track.
-	return nil
-}
-
 // replaceSrcExt is a convenience function to replace the extension of the
 // Media's current Src.
 func (media *Media) replaceSrcExt(newExt string) {
@@ -694,9 +446,17 @@ func (media *Media) Serialize() string {
 // NewMedia is a convenience function meant to clean up the code of modules.
 // It builds the Media.
 func NewMedia(srv *Server, mediaItem map[string]string, track string, muted bool, loop bool, acceptableFormats []string) (*Media, error) {
+	return NewMediaWithContext(context.Background(), srv, mediaItem, track,
+		muted, loop, acceptableFormats)
+}
+
+// NewMediaWithContext builds the Media like NewMedia, the requests made to
+// resolve it are aborted if the context is canceled.
+func NewMediaWithContext(ctx context.Context, srv *Server, mediaItem map[string]string, track string, muted bool, loop bool, acceptableFormats []string) (*Media, error) {
 	// Parse the mediaItem map into a Media.
 	media := new(Media)
 	media.srv = srv
+	media.ctx = ctx
 	media.End = mediaItem["end"]
 	media.Muted = muted
 	media.Loop = loop
@@ -708,5 +468,21 @@ func NewMedia(srv *Server, mediaItem map[string]string, track string, muted bool
 		return nil, setSrcErr
 	}
 
+	// The start found in the URL (e.g. YouTube "t" parameter) is only
+	// used if none was requested.
+	if mediaItem["start"] != "" {
+		media.Start = mediaItem["start"]
+	} else if media.Start != "" && media.End != "" {
+		start, _ := strconv.ParseFloat(media.Start, 64)
+		end, _ := strconv.ParseFloat(media.End, 64)
+		if end <= start {
+			return nil, errors.New("the end must be after the start")
+		}
+	}
+
+	if srv != nil && srv.MediaProxy != nil {
+		srv.MediaProxy.Rewrite(media)
+	}
+
 	return media, nil
 }
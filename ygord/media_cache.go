@@ -0,0 +1,386 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the cache of the resolved media.  Resolving a URL takes
+// at least one request to its host and often a few more to the API of the
+// provider, the outcome is kept by URL for a while, including the definitive
+// rejections (e.g. 404 or unsupported content-type).  The least recently used
+// entries are dropped first, and the cache is saved to disk in the background
+// to survive restarts.
+//
+
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// MediaCacheSaveDelay is how long the changes to the cache are batched before
+// being saved to disk.
+const MediaCacheSaveDelay = 5 * time.Second
+
+// MediaRejectedError is returned when the host of a media answered but the
+// media cannot be used (e.g. bad status code, unsupported content-type).
+// Unlike the network failures, which could be temporary, these errors are
+// cached.
+type MediaRejectedError struct {
+	Reason string
+}
+
+func (err *MediaRejectedError) Error() string {
+	return err.Reason
+}
+
+// rejectMedia returns a MediaRejectedError with the given reason.
+func rejectMedia(reason string) error {
+	return &MediaRejectedError{Reason: reason}
+}
+
+// MediaCacheEntry is the outcome of the resolution of a URL.
+type MediaCacheEntry struct {
+	URL       string        `json:"url"`
+	Src       string        `json:"src"`
+	Format    string        `json:"format"`
+	MediaType string        `json:"mediaType"`
+	Start     string        `json:"start,omitempty"`
+	Metadata  MediaMetadata `json:"metadata"`
+
+	// Error is the reason the URL was rejected, if it was.
+	Error string `json:"error,omitempty"`
+
+	Expires time.Time `json:"expires"`
+}
+
+// NewMediaCacheEntry records the resolution of the given media, err is the
+// MediaRejectedError returned by the resolution if any.
+func NewMediaCacheEntry(media *Media, err error) *MediaCacheEntry {
+	entry := &MediaCacheEntry{URL: media.url}
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+
+	entry.Src = media.Src
+	entry.Format = media.Format
+	entry.MediaType = media.mediaType
+	entry.Start = media.Start
+	entry.Metadata = media.metadata
+
+	return entry
+}
+
+// Apply restores the resolution of the media from the entry, returning the
+// original error if the URL was rejected.
+func (entry *MediaCacheEntry) Apply(media *Media) error {
+	if entry.Error != "" {
+		return rejectMedia(entry.Error)
+	}
+
+	media.Src = entry.Src
+	media.Format = entry.Format
+	media.mediaType = entry.MediaType
+	media.Start = entry.Start
+	media.metadata = entry.Metadata
+	if media.srv != nil {
+		media.resolver = media.srv.GetResolver(media)
+	}
+
+	return nil
+}
+
+// MediaCacheStats describes the content and usage of the cache.
+type MediaCacheStats struct {
+	Entries  int
+	Failures int
+	Hits     int
+	Misses   int
+}
+
+// MediaCache keeps the most recently used MediaCacheEntry, up to Size.
+// Entries expire after TTL, or NegativeTTL for failures.  The changes are
+// saved SaveDelay after the first one.
+type MediaCache struct {
+	sync.Mutex
+
+	Size        int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	SaveDelay   time.Duration
+
+	// File the entries are saved to, if any.  saveLock serializes the
+	// writes, dirty is set while a save is scheduled.
+	path     string
+	saveLock sync.Mutex
+	dirty    bool
+
+	// Entries by URL, and the same entries from the most recently used to
+	// the least recently used.
+	index   map[string]*list.Element
+	entries *list.List
+
+	hits   int
+	misses int
+}
+
+// NewMediaCache creates an empty MediaCache from its configuration, using
+// the defaults (1000 entries, "24h", "10m") for empty values.
+func NewMediaCache(size int, ttl, negativeTTL string) (*MediaCache, error) {
+	cache := &MediaCache{
+		Size:        1000,
+		TTL:         24 * time.Hour,
+		NegativeTTL: 10 * time.Minute,
+		SaveDelay:   MediaCacheSaveDelay,
+		index:       make(map[string]*list.Element),
+		entries:     list.New(),
+	}
+
+	var err error
+
+	if size < 0 {
+		return nil, errors.New("size must be positive")
+	} else if size > 0 {
+		cache.Size = size
+	}
+
+	if ttl != "" {
+		cache.TTL, err = ParseDuration(ttl)
+		if err != nil {
+			return nil, errors.New("TTL: " + err.Error())
+		}
+	}
+
+	if negativeTTL != "" {
+		cache.NegativeTTL, err = ParseDuration(negativeTTL)
+		if err != nil {
+			return nil, errors.New("negative TTL: " + err.Error())
+		}
+	}
+
+	return cache, nil
+}
+
+// Load reads the entries saved at the given path, the following changes are
+// saved there.  Expired entries are dropped.
+func (cache *MediaCache) Load(path string) error {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries []*MediaCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if now.After(entry.Expires) || cache.entries.Len() >= cache.Size {
+			continue
+		}
+		if _, ok := cache.index[entry.URL]; ok {
+			continue
+		}
+		cache.index[entry.URL] = cache.entries.PushBack(entry)
+	}
+
+	return nil
+}
+
+// Save writes all the entries to the file of the cache, if any.  The cache
+// remains usable while the file is written.
+func (cache *MediaCache) Save() error {
+	cache.saveLock.Lock()
+	defer cache.saveLock.Unlock()
+
+	cache.Lock()
+	path := cache.path
+	entries := []*MediaCacheEntry{}
+	for e := cache.entries.Front(); e != nil; e = e.Next() {
+		entries = append(entries, e.Value.(*MediaCacheEntry))
+	}
+	cache.dirty = false
+	cache.Unlock()
+
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// Write the whole file aside first to never leave a partial cache.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// changed schedules a save of the cache, unless one already is.  The lock
+// must be held.
+func (cache *MediaCache) changed() {
+	if cache.path == "" || cache.dirty {
+		return
+	}
+	cache.dirty = true
+
+	time.AfterFunc(cache.SaveDelay, func() {
+		if err := cache.Save(); err != nil {
+			log.Printf("media cache error: %s", err.Error())
+		}
+	})
+}
+
+// remove drops an entry from the cache.
+func (cache *MediaCache) remove(e *list.Element) {
+	cache.entries.Remove(e)
+	delete(cache.index, e.Value.(*MediaCacheEntry).URL)
+}
+
+// Get returns the entry of the given URL if it has not expired.
+func (cache *MediaCache) Get(now time.Time, link string) (*MediaCacheEntry, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	e, ok := cache.index[link]
+	if ok && now.After(e.Value.(*MediaCacheEntry).Expires) {
+		cache.remove(e)
+		ok = false
+	}
+
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+
+	cache.hits++
+	cache.entries.MoveToFront(e)
+
+	return e.Value.(*MediaCacheEntry), true
+}
+
+// Peek returns the entry of the given URL, expired or not, without affecting
+// the cache.
+func (cache *MediaCache) Peek(link string) (*MediaCacheEntry, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	e, ok := cache.index[link]
+	if !ok {
+		return nil, false
+	}
+
+	return e.Value.(*MediaCacheEntry), true
+}
+
+// Set adds an entry to the cache, replacing the previous one for its URL and
+// dropping the least recently used ones if the cache is full.  Entries must
+// not be modified once added.
+func (cache *MediaCache) Set(now time.Time, entry *MediaCacheEntry) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if entry.Error != "" {
+		entry.Expires = now.Add(cache.NegativeTTL)
+	} else {
+		entry.Expires = now.Add(cache.TTL)
+	}
+
+	if e, ok := cache.index[entry.URL]; ok {
+		cache.remove(e)
+	}
+	cache.index[entry.URL] = cache.entries.PushFront(entry)
+
+	for cache.entries.Len() > cache.Size {
+		cache.remove(cache.entries.Back())
+	}
+
+	cache.changed()
+}
+
+// Flush drops the entry of the given URL, or all the entries if link is
+// empty.  It returns the number of entries dropped.
+func (cache *MediaCache) Flush(link string) int {
+	cache.Lock()
+	defer cache.Unlock()
+
+	count := 0
+	if link == "" {
+		count = cache.entries.Len()
+		cache.index = make(map[string]*list.Element)
+		cache.entries.Init()
+	} else if e, ok := cache.index[link]; ok {
+		cache.remove(e)
+		count = 1
+	}
+
+	if count > 0 {
+		cache.changed()
+	}
+
+	return count
+}
+
+// Stats returns the number of entries and how often the cache was useful.
+func (cache *MediaCache) Stats() MediaCacheStats {
+	cache.Lock()
+	defer cache.Unlock()
+
+	stats := MediaCacheStats{
+		Entries: cache.entries.Len(),
+		Hits:    cache.hits,
+		Misses:  cache.misses,
+	}
+	for e := cache.entries.Front(); e != nil; e = e.Next() {
+		if e.Value.(*MediaCacheEntry).Error != "" {
+			stats.Failures++
+		}
+	}
+
+	return stats
+}
+
+// resolve determines the Format of the media from its URL, using the
+// MediaCache when possible.
+func (media *Media) resolve() error {
+	if media.srv == nil {
+		return media.resolveURL()
+	}
+
+	cache := media.srv.MediaCache
+	if !media.refresh {
+		if entry, ok := cache.Get(time.Now(), media.url); ok {
+			return entry.Apply(media)
+		}
+	}
+
+	err := media.resolveURL()
+
+	// Only the definitive rejections are cached, the next attempt could
+	// succeed after a network failure, a timeout or a cancellation.  A
+	// refresh never replaces an entry with a failure.
+	if _, ok := err.(*MediaRejectedError); err != nil && (!ok || media.refresh) {
+		return err
+	}
+
+	cache.Set(time.Now(), NewMediaCacheEntry(media, err))
+
+	return err
+}
@@ -0,0 +1,175 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaCacheLRU(t *testing.T) {
+	cache, _ := NewMediaCache(2, "1h", "1m")
+	now := time.Now()
+
+	cache.Set(now, &MediaCacheEntry{URL: "http://a/", Format: "img"})
+	cache.Set(now, &MediaCacheEntry{URL: "http://b/", Format: "img"})
+
+	// Using "a" makes "b" the least recently used.
+	_, ok := cache.Get(now, "http://a/")
+	assert.True(t, ok)
+	cache.Set(now, &MediaCacheEntry{URL: "http://c/", Error: "nope"})
+
+	_, ok = cache.Get(now, "http://b/")
+	assert.False(t, ok)
+	_, ok = cache.Get(now, "http://a/")
+	assert.True(t, ok)
+
+	// Failures expire sooner.
+	entry, ok := cache.Get(now.Add(30*time.Second), "http://c/")
+	if assert.True(t, ok) {
+		assert.Equal(t, "nope", entry.Error)
+	}
+	_, ok = cache.Get(now.Add(2*time.Minute), "http://c/")
+	assert.False(t, ok)
+	_, ok = cache.Get(now.Add(2*time.Hour), "http://a/")
+	assert.False(t, ok)
+
+	assert.Equal(t, MediaCacheStats{Hits: 3, Misses: 3}, cache.Stats())
+}
+
+func TestMediaCacheFlush(t *testing.T) {
+	cache, _ := NewMediaCache(0, "", "")
+	now := time.Now()
+
+	cache.Set(now, &MediaCacheEntry{URL: "http://a/"})
+	cache.Set(now, &MediaCacheEntry{URL: "http://b/", Error: "nope"})
+	assert.Equal(t, MediaCacheStats{Entries: 2, Failures: 1}, cache.Stats())
+
+	assert.Equal(t, 1, cache.Flush("http://b/"))
+	assert.Equal(t, 1, cache.Flush(""))
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestMediaCacheLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ygord-media-cache")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "media.json")
+
+	cache, _ := NewMediaCache(0, "", "")
+	assert.Nil(t, cache.Load(path))
+	cache.Set(time.Now(), &MediaCacheEntry{URL: "http://a/", Src: "a"})
+	cache.Set(time.Now().Add(-time.Hour), &MediaCacheEntry{URL: "http://b/", Error: "nope"})
+	assert.Nil(t, cache.Save())
+
+	// The expired failure is not loaded.
+	cache, _ = NewMediaCache(0, "", "")
+	assert.Nil(t, cache.Load(path))
+	assert.Equal(t, 1, cache.Stats().Entries)
+	entry, ok := cache.Peek("http://a/")
+	if assert.True(t, ok) {
+		assert.Equal(t, "a", entry.Src)
+	}
+}
+
+func TestMediaCacheSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ygord-media-cache")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "media.json")
+
+	cache, _ := NewMediaCache(0, "", "")
+	cache.SaveDelay = 10 * time.Millisecond
+	assert.Nil(t, cache.Load(path))
+
+	// The changes are saved in the background, in a single write.
+	cache.Set(time.Now(), &MediaCacheEntry{URL: "http://a/", Src: "a"})
+	cache.Set(time.Now(), &MediaCacheEntry{URL: "http://b/", Src: "b"})
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	loaded, _ := NewMediaCache(0, "", "")
+	for i := 0; i < 100 && loaded.Stats().Entries == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(t, loaded.Load(path))
+	}
+	assert.Equal(t, 2, loaded.Stats().Entries)
+}
+
+func TestMediaCacheResolve(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/cat.gif":
+			w.Header().Set("Content-Type", "image/gif")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	srv := CreateTestServer()
+
+	for i := 0; i < 2; i++ {
+		media, err := NewMedia(srv, map[string]string{"url": ts.URL + "/cat.gif"},
+			"imageTrack", true, true, nil)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "img", media.Format)
+		}
+
+		_, err = NewMedia(srv, map[string]string{"url": ts.URL + "/dog.gif"},
+			"imageTrack", true, true, nil)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "response status code is 404", err.Error())
+		}
+
+		// The cached resolution is still checked against the
+		// formats accepted by the command.
+		_, err = NewMedia(srv, map[string]string{"url": ts.URL + "/cat.gif"},
+			"playTrack", false, false, []string{"audio"})
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "content-type (image/gif) not supported by this command", err.Error())
+		}
+	}
+
+	// HEAD for cat.gif, HEAD then GET for dog.gif.
+	assert.Equal(t, 3, requests)
+	entry, ok := srv.MediaCache.Peek(ts.URL + "/dog.gif")
+	if assert.True(t, ok) {
+		assert.Equal(t, "response status code is 404", entry.Error)
+	}
+}
+
+func TestMediaCacheResolveNetworkFailure(t *testing.T) {
+	// Nothing is listening on this address anymore.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	link := "http://" + listener.Addr().String() + "/cat.gif"
+	listener.Close()
+
+	srv := CreateTestServer()
+
+	_, err = NewMedia(srv, map[string]string{"url": link}, "imageTrack",
+		true, true, nil)
+	assert.NotNil(t, err)
+
+	// The next attempt could succeed.
+	_, ok := srv.MediaCache.Peek(link)
+	assert.False(t, ok)
+}
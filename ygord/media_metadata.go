@@ -0,0 +1,224 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the lookup of the metadata of the media (title, artist,
+// duration and thumbnail), either through the oEmbed endpoint of the
+// provider or from the HTML of generic pages (<title> and OpenGraph tags).
+// The results are cached with the rest of the resolution, see MediaCache.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MetadataTimeout is the maximum time spent fetching metadata.
+	MetadataTimeout = 5 * time.Second
+
+	// MetadataMaxHTMLSize is the maximum number of bytes of a page read to
+	// find its title.
+	MetadataMaxHTMLSize = 64 * 1024
+)
+
+var (
+	metadataClient = &http.Client{
+		Timeout:       MetadataTimeout,
+		Transport:     outboundTransport,
+		CheckRedirect: checkRedirect,
+	}
+
+	reHTMLTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	reHTMLMeta  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	reHTMLAttr  = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// MediaMetadata describes the content of a Media, all the fields are
+// optional.
+type MediaMetadata struct {
+	Title     string        `json:"title"`
+	Artist    string        `json:"artist"`
+	Duration  time.Duration `json:"duration"`
+	Thumbnail string        `json:"thumbnail"`
+}
+
+// merge fills the empty fields of the metadata with the given ones.
+func (metadata *MediaMetadata) merge(other *MediaMetadata) {
+	if metadata.Title == "" {
+		metadata.Title = other.Title
+	}
+	if metadata.Artist == "" {
+		metadata.Artist = other.Artist
+	}
+	if metadata.Duration == 0 {
+		metadata.Duration = other.Duration
+	}
+	if metadata.Thumbnail == "" {
+		metadata.Thumbnail = other.Thumbnail
+	}
+}
+
+// MetadataFetcher is implemented by the resolvers able to describe the media
+// they handle.
+type MetadataFetcher interface {
+	FetchMetadata(media *Media) (*MediaMetadata, error)
+}
+
+// fetchGet sends a GET request limited to MetadataTimeout.
+func fetchGet(ctx context.Context, link string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+	return metadataClient.Do(req.WithContext(ctx))
+}
+
+// fetchOEmbed queries an oEmbed endpoint about the given URL.
+func fetchOEmbed(ctx context.Context, endpoint, link string) (*MediaMetadata, error) {
+	res, err := fetchGet(ctx, endpoint+"?format=json&url="+
+		url.QueryEscape(link))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("oEmbed response status code is " +
+			strconv.Itoa(res.StatusCode))
+	}
+
+	var dat struct {
+		Title        string  `json:"title"`
+		AuthorName   string  `json:"author_name"`
+		ThumbnailURL string  `json:"thumbnail_url"`
+		Duration     float64 `json:"duration"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&dat); err != nil {
+		return nil, errors.New("malformed oEmbed response")
+	}
+
+	return &MediaMetadata{
+		Title:     dat.Title,
+		Artist:    dat.AuthorName,
+		Duration:  time.Duration(dat.Duration * float64(time.Second)),
+		Thumbnail: dat.ThumbnailURL,
+	}, nil
+}
+
+// fetchHTMLMetadata reads the beginning of a web page to find its title,
+// preferring the OpenGraph tags over <title>.
+func fetchHTMLMetadata(ctx context.Context, link string) (*MediaMetadata, error) {
+	res, err := fetchGet(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("response status code is " +
+			strconv.Itoa(res.StatusCode))
+	}
+
+	if !strings.Contains(res.Header.Get("Content-Type"), "html") {
+		return nil, errors.New("not an HTML page")
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, MetadataMaxHTMLSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHTMLMetadata(string(body)), nil
+}
+
+// parseHTMLMetadata extracts the metadata from the OpenGraph tags and the
+// <title> of an HTML document.
+func parseHTMLMetadata(document string) *MediaMetadata {
+	metadata := &MediaMetadata{}
+
+	for _, tag := range reHTMLMeta.FindAllString(document, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range reHTMLAttr.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(attr[1])] = attr[2] + attr[3]
+		}
+
+		property := attrs["property"]
+		if property == "" {
+			property = attrs["name"]
+		}
+		content := strings.TrimSpace(html.UnescapeString(attrs["content"]))
+
+		switch property {
+		case "og:title":
+			metadata.Title = content
+		case "og:image":
+			metadata.Thumbnail = content
+		case "og:video:duration", "video:duration", "music:duration":
+			seconds, err := strconv.Atoi(content)
+			if err == nil && seconds > 0 {
+				metadata.Duration = time.Duration(seconds) * time.Second
+			}
+		case "og:audio:artist", "music:musician", "author":
+			metadata.Artist = content
+		}
+	}
+
+	if metadata.Title == "" {
+		if match := reHTMLTitle.FindStringSubmatch(document); match != nil {
+			title := html.UnescapeString(match[1])
+			metadata.Title = strings.Join(strings.Fields(title), " ")
+		}
+	}
+
+	return metadata
+}
+
+// fetchMetadata looks up the metadata of the media.  Failing to find metadata
+// is not an error for the media itself, it keeps what its resolver found and
+// falls back on its URL.
+func (media *Media) fetchMetadata() {
+	if media.srv == nil {
+		return
+	}
+
+	var metadata *MediaMetadata
+	var err error
+
+	if fetcher, ok := media.resolver.(MetadataFetcher); ok {
+		metadata, err = fetcher.FetchMetadata(media)
+	} else if media.Format == "web" && !hostIsPrivateIP(media.host) {
+		metadata, err = fetchHTMLMetadata(media.context(), media.url)
+	} else {
+		return
+	}
+
+	if err != nil {
+		media.srv.Logf(SeverityInfo, "metadata error for %s: %s",
+			media.url, err.Error())
+		return
+	}
+
+	media.metadata.merge(metadata)
+}
+
+// NowPlayingText returns the description of the media shown to the users
+// (e.g. "now playing: Fresh Pots (3:42)").
+func NowPlayingText(media *Media) string {
+	text := "now playing: " + media.GetTitle()
+	if duration := media.GetDuration(); duration > 0 {
+		text += " (" + FormatTimestamp(duration) + ")"
+	}
+	return text
+}
@@ -0,0 +1,109 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHTMLMetadata(t *testing.T) {
+	metadata := parseHTMLMetadata(`<html><head>
+		<title>
+			Fresh  Pots &amp; Co
+		</title>
+	</head></html>`)
+	assert.Equal(t, &MediaMetadata{Title: "Fresh Pots & Co"}, metadata)
+
+	metadata = parseHTMLMetadata(`<html><head>
+		<title>Page title</title>
+		<meta property="og:title" content="Fresh Pots &amp; Co">
+		<meta property='og:image' content='http://example.com/pots.jpg' />
+		<meta property="og:video:duration" content="222">
+		<meta name="author" content="Foo Fighters">
+	</head></html>`)
+	assert.Equal(t, &MediaMetadata{
+		Title:     "Fresh Pots & Co",
+		Artist:    "Foo Fighters",
+		Duration:  222 * time.Second,
+		Thumbnail: "http://example.com/pots.jpg",
+	}, metadata)
+
+	assert.Equal(t, &MediaMetadata{}, parseHTMLMetadata("no html here"))
+}
+
+func TestFetchOEmbed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("url") {
+		case "https://vimeo.com/123456":
+			fmt.Fprint(w, `{"title": "Fresh Pots", "author_name": "Foo Fighters", "duration": 222, "thumbnail_url": "http://example.com/pots.jpg"}`)
+		case "https://vimeo.com/666666":
+			fmt.Fprint(w, `nope`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	metadata, err := fetchOEmbed(context.Background(), ts.URL, "https://vimeo.com/123456")
+	if assert.Nil(t, err) {
+		assert.Equal(t, &MediaMetadata{
+			Title:     "Fresh Pots",
+			Artist:    "Foo Fighters",
+			Duration:  222 * time.Second,
+			Thumbnail: "http://example.com/pots.jpg",
+		}, metadata)
+	}
+
+	_, err = fetchOEmbed(context.Background(), ts.URL, "https://vimeo.com/666666")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "malformed oEmbed response", err.Error())
+	}
+
+	_, err = fetchOEmbed(context.Background(), ts.URL, "https://vimeo.com/404")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "oEmbed response status code is 404", err.Error())
+	}
+}
+
+func TestMediaFetchMetadataFromHTML(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.Method == "GET" {
+			requests++
+			fmt.Fprint(w, `<title>Fresh Pots</title>`)
+		}
+	}))
+	defer ts.Close()
+
+	srv := CreateTestServer()
+
+	for i := 0; i < 2; i++ {
+		media, err := NewMedia(srv, map[string]string{"url": ts.URL + "/pots"},
+			"imageTrack", true, true, nil)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "web", media.Format)
+			assert.Equal(t, "Fresh Pots", media.GetTitle())
+			assert.Equal(t, "now playing: Fresh Pots", NowPlayingText(media))
+		}
+	}
+
+	// The second lookup is served from the cache.
+	assert.Equal(t, 1, requests)
+}
+
+func TestNowPlayingText(t *testing.T) {
+	media := &Media{url: "http://example.com/freshpots.mp3"}
+	assert.Equal(t, "now playing: freshpots.mp3", NowPlayingText(media))
+
+	media.metadata = MediaMetadata{Title: "Fresh Pots", Duration: 222 * time.Second}
+	assert.Equal(t, "now playing: Fresh Pots (3:42)", NowPlayingText(media))
+}
@@ -0,0 +1,387 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the media proxy.  When enabled, the images, audio and
+// video files are not fetched by the minions straight from their host but
+// through ygord, which downloads them once and keeps them on disk.  All the
+// screens then load the same bytes from the same place, regardless of how
+// slow or picky the original host is.
+//
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MediaProxyTimeout is the maximum time spent downloading a file.
+	MediaProxyTimeout = 2 * time.Minute
+
+	// MediaProxyPath is the path of the HTTP endpoint serving the files,
+	// followed by their key.
+	MediaProxyPath = "/media/proxy/"
+)
+
+var (
+	mediaProxyClient = &http.Client{
+		Timeout:       MediaProxyTimeout,
+		Transport:     outboundTransport,
+		CheckRedirect: checkRedirect,
+	}
+
+	// Formats of the media going through the proxy, the others are
+	// embedded by the minions (e.g. YouTube).
+	mediaProxyFormats = []string{"img", "audio", "video"}
+)
+
+// MediaProxyFile describes a file known to the proxy, downloaded or not.
+// MediaType is the type found when the media was resolved (e.g. "image/gif",
+// or ".gif" if it was recognized by the extension of its URL).
+type MediaProxyFile struct {
+	Key         string    `json:"key"`
+	URL         string    `json:"url"`
+	Format      string    `json:"format"`
+	MediaType   string    `json:"mediaType"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Accessed    time.Time `json:"accessed"`
+
+	// Set while the file is being downloaded, closed once done.
+	downloading chan bool
+	err         error
+}
+
+// MediaProxy downloads the media to Dir on demand, keeping at most MaxSize
+// bytes.  Files larger than MaxFileSize are rejected.
+type MediaProxy struct {
+	sync.Mutex
+
+	Dir         string
+	MaxSize     int64
+	MaxFileSize int64
+
+	// BaseURL is the prefix of the URLs given to the minions, defaults to
+	// the MediaProxyPath of the ygord serving them.
+	BaseURL string
+
+	// Known files by key, the downloaded ones have a Size.
+	files map[string]*MediaProxyFile
+	size  int64
+}
+
+// NewMediaProxy creates a MediaProxy storing its files in dir, loading the
+// files previously downloaded there.
+func NewMediaProxy(dir string, maxSize, maxFileSize int64, baseURL string) (*MediaProxy, error) {
+	proxy := &MediaProxy{
+		Dir:         dir,
+		MaxSize:     maxSize,
+		MaxFileSize: maxFileSize,
+		BaseURL:     baseURL,
+		files:       make(map[string]*MediaProxyFile),
+	}
+
+	if proxy.BaseURL == "" {
+		proxy.BaseURL = MediaProxyPath
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		file := &MediaProxyFile{}
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+
+		// Ignore the leftovers of interrupted downloads.
+		if _, err := os.Stat(proxy.path(file.Key)); err != nil {
+			os.Remove(path)
+			continue
+		}
+
+		proxy.files[file.Key] = file
+		proxy.size += file.Size
+	}
+
+	return proxy, nil
+}
+
+// mediaProxyKey returns the key identifying the given URL in the proxy.
+func mediaProxyKey(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the path of the file with the given key, its metadata are
+// stored next to it with a ".json" extension.
+func (proxy *MediaProxy) path(key string) string {
+	return filepath.Join(proxy.Dir, key)
+}
+
+// Rewrite points the Src of the media to the proxy if it is a file the proxy
+// handles, the minions will then fetch it from ygord.
+func (proxy *MediaProxy) Rewrite(media *Media) {
+	if !media.IsOfFormat(mediaProxyFormats) || media.host == "" ||
+		hostIsPrivateIP(media.host) {
+		return
+	}
+
+	key := mediaProxyKey(media.Src)
+
+	proxy.Lock()
+	if _, ok := proxy.files[key]; !ok {
+		proxy.files[key] = &MediaProxyFile{
+			Key:       key,
+			URL:       media.Src,
+			Format:    media.Format,
+			MediaType: media.mediaType,
+		}
+	}
+	proxy.Unlock()
+
+	media.Src = proxy.BaseURL + key
+}
+
+// Open returns the file with the given key, downloading it first if needed.
+// Only the keys of the media rewritten since startup are accepted, as well
+// as the files already downloaded.
+func (proxy *MediaProxy) Open(key string) (*MediaProxyFile, *os.File, error) {
+	proxy.Lock()
+	file, ok := proxy.files[key]
+	if !ok {
+		proxy.Unlock()
+		return nil, nil, os.ErrNotExist
+	}
+
+	if file.Size == 0 {
+		if file.downloading == nil {
+			file.downloading = make(chan bool)
+			file.err = nil
+			go proxy.download(file)
+		}
+		downloading := file.downloading
+		proxy.Unlock()
+
+		<-downloading
+
+		proxy.Lock()
+		if file.err != nil {
+			err := file.err
+			proxy.Unlock()
+			return nil, nil, err
+		}
+	}
+
+	file.Accessed = time.Now().UTC()
+	info := *file
+	proxy.Unlock()
+
+	f, err := os.Open(proxy.path(key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &info, f, nil
+}
+
+// download fetches the file from its URL and stores it, then wakes up the
+// requests waiting for it.
+func (proxy *MediaProxy) download(file *MediaProxyFile) {
+	contentType, size, err := proxy.fetch(file)
+
+	proxy.Lock()
+	defer proxy.Unlock()
+
+	if err == nil {
+		file.ContentType = contentType
+		file.Size = size
+		proxy.size += size
+		proxy.evict(file.Key)
+		err = proxy.saveMetadata(file)
+	}
+
+	file.err = err
+	close(file.downloading)
+	file.downloading = nil
+}
+
+// fetch downloads the file to disk, returning its Content-Type and size.
+func (proxy *MediaProxy) fetch(file *MediaProxyFile) (string, int64, error) {
+	res, err := mediaProxyClient.Get(file.URL)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", 0, errors.New("response status code is " +
+			strconv.Itoa(res.StatusCode))
+	}
+
+	// The host may not describe the file properly, it was recognized by
+	// its content or its extension when the media was resolved.
+	body := bufio.NewReaderSize(res.Body, MediaSniffSize)
+	head, _ := body.Peek(MediaSniffSize)
+	header, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	contentType := mediaProxyContentType(file, header, head)
+	if contentType == "" {
+		return "", 0, errors.New("content-type (" + header +
+			") is not " + file.Format)
+	}
+
+	if res.ContentLength > proxy.MaxFileSize {
+		return "", 0, errors.New("file too large")
+	}
+
+	tmp, err := ioutil.TempFile(proxy.Dir, "download-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, io.LimitReader(body, proxy.MaxFileSize+1))
+	tmp.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	if size > proxy.MaxFileSize {
+		return "", 0, errors.New("file too large")
+	}
+	if size == 0 {
+		return "", 0, errors.New("empty file")
+	}
+
+	if err := os.Rename(tmp.Name(), proxy.path(file.Key)); err != nil {
+		return "", 0, err
+	}
+
+	return contentType, size, nil
+}
+
+// mediaProxyAccepts returns true if the Content-Type matches the format of
+// the media.
+func mediaProxyAccepts(format, contentType string) bool {
+	for _, accepted := range supportedFormatsAndTypes[format] {
+		if contentType == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaProxyContentType returns the Content-Type the file is served with: the
+// one given by its host if it matches the format of the media, else the one
+// detected from its first bytes, else the one found when the media was
+// resolved.  It returns an empty string if none matches.
+func mediaProxyContentType(file *MediaProxyFile, header string, head []byte) string {
+	if mediaProxyAccepts(file.Format, header) {
+		return header
+	}
+
+	if sniffed := sniffContentType(head); mediaProxyAccepts(file.Format, sniffed) {
+		return sniffed
+	}
+
+	mediaType := file.MediaType
+	if strings.HasPrefix(mediaType, ".") {
+		mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(mediaType))
+	}
+	if mediaProxyAccepts(file.Format, mediaType) {
+		return mediaType
+	}
+
+	return ""
+}
+
+// saveMetadata writes the description of the file next to it.
+func (proxy *MediaProxy) saveMetadata(file *MediaProxyFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(proxy.path(file.Key)+".json", data, 0640)
+}
+
+// evict removes the least recently accessed files until the total size is
+// below MaxSize, keeping the file with the given key.
+func (proxy *MediaProxy) evict(keep string) {
+	if proxy.size <= proxy.MaxSize {
+		return
+	}
+
+	var files []*MediaProxyFile
+	for _, file := range proxy.files {
+		if file.Size > 0 && file.Key != keep {
+			files = append(files, file)
+		}
+	}
+	sort.Sort(mediaProxyFilesByAccess(files))
+
+	for _, file := range files {
+		if proxy.size <= proxy.MaxSize {
+			break
+		}
+		os.Remove(proxy.path(file.Key))
+		os.Remove(proxy.path(file.Key) + ".json")
+		proxy.size -= file.Size
+		file.Size = 0
+	}
+}
+
+type mediaProxyFilesByAccess []*MediaProxyFile
+
+func (files mediaProxyFilesByAccess) Len() int      { return len(files) }
+func (files mediaProxyFilesByAccess) Swap(i, j int) { files[i], files[j] = files[j], files[i] }
+func (files mediaProxyFilesByAccess) Less(i, j int) bool {
+	return files[i].Accessed.Before(files[j].Accessed)
+}
+
+// Size returns the number of files downloaded and their total size.
+func (proxy *MediaProxy) Size() (int, int64) {
+	proxy.Lock()
+	defer proxy.Unlock()
+
+	count := 0
+	for _, file := range proxy.files {
+		if file.Size > 0 {
+			count++
+		}
+	}
+
+	return count, proxy.size
+}
+
+// isMediaProxyKey returns true if the value looks like a key of the proxy.
+func isMediaProxyKey(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	return strings.Trim(value, "0123456789abcdef") == ""
+}
@@ -0,0 +1,175 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMediaProxyTestServer(requests map[string]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/cat.gif", "/dog.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte("GIF89a...."))
+		case "/big.gif":
+			w.Header().Set("Content-Type", "image/gif")
+			w.Write([]byte(strings.Repeat("GIF89a", 100)))
+		case "/sniffed":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("GIF89a...."))
+		case "/photo.jpg":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("not a JPEG"))
+		case "/fake.gif":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func getMediaProxy(srv *Server, src string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", src, nil)
+	handler := &MediaProxyHandler{srv}
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestMediaProxy(t *testing.T) {
+	requests := make(map[string]int)
+	ts := newMediaProxyTestServer(requests)
+	defer ts.Close()
+	uri, _ := url.Parse(ts.URL)
+
+	dir, err := ioutil.TempDir("", "ygord-media-proxy")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	srv := CreateTestServer()
+	srv.MediaProxy, err = NewMediaProxy(dir, 15, 100, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	proxied := make(map[string]string)
+	for _, name := range []string{"cat.gif", "dog.gif", "big.gif", "fake.gif"} {
+		media := &Media{Src: ts.URL + "/" + name, host: uri.Host, Format: "img"}
+		srv.MediaProxy.Rewrite(media)
+		assert.True(t, strings.HasPrefix(media.Src, MediaProxyPath), media.Src)
+		proxied[name] = media.Src
+	}
+
+	// Downloaded once, then served from the disk.
+	for i := 0; i < 2; i++ {
+		w := getMediaProxy(srv, proxied["cat.gif"])
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "GIF89a....", w.Body.String())
+	}
+	assert.Equal(t, 1, requests["/cat.gif"])
+
+	// The least recently used file is dropped to make room.
+	assert.Equal(t, 200, getMediaProxy(srv, proxied["dog.gif"]).Code)
+	count, size := srv.MediaProxy.Size()
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(10), size)
+
+	w := getMediaProxy(srv, proxied["big.gif"])
+	assert.Equal(t, 502, w.Code)
+	assert.Equal(t, "file too large\n", w.Body.String())
+
+	w = getMediaProxy(srv, proxied["fake.gif"])
+	assert.Equal(t, 502, w.Code)
+	assert.Equal(t, "content-type (text/html) is not img\n", w.Body.String())
+
+	assert.Equal(t, 404, getMediaProxy(srv, MediaProxyPath+mediaProxyKey("nope")).Code)
+	assert.Equal(t, 404, getMediaProxy(srv, MediaProxyPath+"../aliases.cfg").Code)
+
+	// The downloaded files are still available after a restart.
+	proxy, err := NewMediaProxy(dir, 15, 100, "")
+	if assert.Nil(t, err) {
+		count, size := proxy.Size()
+		assert.Equal(t, 1, count)
+		assert.Equal(t, int64(10), size)
+	}
+}
+
+func TestMediaProxyResolvedType(t *testing.T) {
+	requests := make(map[string]int)
+	ts := newMediaProxyTestServer(requests)
+	defer ts.Close()
+	uri, _ := url.Parse(ts.URL)
+
+	dir, err := ioutil.TempDir("", "ygord-media-proxy")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	srv := CreateTestServer()
+	srv.MediaProxy, err = NewMediaProxy(dir, 1000, 100, "")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Files the host does not describe properly are recognized by their
+	// content, or by the type found when resolving them.
+	for _, test := range []struct {
+		name, mediaType, contentType string
+	}{
+		{"sniffed", "", "image/gif"},
+		{"photo.jpg", ".jpg", "image/jpeg"},
+	} {
+		media := &Media{Src: ts.URL + "/" + test.name, host: uri.Host,
+			Format: "img", mediaType: test.mediaType}
+		srv.MediaProxy.Rewrite(media)
+
+		w := getMediaProxy(srv, media.Src)
+		assert.Equal(t, 200, w.Code, test.name)
+		assert.Equal(t, test.contentType, w.Header().Get("Content-Type"),
+			test.name)
+	}
+}
+
+func TestMediaProxyRewrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ygord-media-proxy")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	proxy, err := NewMediaProxy(dir, 15, 100, "https://ygor.example.com/media/proxy/")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	media := &Media{Src: "http://example.com/cat.gif", host: "example.com", Format: "img"}
+	proxy.Rewrite(media)
+	assert.Equal(t, "https://ygor.example.com/media/proxy/"+
+		mediaProxyKey("http://example.com/cat.gif"), media.Src)
+
+	// Embedded media and private hosts are left alone.
+	media = &Media{Src: "dQw4w9WgXcQ", host: "youtu.be", Format: "youtube"}
+	proxy.Rewrite(media)
+	assert.Equal(t, "dQw4w9WgXcQ", media.Src)
+
+	media = &Media{Src: "http://10.0.0.1/cat.gif", host: "10.0.0.1", Format: "img"}
+	proxy.Rewrite(media)
+	assert.Equal(t, "http://10.0.0.1/cat.gif", media.Src)
+}
@@ -0,0 +1,274 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the queue resolving the media in the background.
+// Resolving a URL requires a few requests to remote hosts, which could take a
+// while and would otherwise freeze the main loop.  The results are delivered
+// back to the main loop in the order the requests were made in each channel,
+// so that an alias playing a sound then showing an image keeps working as
+// expected.  The other commands of the channel wait for the media submitted
+// before them, see After.
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMediaCanceled is given to the callback of the jobs canceled
+	// before they were delivered (e.g. by 'shutup').
+	ErrMediaCanceled = errors.New("media resolution canceled")
+
+	// ErrMediaQueueFull is returned when too many jobs are pending.
+	ErrMediaQueueFull = errors.New("too many pending media, please try " +
+		"again later")
+)
+
+// MediaJob is a single media resolution, see MediaQueue.Submit, or a command
+// waiting for the resolutions submitted before it, see MediaQueue.After.
+type MediaJob struct {
+	Channel string
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	resolve func(ctx context.Context) (*Media, error)
+	done    func(media *Media, err error)
+
+	// Outcome of the resolution, set once ready.
+	media     *Media
+	err       error
+	ready     bool
+	cancelled bool
+}
+
+// MediaQueue runs the media resolutions on a pool of Workers, each limited to
+// Timeout once picked up by a worker.
+type MediaQueue struct {
+	sync.Mutex
+
+	Workers int
+	Timeout time.Duration
+
+	// Ready receives the channel of every job resolved, the main loop is
+	// expected to call Deliver with it.
+	Ready chan string
+
+	jobs chan *MediaJob
+
+	// Jobs not delivered yet per channel, in the order they were
+	// submitted.
+	pending map[string][]*MediaJob
+}
+
+// NewMediaQueue creates a MediaQueue from its configuration, using the
+// defaults (4 workers, "10s") for empty values.  The workers are not running
+// until Start is called.
+func NewMediaQueue(workers int, timeout string) (*MediaQueue, error) {
+	queue := &MediaQueue{
+		Workers: 4,
+		Timeout: 10 * time.Second,
+		Ready:   make(chan string, 128),
+		jobs:    make(chan *MediaJob, 128),
+		pending: make(map[string][]*MediaJob),
+	}
+
+	var err error
+
+	if workers < 0 {
+		return nil, errors.New("workers must be positive")
+	} else if workers > 0 {
+		queue.Workers = workers
+	}
+
+	if timeout != "" {
+		queue.Timeout, err = ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.New("timeout: " + err.Error())
+		}
+	}
+
+	return queue, nil
+}
+
+// Start runs the workers.
+func (queue *MediaQueue) Start() {
+	for i := 0; i < queue.Workers; i++ {
+		go queue.work()
+	}
+}
+
+// work resolves the submitted jobs, forever.
+func (queue *MediaQueue) work() {
+	for job := range queue.jobs {
+		// The time spent waiting for a worker does not count.
+		ctx, cancel := context.WithTimeout(job.ctx, queue.Timeout)
+		media, err := job.resolve(ctx)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = errors.New("timed out after " +
+				shortDuration(queue.Timeout))
+		}
+		cancel()
+		job.cancel()
+
+		queue.Lock()
+		job.media, job.err, job.ready = media, err, true
+		queue.Unlock()
+
+		queue.Ready <- job.Channel
+	}
+}
+
+// Submit queues a media resolution for the given channel.  Once resolve
+// returns, done is called by Deliver, after the jobs submitted before for the
+// same channel.
+func (queue *MediaQueue) Submit(channel string, resolve func(ctx context.Context) (*Media, error), done func(media *Media, err error)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &MediaJob{
+		Channel: channel,
+		ctx:     ctx,
+		cancel:  cancel,
+		resolve: resolve,
+		done:    done,
+	}
+
+	queue.Lock()
+	defer queue.Unlock()
+
+	select {
+	case queue.jobs <- job:
+	default:
+		cancel()
+		return ErrMediaQueueFull
+	}
+
+	queue.pending[channel] = append(queue.pending[channel], job)
+
+	return nil
+}
+
+// After calls fn once all the jobs submitted before for the channel are
+// delivered, right away if there are none.  It is not canceled by Cancel.
+func (queue *MediaQueue) After(channel string, fn func()) {
+	queue.Lock()
+	if len(queue.pending[channel]) == 0 {
+		queue.Unlock()
+		fn()
+		return
+	}
+
+	queue.pending[channel] = append(queue.pending[channel], &MediaJob{
+		Channel: channel,
+		done:    func(media *Media, err error) { fn() },
+		ready:   true,
+	})
+	queue.Unlock()
+}
+
+// Deliver calls the callbacks of the jobs of the channel which are ready, up
+// to the first one still being resolved.
+func (queue *MediaQueue) Deliver(channel string) {
+	queue.Lock()
+	var ready []*MediaJob
+	jobs := queue.pending[channel]
+	for len(jobs) > 0 && jobs[0].ready {
+		ready = append(ready, jobs[0])
+		jobs = jobs[1:]
+	}
+	if len(jobs) == 0 {
+		delete(queue.pending, channel)
+	} else {
+		queue.pending[channel] = jobs
+	}
+	queue.Unlock()
+
+	for _, job := range ready {
+		if job.cancelled {
+			job.done(nil, ErrMediaCanceled)
+		} else {
+			job.done(job.media, job.err)
+		}
+	}
+}
+
+// Cancel aborts the pending resolutions of the given channel, or of all the
+// channels if empty.  It returns the number of resolutions canceled.
+func (queue *MediaQueue) Cancel(channel string) int {
+	queue.Lock()
+	defer queue.Unlock()
+
+	count := 0
+	for name, jobs := range queue.pending {
+		if channel != "" && name != channel {
+			continue
+		}
+		for _, job := range jobs {
+			if job.resolve != nil && !job.cancelled {
+				job.cancelled = true
+				job.cancel()
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// Len returns the number of jobs not delivered yet.
+func (queue *MediaQueue) Len() int {
+	queue.Lock()
+	defer queue.Unlock()
+
+	count := 0
+	for _, jobs := range queue.pending {
+		count += len(jobs)
+	}
+
+	return count
+}
+
+// ResolveMedia resolves a media in the background, done is called from the
+// main loop when ready (see MediaQueue).  The message is only audited once
+// all its media are delivered, and done is not called if the resolution was
+// canceled.
+func (srv *Server) ResolveMedia(msg *InputMessage, resolve func(ctx context.Context) (*Media, error), done func(media *Media, err error)) {
+	err := srv.MediaQueue.Submit(msg.ReplyTo, resolve,
+		func(media *Media, err error) {
+			if err != ErrMediaCanceled {
+				done(media, err)
+			}
+			msg.pending--
+			if msg.pending == 0 {
+				srv.Audit(msg.Audit)
+			}
+		})
+	if err != nil {
+		done(nil, err)
+		return
+	}
+
+	msg.pending++
+}
+
+// context returns the context of the resolution of the media.
+func (media *Media) context() context.Context {
+	if media.ctx == nil {
+		return context.Background()
+	}
+	return media.ctx
+}
+
+// httpRequest sends a request in the context of the resolution of the
+// media, it is aborted if the resolution is canceled or times out.
+func (media *Media) httpRequest(method, link string) (*http.Response, error) {
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	return outboundClient.Do(req.WithContext(media.context()))
+}
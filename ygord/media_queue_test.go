@@ -0,0 +1,191 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaQueueOrdering(t *testing.T) {
+	srv := CreateTestServer()
+
+	var delivered []string
+	release := make(chan bool)
+
+	submit := func(channel, name string, wait bool) {
+		srv.MediaQueue.Submit(channel, func(ctx context.Context) (*Media, error) {
+			if wait {
+				<-release
+			}
+			return &Media{Src: name}, nil
+		}, func(media *Media, err error) {
+			delivered = append(delivered, channel+" "+media.Src)
+		})
+	}
+
+	// The slow first job of #test holds the second one back, but not the
+	// jobs of the other channels.
+	submit("#test", "slow", true)
+	submit("#test", "fast", false)
+	submit("#lobby", "other", false)
+
+	srv.MediaQueue.Deliver(<-srv.MediaQueue.Ready)
+	srv.MediaQueue.Deliver(<-srv.MediaQueue.Ready)
+	assert.Equal(t, []string{"#lobby other"}, delivered)
+
+	close(release)
+	srv.FlushMediaQueue()
+	assert.Equal(t, []string{"#lobby other", "#test slow", "#test fast"},
+		delivered)
+}
+
+func TestMediaQueueTimeout(t *testing.T) {
+	srv := CreateTestServer()
+	srv.MediaQueue.Timeout = 10 * time.Millisecond
+
+	var result error
+	srv.MediaQueue.Submit("#test", func(ctx context.Context) (*Media, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, func(media *Media, err error) {
+		result = err
+	})
+
+	srv.FlushMediaQueue()
+	if assert.NotNil(t, result) {
+		assert.Equal(t, "timed out after 10ms", result.Error())
+	}
+}
+
+func TestMediaQueueCancel(t *testing.T) {
+	srv := CreateTestServer()
+
+	var result error
+	srv.MediaQueue.Submit("#test", func(ctx context.Context) (*Media, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, func(media *Media, err error) {
+		result = err
+	})
+
+	assert.Equal(t, 0, srv.MediaQueue.Cancel("#lobby"))
+	assert.Equal(t, 1, srv.MediaQueue.Cancel("#test"))
+
+	srv.FlushMediaQueue()
+	assert.Equal(t, ErrMediaCanceled, result)
+}
+
+func TestModuleShutUpCancelsMedia(t *testing.T) {
+	srv := createTestServerWithHistory()
+	client := srv.RegisterClient("dummy", "test")
+	(&ShutUpModule{}).Init(srv)
+
+	// Blocks the play command until the shutup command is run.
+	release := make(chan bool)
+	srv.MediaQueue.Submit("#test", func(ctx context.Context) (*Media, error) {
+		<-release
+		return nil, nil
+	}, func(media *Media, err error) {})
+
+	srv.RunCommand("play", &InputMessage{
+		Adapter: "test",
+		ReplyTo: "#test",
+		Body:    "play http://10.0.0.1/freshpots.mp3",
+		Args:    []string{"http://10.0.0.1/freshpots.mp3"},
+	})
+	// Not audited until the media is delivered.
+	assert.Empty(t, srv.AuditLog.Recent(10, ""))
+
+	srv.RunCommand("shutup", &InputMessage{
+		Adapter: "test",
+		ReplyTo: "#test",
+		Body:    "shutup",
+	})
+	close(release)
+	srv.FlushMediaQueue()
+
+	// Only the shutup command reached the minions.
+	if queue := client.FlushQueue(); assert.Len(t, queue, 1) {
+		assert.Equal(t, "shutup", queue[0].Name)
+	}
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "ok...", msgs[0].Body)
+	}
+
+	entries := srv.AuditLog.Recent(10, "")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "play", entries[0].Command)
+		assert.Empty(t, entries[0].ClientCommands)
+		assert.Equal(t, "shutup", entries[1].Command)
+	}
+}
+
+func TestMediaQueueTimeoutStartsWhenPickedUp(t *testing.T) {
+	queue, _ := NewMediaQueue(1, "50ms")
+	queue.Start()
+
+	// The second job waits for the only worker longer than the timeout.
+	results := make(map[string]error)
+	for _, name := range []string{"slow", "fast"} {
+		name := name
+		queue.Submit("#test", func(ctx context.Context) (*Media, error) {
+			if name == "slow" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return &Media{Src: name}, nil
+		}, func(media *Media, err error) {
+			results[name] = err
+		})
+	}
+
+	for queue.Len() > 0 {
+		queue.Deliver(<-queue.Ready)
+	}
+
+	if assert.NotNil(t, results["slow"]) {
+		assert.Equal(t, "timed out after 50ms", results["slow"].Error())
+	}
+	assert.Nil(t, results["fast"])
+}
+
+func TestServerCommandsWaitForMedia(t *testing.T) {
+	srv := createTestServerWithHistory()
+	client := srv.RegisterClient("dummy", "test")
+
+	// Blocks the media of #test until released.
+	release := make(chan bool)
+	srv.MediaQueue.Submit("#test", func(ctx context.Context) (*Media, error) {
+		<-release
+		return nil, nil
+	}, func(media *Media, err error) {})
+
+	for _, body := range []string{"play http://10.0.0.1/freshpots.mp3", "skip"} {
+		msgs, err := srv.NewMessagesFromBody(body, 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		msgs[0].Type = InputMsgTypeChannel
+		msgs[0].Adapter = "test"
+		msgs[0].ReplyTo = "#test"
+		srv.IRCMessageHandler(msgs[0])
+	}
+
+	// The skip is not sent before the track it is meant to skip.
+	assert.Empty(t, client.FlushQueue())
+
+	close(release)
+	srv.FlushMediaQueue()
+
+	var names []string
+	for _, cmd := range client.FlushQueue() {
+		names = append(names, cmd.Name)
+	}
+	assert.Equal(t, []string{"play", "skip"}, names)
+}
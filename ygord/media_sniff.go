@@ -0,0 +1,212 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the fallback used when the HEAD request of a media URL
+// is not conclusive.  Many hosts refuse HEAD requests (e.g. 405), answer them
+// differently than GET (e.g. signed URLs) or do not bother with a meaningful
+// Content-Type.  The first bytes of the file are then requested and its type
+// is detected from its content.
+//
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MediaSniffSize is the number of bytes requested to detect the type
+	// of a file.
+	MediaSniffSize = 4096
+
+	// MediaMaxRedirects is the number of redirects followed when fetching
+	// a URL.
+	MediaMaxRedirects = 10
+)
+
+var (
+	// Content-Types which do not tell anything about the file.
+	genericContentTypes = []string{
+		"",
+		"application/binary",
+		"application/octet-stream",
+		"application/unknown",
+		"application/x-unknown",
+		"binary/octet-stream",
+		"text/plain",
+	}
+
+	// Signatures of the audio and video files not recognized by
+	// http.DetectContentType, or not precisely enough.
+	mediaSignatures = []struct {
+		offset      int
+		magic       []byte
+		contentType string
+	}{
+		{4, []byte("ftypM4A "), "audio/mp4"},
+		{4, []byte("ftypM4B "), "audio/mp4"},
+		{4, []byte("ftypM4V "), "video/x-m4v"},
+		{4, []byte("ftypqt  "), "video/quicktime"},
+		{28, []byte("\x01vorbis"), "audio/ogg"},
+		{28, []byte("OpusHead"), "audio/ogg"},
+		{28, []byte("Speex   "), "audio/ogg"},
+		{28, []byte("\x80theora"), "video/ogg"},
+		{0, []byte("OggS"), "video/ogg"},
+		{0, []byte("#!AMR"), "audio/amr"},
+		{0, []byte("\x30\x26\xb2\x75\x8e\x66\xcf\x11"), "video/x-ms-asf"},
+	}
+)
+
+// checkRedirect is the CheckRedirect of the outbound client, the addresses of
+// the new hosts are checked when connecting to them.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MediaMaxRedirects {
+		return errors.New("too many redirects")
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return errors.New("redirected to an unsupported URL (" +
+			req.URL.String() + ")")
+	}
+	return nil
+}
+
+// fetchError returns the reason of the failure of a request, without the
+// details of the request (e.g. "address 10.0.0.1 is blocked (private)").
+func fetchError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Err
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
+
+// isGenericContentType returns true if the Content-Type of the header does
+// not describe the file.
+func isGenericContentType(header http.Header) bool {
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	for _, generic := range genericContentTypes {
+		if contentType == generic {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffContentType returns the Content-Type of the file starting with the
+// given bytes.
+func sniffContentType(data []byte) string {
+	for _, signature := range mediaSignatures {
+		end := signature.offset + len(signature.magic)
+		if len(data) >= end &&
+			bytes.Equal(data[signature.offset:end], signature.magic) {
+			return signature.contentType
+		}
+	}
+
+	// MPEG audio frames (MP3) and ADTS (AAC) without ID3 tag.
+	if len(data) >= 2 && data[0] == 0xff {
+		if data[1]&0xf6 == 0xf0 {
+			return "audio/aac"
+		}
+		if data[1]&0xe0 == 0xe0 && data[1]&0x06 != 0 {
+			return "audio/mpeg"
+		}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return contentType
+}
+
+// isMediaContentType returns true if the Content-Type is one of the images,
+// audio or video files supported by the minions.
+func isMediaContentType(contentType string) bool {
+	for _, format := range []string{"img", "audio", "video"} {
+		for _, mediaType := range supportedFormatsAndTypes[format] {
+			if strings.Contains(contentType, mediaType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchHeader returns the header describing the URL of the media.  A HEAD
+// request is tried first, the beginning of the file is fetched if it fails
+// or if the Content-Type it returns is too vague.
+func (media *Media) fetchHeader() (http.Header, error) {
+	res, err := media.httpRequest("HEAD", media.Src)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusOK && !isGenericContentType(res.Header) {
+		return res.Header, nil
+	}
+
+	header, err := media.sniff()
+	if err != nil {
+		// The extensions may still tell what the file is.
+		if res.StatusCode == http.StatusOK {
+			return res.Header, nil
+		}
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// sniff requests the first MediaSniffSize bytes of the file, returning the
+// header of the response with the Content-Type detected from these bytes if
+// the original one is too vague.
+func (media *Media) sniff() (http.Header, error) {
+	req, err := http.NewRequest("GET", media.Src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-"+strconv.Itoa(MediaSniffSize-1))
+
+	res, err := outboundClient.Do(req.WithContext(media.context()))
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK &&
+		res.StatusCode != http.StatusPartialContent {
+		return nil, rejectMedia("response status code is " +
+			strconv.Itoa(res.StatusCode))
+	}
+
+	header := res.Header
+	if !isGenericContentType(header) {
+		return header, nil
+	}
+
+	// The host may ignore the range and send the whole file.
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, MediaSniffSize))
+	if err != nil {
+		return nil, fetchError(err)
+	}
+
+	if contentType := sniffContentType(data); isMediaContentType(contentType) {
+		header = http.Header{"Content-Type": {contentType}}
+	}
+
+	return header, nil
+}
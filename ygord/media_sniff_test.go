@@ -0,0 +1,101 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testPNG = "\x89PNG\x0d\x0a\x1a\x0a\x00\x00\x00\x0dIHDR"
+	testMP3 = "ID3\x03\x00\x00\x00\x00\x00\x00"
+)
+
+func TestSniffContentType(t *testing.T) {
+	for _, test := range []struct {
+		data        string
+		contentType string
+	}{
+		{testPNG, "image/png"},
+		{"GIF89a....", "image/gif"},
+		{testMP3, "audio/mpeg"},
+		{"\xff\xfb\x90\x64\x00", "audio/mpeg"},
+		{"\xff\xf1\x50\x80\x00", "audio/aac"},
+		{"\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00", "audio/mp4"},
+		{"\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom", "video/mp4"},
+		{"\x1a\x45\xdf\xa3\x01\x00\x00\x00", "video/webm"},
+		{"OggS\x00\x02" + string(make([]byte, 22)) + "OpusHead", "audio/ogg"},
+		{"OggS\x00\x02" + string(make([]byte, 22)) + "\x80theora", "video/ogg"},
+		{"RIFF\x00\x00\x00\x00WAVEfmt ", "audio/wave"},
+		{"<html><body>", "text/html"},
+		{"\x00\x01\x02\x03", "application/octet-stream"},
+	} {
+		assert.Equal(t, test.contentType, sniffContentType([]byte(test.data)),
+			test.contentType)
+	}
+}
+
+func TestMediaFetchHeaderFallback(t *testing.T) {
+	ranges := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			ranges[r.URL.Path] = r.Header.Get("Range")
+		}
+		switch r.URL.Path {
+		case "/no-head":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(testMP3))
+		case "/generic":
+			w.Header().Set("Content-Type", "binary/octet-stream")
+			w.Write([]byte(testPNG))
+		case "/redirect":
+			http.Redirect(w, r, "/generic", http.StatusFound)
+		case "/redirect-private":
+			http.Redirect(w, r, "http://10.0.0.1/cat.gif", http.StatusFound)
+		case "/redirect-ftp":
+			http.Redirect(w, r, "ftp://example.com/cat.gif", http.StatusFound)
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("hello"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		path   string
+		format string
+		err    string
+	}{
+		{"/no-head", "audio", ""},
+		{"/generic", "img", ""},
+		{"/redirect", "img", ""},
+		{"/text", "web", ""},
+		{"/redirect-private", "", "address 10.0.0.1 is blocked (private)"},
+		{"/redirect-ftp", "", "redirected to an unsupported URL (ftp://example.com/cat.gif)"},
+		{"/gone", "", "response status code is 404"},
+	} {
+		media, err := NewMedia(nil, map[string]string{"url": ts.URL + test.path},
+			"", false, false, nil)
+		if test.err != "" {
+			if assert.NotNil(t, err, test.path) {
+				assert.Equal(t, test.err, err.Error(), test.path)
+			}
+		} else if assert.Nil(t, err, test.path) {
+			assert.Equal(t, test.format, media.Format, test.path)
+		}
+	}
+
+	assert.Equal(t, "bytes=0-4095", ranges["/no-head"])
+}
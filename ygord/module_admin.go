@@ -0,0 +1,102 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This module provides the commands managing the roles of the users at
+// runtime.  They are only available to admins, from the AdminChannel.
+// Changes are not saved, the configuration is used again on restart.
+//
+
+package main
+
+// AdminModule controls the 'grant', 'revoke' and 'roles' commands.
+type AdminModule struct{}
+
+// GrantPrivMsg gives a role to a user of the given adapter.
+func (module *AdminModule) GrantPrivMsg(srv *Server, msg *InputMessage) {
+	role, err := ParseRole(msg.Params.String("role"))
+	if err != nil {
+		srv.ReplyError(msg, err.Error())
+		return
+	}
+
+	adapter := msg.Params.String("adapter")
+	nickname := msg.Params.String("nickname")
+	srv.Roles.Set(adapter, nickname, role)
+
+	srv.Reply(msg, adapter+"/"+nickname+" is now "+role.String())
+}
+
+// RevokePrivMsg removes the role of a user of the given adapter.
+func (module *AdminModule) RevokePrivMsg(srv *Server, msg *InputMessage) {
+	adapter := msg.Params.String("adapter")
+	nickname := msg.Params.String("nickname")
+
+	if !srv.Roles.Delete(adapter, nickname) {
+		srv.ReplyError(msg, adapter+"/"+nickname+" has no role")
+		return
+	}
+
+	srv.Reply(msg, adapter+"/"+nickname+" is now "+RoleUser.String())
+}
+
+// RolesPrivMsg lists all the users with a role.
+func (module *AdminModule) RolesPrivMsg(srv *Server, msg *InputMessage) {
+	items := srv.Roles.List()
+	if len(items) == 0 {
+		srv.Reply(msg, "no roles defined")
+		return
+	}
+
+	srv.ReplyList(msg, "roles", items)
+}
+
+// Init registers all the commands for this module.
+func (module AdminModule) Init(srv *Server) {
+	srv.RegisterCommand(Command{
+		Name:            "grant",
+		PrivMsgFunction: module.GrantPrivMsg,
+		Addressed:       true,
+		AllowPrivate:    false,
+		AllowChannel:    true,
+		Role:            RoleAdmin,
+		Admin:           true,
+		Args: []ArgSpec{
+			{Name: "adapter"},
+			{Name: "nickname"},
+			{Name: "role"},
+		},
+		Description: "Give a role (user, operator or admin) to someone.",
+		Examples: []string{
+			"grant irc bob operator",
+		},
+	})
+
+	srv.RegisterCommand(Command{
+		Name:            "revoke",
+		PrivMsgFunction: module.RevokePrivMsg,
+		Addressed:       true,
+		AllowPrivate:    false,
+		AllowChannel:    true,
+		Role:            RoleAdmin,
+		Admin:           true,
+		Args: []ArgSpec{
+			{Name: "adapter"},
+			{Name: "nickname"},
+		},
+		Description: "Remove the global role of someone.",
+		Examples: []string{
+			"revoke irc bob",
+		},
+	})
+
+	srv.RegisterCommand(Command{
+		Name:            "roles",
+		PrivMsgFunction: module.RolesPrivMsg,
+		Addressed:       true,
+		AllowPrivate:    false,
+		AllowChannel:    true,
+		Role:            RoleAdmin,
+		Admin:           true,
+		Description:     "List the global roles.",
+	})
+}
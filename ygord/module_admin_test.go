@@ -0,0 +1,78 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestServerWithAdmin() *Server {
+	srv := CreateTestServer()
+	srv.Config.AdminChannel = "#admin"
+	srv.Roles.Set("test", "alice", RoleAdmin)
+
+	m := &AdminModule{}
+	m.Init(srv)
+
+	return srv
+}
+
+func newAdminTestMessage(channel string, args ...string) *InputMessage {
+	return &InputMessage{
+		Adapter:  "test",
+		Nickname: "alice",
+		ReplyTo:  channel,
+		Args:     args,
+	}
+}
+
+func TestModuleAdmin_GrantRevoke(t *testing.T) {
+	srv := createTestServerWithAdmin()
+
+	srv.RunCommand("grant", newAdminTestMessage("#admin", "irc", "Bob", "operator"))
+	assert.Equal(t, RoleOperator, srv.Roles.Get("irc", "bob"))
+
+	srv.RunCommand("roles", newAdminTestMessage("#admin"))
+
+	srv.RunCommand("revoke", newAdminTestMessage("#admin", "irc", "bob"))
+	assert.Equal(t, RoleUser, srv.Roles.Get("irc", "bob"))
+
+	srv.RunCommand("revoke", newAdminTestMessage("#admin", "irc", "bob"))
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 4) {
+		assert.Equal(t, "#admin", msgs[0].Channel)
+		assert.Equal(t, "irc/Bob is now operator", msgs[0].Body)
+		assert.Equal(t, "roles: irc/bob: operator, test/alice: admin", msgs[1].PlainText())
+		assert.Equal(t, "irc/bob is now user", msgs[2].Body)
+		assert.Equal(t, "error: irc/bob has no role", msgs[3].PlainText())
+	}
+}
+
+func TestModuleAdmin_GrantUnknownRole(t *testing.T) {
+	srv := createTestServerWithAdmin()
+
+	srv.RunCommand("grant", newAdminTestMessage("#admin", "irc", "bob", "god"))
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "error: unknown role 'god' (expected user, operator, admin)", msgs[0].PlainText())
+	}
+	assert.Equal(t, RoleUser, srv.Roles.Get("irc", "bob"))
+}
+
+func TestModuleAdmin_OutsideAdminChannel(t *testing.T) {
+	srv := createTestServerWithAdmin()
+
+	srv.RunCommand("grant", newAdminTestMessage("#test", "irc", "bob", "admin"))
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#test", msgs[0].Channel)
+		assert.Equal(t, "error: permission denied: grant can only be used in the admin channel", msgs[0].PlainText())
+	}
+	assert.Equal(t, RoleUser, srv.Roles.Get("irc", "bob"))
+}
@@ -30,18 +30,13 @@ type AliasModule struct{}
 func (module *AliasModule) AliasPrivMsg(srv *Server, msg *InputMessage) {
 	var outputMsg string
 
-	if len(msg.Args) == 0 {
-		srv.Reply(msg, "usage: alias name [expr ...]")
-		return
-	}
-
-	name := msg.Args[0]
+	name := msg.Params.String("name")
 	alias := srv.Aliases.Get(name)
 
 	// Request the value of an alias.
-	if len(msg.Args) == 1 {
+	if !msg.Params.Has("expr") {
 		if alias == nil {
-			srv.Reply(msg, "error: unknown alias")
+			srv.ReplyError(msg, "unknown alias")
 			return
 		}
 		srv.Reply(msg, fmt.Sprintf("%s=\"%s\" (created by %s on %s)",
@@ -52,19 +47,19 @@ func (module *AliasModule) AliasPrivMsg(srv *Server, msg *InputMessage) {
 	// Set a new alias.
 	cmd := srv.GetCommand(name)
 	if cmd != nil {
-		srv.Reply(msg, fmt.Sprintf("error: '%s' is a"+
+		srv.ReplyError(msg, fmt.Sprintf("'%s' is a"+
 			" command", name))
 		return
 	}
 
-	newValue := strings.Join(msg.Args[1:], " ")
+	newValue := strings.Join(msg.Params.Strings("expr"), " ")
 
 	if alias == nil {
 		var creationTime time.Time
 
 		newName, err := srv.Aliases.GetIncrementedName(name, newValue)
 		if err != nil {
-			srv.Reply(msg, "error: "+err.Error())
+			srv.ReplyError(msg, err.Error())
 			return
 		}
 		if newName != name {
@@ -78,12 +73,14 @@ func (module *AliasModule) AliasPrivMsg(srv *Server, msg *InputMessage) {
 		outputMsg = "no changes"
 	} else {
 		outputMsg = "ok (replaces \"" + alias.Value + "\")"
-		alias.Value = newValue
+		srv.Aliases.Add(alias.Name, newValue, alias.Author,
+			alias.CreationTime)
 	}
 
 	err := srv.Aliases.Save()
 	if err != nil {
-		outputMsg = "error: " + err.Error()
+		srv.ReplyError(msg, err.Error())
+		return
 	}
 
 	srv.Reply(msg, outputMsg)
@@ -91,16 +88,11 @@ func (module *AliasModule) AliasPrivMsg(srv *Server, msg *InputMessage) {
 
 // UnAliasPrivMsg is the message handler for user 'unalias' requests.
 func (module *AliasModule) UnAliasPrivMsg(srv *Server, msg *InputMessage) {
-	if len(msg.Args) != 1 {
-		srv.Reply(msg, "usage: unalias name")
-		return
-	}
-
-	name := msg.Args[0]
+	name := msg.Params.String("name")
 	alias := srv.Aliases.Get(name)
 
 	if alias == nil {
-		srv.Reply(msg, "error: unknown alias")
+		srv.ReplyError(msg, "unknown alias")
 		return
 	}
 
@@ -112,26 +104,20 @@ func (module *AliasModule) UnAliasPrivMsg(srv *Server, msg *InputMessage) {
 // GrepPrivMsg is the message handler for user 'grep' requests.  It lists
 // all the available aliases matching the provided pattern.
 func (module *AliasModule) GrepPrivMsg(srv *Server, msg *InputMessage) {
-	if len(msg.Args) != 1 {
-		srv.Reply(msg, "usage: grep pattern")
-		return
-	}
-
-	results := srv.Aliases.Find(msg.Args[0])
+	results := srv.Aliases.Find(msg.Params.String("pattern"))
 	sort.Strings(results)
 
 	if len(results) == 0 {
-		srv.Reply(msg, "error: no matches found")
+		srv.ReplyError(msg, "no matches found")
 		return
 	}
 
-	found := strings.Join(results, ", ")
-	if len(found) > MaxCharsPerPage {
-		srv.Reply(msg, "error: too many matches, refine your search")
+	if len(strings.Join(results, ", ")) > MaxCharsPerPage {
+		srv.ReplyError(msg, "too many matches, refine your search")
 		return
 	}
 
-	srv.Reply(msg, found)
+	srv.ReplyList(msg, "", results)
 }
 
 // RandomPrivMsg is the message handler for user 'random' requests.  It picks a
@@ -139,18 +125,14 @@ func (module *AliasModule) GrepPrivMsg(srv *Server, msg *InputMessage) {
 func (module *AliasModule) RandomPrivMsg(srv *Server, msg *InputMessage) {
 	var names []string
 
-	switch len(msg.Args) {
-	case 0:
+	if msg.Params.Has("pattern") {
+		names = srv.Aliases.Find(msg.Params.String("pattern"))
+	} else {
 		names = srv.Aliases.Names()
-	case 1:
-		names = srv.Aliases.Find(msg.Args[0])
-	default:
-		srv.Reply(msg, "usage: random [pattern]")
-		return
 	}
 
 	if len(names) <= 0 {
-		srv.Reply(msg, "error: no matches found")
+		srv.ReplyError(msg, "no matches found")
 		return
 	}
 
@@ -158,23 +140,25 @@ func (module *AliasModule) RandomPrivMsg(srv *Server, msg *InputMessage) {
 
 	body, err := srv.Aliases.Resolve(names[idx], 0)
 	if err != nil {
-		srv.Reply(msg, "error: failed to resolve aliases: "+
+		srv.ReplyError(msg, "failed to resolve aliases: "+
 			err.Error())
 		return
 	}
 
 	newmsgs, err := srv.NewMessagesFromBody(body, msg.Depth+1)
 	if err != nil {
-		srv.Reply(msg, "error: failed to expand chosen alias '"+
+		srv.ReplyError(msg, "failed to expand chosen alias '"+
 			names[idx]+"': "+err.Error())
 		return
 	}
 
-	srv.Reply(msg, "/me chooses "+names[idx])
+	srv.ReplyAction(msg, "chooses "+names[idx])
 
 	for _, newmsg := range newmsgs {
 		newmsg.ReplyTo = msg.ReplyTo
 		newmsg.Type = msg.Type
+		newmsg.Adapter = msg.Adapter
+		newmsg.AdapterData = msg.AdapterData
 		newmsg.Nickname = msg.Nickname
 		if newmsg == nil {
 			log.Printf("failed to convert PRIVMSG")
@@ -184,6 +168,30 @@ func (module *AliasModule) RandomPrivMsg(srv *Server, msg *InputMessage) {
 	}
 }
 
+// BrokenAliasesPrivMsg is the message handler for user 'broken-aliases'
+// requests.  It lists the aliases whose media URLs failed the last check.
+func (module *AliasModule) BrokenAliasesPrivMsg(srv *Server, msg *InputMessage) {
+	broken, checked := srv.AliasChecker.Broken(srv.Aliases)
+	if checked.IsZero() {
+		srv.Reply(msg, "the aliases have not been checked yet")
+		return
+	}
+
+	ago := shortDuration(time.Since(checked).Round(time.Minute))
+	if len(broken) == 0 {
+		srv.Reply(msg, "no broken aliases (checked "+ago+" ago)")
+		return
+	}
+
+	var items []string
+	for _, b := range broken {
+		items = append(items, b.Name+" ("+b.Error+")")
+	}
+
+	srv.ReplyList(msg, fmt.Sprintf("%d broken aliases (checked %s ago)",
+		len(broken), ago), items)
+}
+
 // Init registers all the commands for this module.
 func (module *AliasModule) Init(srv *Server) {
 	srv.RegisterCommand(Command{
@@ -192,6 +200,28 @@ func (module *AliasModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Args: []ArgSpec{
+			{Name: "name"},
+			{Name: "expr", Optional: true, Variadic: true},
+		},
+		Description: "Show the value of an alias or define it.",
+		Examples: []string{
+			"alias coffee",
+			"alias coffee play http://example.com/coffee.mp3",
+		},
+	})
+
+	srv.RegisterCommand(Command{
+		Name:            "broken-aliases",
+		PrivMsgFunction: module.BrokenAliasesPrivMsg,
+		Addressed:       true,
+		AllowPrivate:    true,
+		AllowChannel:    true,
+		Description: "List the aliases whose media could not be " +
+			"played during the last check.",
+		Examples: []string{
+			"broken-aliases",
+		},
 	})
 
 	srv.RegisterCommand(Command{
@@ -200,6 +230,11 @@ func (module *AliasModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Args:            []ArgSpec{{Name: "pattern"}},
+		Description:     "List the aliases matching a pattern.",
+		Examples: []string{
+			"grep coffee",
+		},
 	})
 
 	srv.RegisterCommand(Command{
@@ -208,6 +243,12 @@ func (module *AliasModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Args:            []ArgSpec{{Name: "pattern", Optional: true}},
+		Description:     "Run a random alias, optionally matching a pattern.",
+		Examples: []string{
+			"random",
+			"random coffee",
+		},
 	})
 
 	srv.RegisterCommand(Command{
@@ -216,6 +257,11 @@ func (module *AliasModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Args:            []ArgSpec{{Name: "name"}},
+		Description:     "Delete an alias.",
+		Examples: []string{
+			"unalias coffee",
+		},
 	})
 
 	srv.RegisterCommand(Command{
@@ -224,5 +270,10 @@ func (module *AliasModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    true,
 		AllowChannel:    true,
+		Args:            []ArgSpec{{Name: "pattern"}},
+		Description:     "List the aliases matching a pattern (same as grep).",
+		Examples: []string{
+			"aliases coffee",
+		},
 	})
 }
@@ -21,9 +21,9 @@ func createTestServerClientAndAliasModule() (*Server, *Client, *AliasModule) {
 }
 
 func TestModuleAliasUsageOnNoParams(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -38,9 +38,9 @@ func TestModuleAliasUsageOnNoParams(t *testing.T) {
 }
 
 func TestModuleAliasValueNotFound(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key"},
 	})
@@ -48,18 +48,18 @@ func TestModuleAliasValueNotFound(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: unknown alias", msgs[0].Body)
+		assert.Equal(t, "error: unknown alias", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleAliasValueFound(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("key", "value", "human", fakeNow)
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key"},
 	})
@@ -74,11 +74,11 @@ func TestModuleAliasValueFound(t *testing.T) {
 }
 
 func TestModuleAliasValueFoundWithPercent(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("60%", "value", "human", fakeNow)
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"60%"},
 	})
@@ -93,12 +93,12 @@ func TestModuleAliasValueFoundWithPercent(t *testing.T) {
 }
 
 func TestModuleAliasValueFoundNested(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("key", "value", "human", fakeNow)
 	srv.Aliases.Add("value", "null", "robot", fakeNow)
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key"},
 	})
@@ -113,11 +113,11 @@ func TestModuleAliasValueFoundNested(t *testing.T) {
 }
 
 func TestModuleAliasChange(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("key", "value", "human", fakeNow)
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key", "other value"},
 	})
@@ -132,9 +132,9 @@ func TestModuleAliasChange(t *testing.T) {
 }
 
 func TestModuleAliasCreate(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key", "value"},
 	})
@@ -149,13 +149,13 @@ func TestModuleAliasCreate(t *testing.T) {
 }
 
 func TestModuleAliasCreateIncremental(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key#", "value1"},
 	})
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key#", "value2"},
 	})
@@ -172,13 +172,13 @@ func TestModuleAliasCreateIncremental(t *testing.T) {
 }
 
 func TestModuleAliasCreateIncrementalDupeValue(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key#", "value"},
 	})
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key#", "value"},
 	})
@@ -188,16 +188,16 @@ func TestModuleAliasCreateIncrementalDupeValue(t *testing.T) {
 		assert.Equal(t, "#test", msgs[0].Channel)
 		assert.Equal(t, "ok (created as \"key1\")", msgs[0].Body)
 		assert.Equal(t, "#test", msgs[1].Channel)
-		assert.Equal(t, "error: already exists as \"key1\"", msgs[1].Body)
+		assert.Equal(t, "error: already exists as \"key1\"", msgs[1].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleAliasCreateIncrementalTooManyHashes(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.AliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("alias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"key##", "value"},
 	})
@@ -205,85 +205,95 @@ func TestModuleAliasCreateIncrementalTooManyHashes(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: too many '#'", msgs[0].Body)
+		assert.Equal(t, "error: too many '#'", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleAliases(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 	srv.Aliases.Add("bar", "bar-value", "human", fakeNow)
+	srv.Aliases.Add("baz", "baz-value", "human", fakeNow)
 	srv.Aliases.Add("zzz", "zzz-value", "human", fakeNow)
 
-	module.AliasesPrivMsg(srv, &InputMessage{
+	srv.RunCommand("aliases", &InputMessage{
+		Adapter: "test",
 		ReplyTo: "#test",
-		Args:    []string{},
+		Args:    []string{"ba"},
 	})
+	srv.DispatchOutputQueue()
 
-	msgs := srv.FlushOutputQueue()
+	msgs := srv.GetTestAdapter().Flush()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "known aliases: bar, foo, zzz", msgs[0].Body)
+		assert.Equal(t, OutputMsgTypeList, msgs[0].Type)
+		assert.Equal(t, "bar, baz", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleAliasesPages(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	for i := 0; i < 35; i++ {
+	// All the results fit on a single page.
+	for i := 0; i < 25; i++ {
 		key := fmt.Sprintf("foobarfoobar%d", i)
 		srv.Aliases.Add(key, "foo-value", "human", fakeNow)
 	}
 
-	module.AliasesPrivMsg(srv, &InputMessage{
+	srv.RunCommand("aliases", &InputMessage{
+		Adapter: "test",
 		ReplyTo: "#test",
-		Args:    []string{},
+		Args:    []string{"foobar"},
 	})
+	srv.DispatchOutputQueue()
 
-	msgs := srv.FlushOutputQueue()
-	if assert.Len(t, msgs, 2) {
+	msgs := srv.GetTestAdapter().Flush()
+	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "known aliases: foobarfoobar0, foobarfoobar1, foobarfoobar10, foobarfoobar11, foobarfoobar12, foobarfoobar13, foobarfoobar14, foobarfoobar15, foobarfoobar16, foobarfoobar17, foobarfoobar18, foobarfoobar19, foobarfoobar2, foobarfoobar20, foobarfoobar21, foobarfoobar22, foobarfoobar23, foobarfoobar24, foobarfoobar25, foobarfoobar26, foobarfoobar27, foobarfoobar28, foobarfoobar29, foobarfoobar3, foobarfoobar30, foobarfoobar31, foobarfoobar32, foobarfoobar33", msgs[0].Body)
-		assert.Equal(t, "#test", msgs[1].Channel)
-		assert.Equal(t, "... foobarfoobar34, foobarfoobar4, foobarfoobar5, foobarfoobar6, foobarfoobar7, foobarfoobar8, foobarfoobar9", msgs[1].Body)
+		assert.Len(t, msgs[0].Items, 25)
+		assert.Equal(t, "foobarfoobar0", msgs[0].Items[0])
+		assert.Equal(t, "foobarfoobar9", msgs[0].Items[24])
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleAliasesTooMany(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	for i := 0; i < 100; i++ {
 		key := fmt.Sprintf("foobarfoobar%d", i)
 		srv.Aliases.Add(key, "foo-value", "human", fakeNow)
 	}
 
-	module.AliasesPrivMsg(srv, &InputMessage{
+	srv.RunCommand("aliases", &InputMessage{
+		Adapter: "test",
 		ReplyTo: "#test",
-		Args:    []string{},
+		Args:    []string{"foobar"},
 	})
+	srv.DispatchOutputQueue()
 
-	msgs := srv.FlushOutputQueue()
+	msgs := srv.GetTestAdapter().Flush()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: too many results, use grep", msgs[0].Body)
+		assert.Equal(t, "error: too many matches, refine your search",
+			msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleUnaliasUsage(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 
-	module.UnAliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("unalias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -298,11 +308,11 @@ func TestModuleUnaliasUsage(t *testing.T) {
 }
 
 func TestModuleUnaliasExisting(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 
-	module.UnAliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("unalias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"foo"},
 	})
@@ -319,11 +329,11 @@ func TestModuleUnaliasExisting(t *testing.T) {
 }
 
 func TestModuleUnaliasNonExisting(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 
-	module.UnAliasPrivMsg(srv, &InputMessage{
+	srv.RunCommand("unalias", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"bar"},
 	})
@@ -331,16 +341,16 @@ func TestModuleUnaliasNonExisting(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: unknown alias", msgs[0].Body)
+		assert.Equal(t, "error: unknown alias", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleGrepUsage(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
-	module.GrepPrivMsg(srv, &InputMessage{
+	srv.RunCommand("grep", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -355,13 +365,13 @@ func TestModuleGrepUsage(t *testing.T) {
 }
 
 func TestModuleGrep(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 	srv.Aliases.Add("baz", "baz-value", "human", fakeNow)
 	srv.Aliases.Add("zzz", "zzz-value", "human", fakeNow)
 
-	module.GrepPrivMsg(srv, &InputMessage{
+	srv.RunCommand("grep", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"z"},
 	})
@@ -369,19 +379,19 @@ func TestModuleGrep(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "baz, zzz", msgs[0].Body)
+		assert.Equal(t, "baz, zzz", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleGrepNoResult(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "foo-value", "human", fakeNow)
 	srv.Aliases.Add("bar", "bar-value", "human", fakeNow)
 
-	module.GrepPrivMsg(srv, &InputMessage{
+	srv.RunCommand("grep", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"z"},
 	})
@@ -389,21 +399,21 @@ func TestModuleGrepNoResult(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: no matches found", msgs[0].Body)
+		assert.Equal(t, "error: no matches found", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleGrepTooManyResults(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	for i := 0; i < 100; i++ {
 		key := fmt.Sprintf("foo%d", i)
 		srv.Aliases.Add(key, "foo-value", "human", fakeNow)
 	}
 
-	module.GrepPrivMsg(srv, &InputMessage{
+	srv.RunCommand("grep", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"foo"},
 	})
@@ -411,20 +421,20 @@ func TestModuleGrepTooManyResults(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: too many matches, refine your search", msgs[0].Body)
+		assert.Equal(t, "error: too many matches, refine your search", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
 
 func TestModuleRandom(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "play foo.mp3", "human", fakeNow)
 	srv.Aliases.Add("bar", "play bar.mp3", "human", fakeNow)
 	srv.Aliases.Add("zzz", "play zzz.mp3", "human", fakeNow)
 
-	module.RandomPrivMsg(srv, &InputMessage{
+	srv.RunCommand("random", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"o"},
 	})
@@ -441,12 +451,12 @@ func TestModuleRandom(t *testing.T) {
 }
 
 func TestModuleRandomNotFound(t *testing.T) {
-	srv, client, module := createTestServerClientAndAliasModule()
+	srv, client, _ := createTestServerClientAndAliasModule()
 
 	srv.Aliases.Add("foo", "play foo.mp3", "human", fakeNow)
 	srv.Aliases.Add("bar", "play bar.mp3", "human", fakeNow)
 
-	module.RandomPrivMsg(srv, &InputMessage{
+	srv.RunCommand("random", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"w"},
 	})
@@ -454,7 +464,7 @@ func TestModuleRandomNotFound(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: no matches found", msgs[0].Body)
+		assert.Equal(t, "error: no matches found", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
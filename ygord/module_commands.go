@@ -5,11 +5,6 @@
 
 package main
 
-import (
-	"sort"
-	"strings"
-)
-
 // CommandsModule controls the 'commands' command which lists all the known
 // commands publicly.
 type CommandsModule struct{}
@@ -18,20 +13,11 @@ type CommandsModule struct{}
 func (module *CommandsModule) PrivMsg(srv *Server, msg *InputMessage) {
 	var names []string
 
-	for name, cmd := range srv.RegisteredCommands {
-		// Attempt to only return user commands (skip minion commands).
-		if cmd.PrivMsgFunction == nil {
-			continue
-		}
-
-		names = append(names, name)
+	for _, cmd := range srv.GetUserCommands() {
+		names = append(names, cmd.Name)
 	}
 
-	sort.Strings(names)
-
-	found := strings.Join(names, ", ")
-
-	srv.Reply(msg, found)
+	srv.ReplyList(msg, "", names)
 }
 
 // Init registers all the commands for this module.
@@ -42,5 +28,6 @@ func (module *CommandsModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Description:     "List all the commands.",
 	})
 }
@@ -18,7 +18,7 @@ func TestModuleCommands(t *testing.T) {
 
 	m := &CommandsModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
+	srv.RunCommand("commands", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -26,7 +26,7 @@ func TestModuleCommands(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "commands, nop", msgs[0].Body)
+		assert.Equal(t, "commands, nop", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
@@ -0,0 +1,75 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This module provides the 'help' command, describing the commands and
+// aliases.
+
+package main
+
+import (
+	"strings"
+)
+
+// HelpModule controls the 'help' command.
+type HelpModule struct{}
+
+// PrivMsg is the message handler for user 'help' requests.  Without argument,
+// all the commands are listed.  Given the name of a command, its usage,
+// description and examples are shown.  Given the name of an alias, its
+// expansion is shown.
+func (module *HelpModule) PrivMsg(srv *Server, msg *InputMessage) {
+	if !msg.Params.Has("command") {
+		var names []string
+		for _, cmd := range srv.GetUserCommands() {
+			names = append(names, cmd.Name)
+		}
+		srv.ReplyList(msg, "commands (try 'help command')", names)
+		return
+	}
+
+	name := msg.Params.String("command")
+
+	if cmd := srv.GetCommand(name); cmd != nil && cmd.PrivMsgFunction != nil {
+		lines := []string{"usage: " + cmd.UsageText()}
+		if cmd.Description != "" {
+			lines = append(lines, cmd.Description)
+		}
+		if cmd.Role > RoleUser {
+			lines = append(lines, "requires: "+cmd.Role.String())
+		}
+		for _, example := range cmd.Examples {
+			lines = append(lines, "example: "+example)
+		}
+		srv.Reply(msg, strings.Join(lines, "\n"))
+		return
+	}
+
+	if alias := srv.Aliases.Get(name); alias != nil {
+		text := name + " is an alias for: " + alias.Value
+		expanded, err := srv.Aliases.Resolve(name, 0)
+		if err == nil && expanded != alias.Value {
+			text += "\nexpands to: " + expanded
+		}
+		srv.Reply(msg, text)
+		return
+	}
+
+	srv.ReplyError(msg, "unknown command or alias: "+name)
+}
+
+// Init registers all the commands for this module.
+func (module *HelpModule) Init(srv *Server) {
+	srv.RegisterCommand(Command{
+		Name:            "help",
+		PrivMsgFunction: module.PrivMsg,
+		Addressed:       true,
+		AllowPrivate:    true,
+		AllowChannel:    true,
+		Args:            []ArgSpec{{Name: "command", Optional: true}},
+		Description:     "Describe a command or an alias.",
+		Examples: []string{
+			"help",
+			"help play",
+		},
+	})
+}
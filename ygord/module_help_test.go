@@ -0,0 +1,96 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestServerWithHelp() *Server {
+	srv := CreateTestServer()
+
+	(&NopModule{}).Init(srv)
+	(&PlayModule{}).Init(srv)
+	(&HelpModule{}).Init(srv)
+
+	return srv
+}
+
+func TestModuleHelpList(t *testing.T) {
+	srv := createTestServerWithHelp()
+
+	srv.RunCommand("help", &InputMessage{ReplyTo: "#test"})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "commands (try 'help command'): help, nop, play",
+			msgs[0].PlainText())
+	}
+}
+
+func TestModuleHelpCommand(t *testing.T) {
+	srv := createTestServerWithHelp()
+
+	srv.RunCommand("help", &InputMessage{
+		ReplyTo: "#test",
+		Args:    []string{"play"},
+	})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "usage: play url [range]\n"+
+			"Play an audio or video track on the channel minions.\n"+
+			"example: play http://example.com/freshpots.mp3\n"+
+			"example: play https://www.youtube.com/watch?v=dQw4w9WgXcQ 0:30\n"+
+			"example: play https://www.youtube.com/watch?v=dQw4w9WgXcQ 1:23-2:05\n"+
+			"example: play https://youtu.be/dQw4w9WgXcQ?t=1m30s",
+			msgs[0].Body)
+	}
+}
+
+func TestModuleHelpAlias(t *testing.T) {
+	srv := createTestServerWithHelp()
+
+	srv.Aliases.Add("coffee", "bean 0:30", "human", fakeNow)
+	srv.Aliases.Add("bean", "play bean.mp3", "human", fakeNow)
+
+	srv.RunCommand("help", &InputMessage{
+		ReplyTo: "#test",
+		Args:    []string{"coffee"},
+	})
+	srv.RunCommand("help", &InputMessage{
+		ReplyTo: "#test",
+		Args:    []string{"bean"},
+	})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "coffee is an alias for: bean 0:30\n"+
+			"expands to: play bean.mp3 0:30", msgs[0].Body)
+		assert.Equal(t, "bean is an alias for: play bean.mp3",
+			msgs[1].Body)
+	}
+}
+
+func TestModuleHelpUnknown(t *testing.T) {
+	srv := createTestServerWithHelp()
+
+	srv.RunCommand("help", &InputMessage{
+		ReplyTo: "#test",
+		Args:    []string{"wat"},
+	})
+	srv.RunCommand("help", &InputMessage{
+		ReplyTo: "#test",
+		Args:    []string{"wat", "wat"},
+	})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "error: unknown command or alias: wat",
+			msgs[0].PlainText())
+		assert.Equal(t, "usage: help [command]", msgs[1].Body)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This module provides the 'history' command, listing the most recent
+// commands from the audit log.
+//
+
+package main
+
+import (
+	"strings"
+)
+
+const (
+	// HistoryDefaultCount is the number of entries shown by default.
+	HistoryDefaultCount = 10
+
+	// HistoryMaxCount is the maximum number of entries shown in chat.
+	HistoryMaxCount = 50
+)
+
+// HistoryModule controls the 'history' command.
+type HistoryModule struct{}
+
+// PrivMsg is the message handler for user 'history' requests.  Only the
+// commands from the same channel are listed, except in the AdminChannel
+// where all of them are.
+func (module *HistoryModule) PrivMsg(srv *Server, msg *InputMessage) {
+	count := HistoryDefaultCount
+	if msg.Params.Has("n") {
+		count = msg.Params.Int("n")
+	}
+	if count > HistoryMaxCount {
+		count = HistoryMaxCount
+	}
+
+	channel := msg.ReplyTo
+	if srv.Config.AdminChannel != "" && channel == srv.Config.AdminChannel {
+		channel = ""
+	}
+
+	var lines []string
+	for _, entry := range srv.AuditLog.Recent(count, channel) {
+		lines = append(lines, entry.String())
+	}
+
+	if len(lines) == 0 {
+		srv.Reply(msg, "no history")
+		return
+	}
+
+	srv.Reply(msg, strings.Join(lines, "\n"))
+}
+
+// Init registers all the commands for this module.
+func (module *HistoryModule) Init(srv *Server) {
+	srv.RegisterCommand(Command{
+		Name:            "history",
+		PrivMsgFunction: module.PrivMsg,
+		Addressed:       true,
+		AllowPrivate:    false,
+		AllowChannel:    true,
+		Args: []ArgSpec{
+			{Name: "n", Type: ArgTypeInteger, Optional: true},
+		},
+		Description: "List the most recent commands of the channel.",
+		Examples: []string{
+			"history",
+			"history 25",
+		},
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestServerWithHistory() *Server {
+	srv := CreateTestServer()
+	srv.Config.AdminChannel = "#admin"
+
+	for _, module := range []Module{&HistoryModule{}, &PlayModule{}, &SkipModule{}} {
+		module.Init(srv)
+	}
+
+	return srv
+}
+
+func TestModuleHistory(t *testing.T) {
+	srv := createTestServerWithHistory()
+	client := srv.RegisterClient("dummy", "test")
+
+	srv.RunCommand("play", &InputMessage{
+		Adapter:  "test",
+		Nickname: "alice",
+		ReplyTo:  "#test",
+		Body:     "play http://10.0.0.1/freshpots.mp3",
+		Args:     []string{"http://10.0.0.1/freshpots.mp3"},
+	})
+	srv.FlushMediaQueue()
+	srv.RunCommand("skip", &InputMessage{
+		Adapter:  "test",
+		Nickname: "bob",
+		ReplyTo:  "#lobby",
+		Body:     "skip",
+	})
+	assert.Len(t, client.FlushQueue(), 1)
+	srv.FlushOutputQueue()
+
+	entries := srv.AuditLog.Recent(10, "")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "skip", entries[0].Command)
+		if assert.Len(t, entries[0].ClientCommands, 1) {
+			assert.Equal(t, "skip", entries[0].ClientCommands[0].Name)
+			assert.Empty(t, entries[0].ClientCommands[0].Clients)
+		}
+		assert.Equal(t, "play", entries[1].Command)
+		assert.Equal(t, "alice", entries[1].Nickname)
+		assert.Equal(t, "#test", entries[1].Channel)
+		if assert.Len(t, entries[1].ClientCommands, 1) {
+			assert.Equal(t, "play", entries[1].ClientCommands[0].Name)
+			assert.Equal(t, []string{client.ID}, entries[1].ClientCommands[0].Clients)
+		}
+	}
+
+	// Only the channel history is shown, except in the admin channel.
+	srv.RunCommand("history", &InputMessage{ReplyTo: "#test", Body: "history"})
+	srv.RunCommand("history", &InputMessage{ReplyTo: "#admin", Args: []string{"2"}})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 2) {
+		lines := strings.Split(msgs[0].Body, "\n")
+		if assert.Len(t, lines, 1) {
+			assert.Contains(t, lines[0], " test/alice in #test: play http://10.0.0.1/freshpots.mp3")
+		}
+
+		lines = strings.Split(msgs[1].Body, "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Contains(t, lines[0], " / in #test: history")
+			assert.Contains(t, lines[1], " test/bob in #lobby: skip")
+		}
+	}
+}
+
+func TestModuleHistoryEmpty(t *testing.T) {
+	srv := createTestServerWithHistory()
+
+	srv.RunCommand("history", &InputMessage{ReplyTo: "#test"})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "no history", msgs[0].Body)
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	srv := createTestServerWithHistory()
+	srv.AuditLog.Append(newTestAuditEntry("#test", "play a.mp3"))
+	srv.AuditLog.Append(newTestAuditEntry("#lobby", "play b.mp3"))
+	srv.AuditLog.Append(newTestAuditEntry("#test", "skip"))
+
+	handler := &HistoryHandler{srv}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, &http.Request{
+		Header: http.Header{},
+		Form:   url.Values{"n": {"5"}, "channel": {"#test"}},
+	})
+
+	var response HistoryResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if assert.Nil(t, err) && assert.Len(t, response.Entries, 2) {
+		assert.Equal(t, "skip", response.Entries[0].Body)
+		assert.Equal(t, "play a.mp3", response.Entries[1].Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, &http.Request{
+		Header: http.Header{},
+		Form:   url.Values{"n": {"zero"}},
+	})
+	assert.Equal(t, 400, recorder.Code)
+}
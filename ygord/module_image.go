@@ -4,7 +4,18 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"time"
+)
+
+var (
+	imageFormats = []string{
+		"vimeo",
+		"youtube",
+		"video",
+		"img",
+		"web",
+	}
 )
 
 // ImageModule controls the 'image' command.
@@ -14,41 +25,37 @@ type ImageModule struct {
 
 // PrivMsg is the message handler for user 'image' requests.
 func (module *ImageModule) PrivMsg(srv *Server, msg *InputMessage) {
-	usage := "usage: image url [end]"
-
-	// Validate the command's usage, and get back a map representing the media
-	// item that was passed, along with it's start and end bounds.
-	mediaItem, err := parseArgList(msg.Args)
-	if err != nil {
-		srv.Reply(msg, usage)
-		return
-	}
+	mediaItem := newMediaItem(msg.Params)
 
-	media, err := NewMedia(srv, mediaItem, "imageTrack", true, true,
-		[]string{
-			"vimeo",
-			"youtube",
-			"video",
-			"img",
-			"web",
-		})
-	if err != nil {
-		srv.Reply(msg, err.Error())
-		return
-	}
+	srv.ResolveMedia(msg, func(ctx context.Context) (*Media, error) {
+		return NewMediaWithContext(ctx, srv, mediaItem, "imageTrack",
+			true, true, imageFormats)
+	}, func(media *Media, err error) {
+		if err != nil {
+			srv.Warningf("%s: media error for %s: %s", msg.Command,
+				mediaItem["url"], err.Error())
+			srv.ReplyError(msg, err.Error())
+			return
+		}
 
-	// If a Mattermost message requests an image, it will be displayed in
-	// the channel.  We also check the Depth to make sure we are not
-	// displaying images right after Mattermost parsed a URL.  So if a user
-	// calls ygor: image directly, that should be Depth 1, however if a
-	// user uses an alias, that should be at least Depth 2 which will
-	// trigger the following.
-	if media.Format == "img" && msg.IsMattermost() && msg.Depth > 1 {
-		srv.Reply(msg, fmt.Sprintf("![](%s)", media.Src))
-	}
+		// If a Mattermost message requests an image, it will be
+		// displayed in the channel.  We also check the Depth to make
+		// sure we are not displaying images right after Mattermost
+		// parsed a URL.  So if a user calls ygor: image directly, that
+		// should be Depth 1, however if a user uses an alias, that
+		// should be at least Depth 2 which will trigger the following.
+		if media.Format == "img" && msg.IsMattermost() && msg.Depth > 1 {
+			srv.ReplyMediaCard(msg, "", Attachment{
+				Fallback: media.GetURL(),
+				ImageURL: media.GetURL(),
+			})
+		} else {
+			srv.ReplyNowPlaying(msg, media)
+		}
 
-	// Send the command to the connected minions.
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"image", media})
+		// Send the command to the connected minions.
+		srv.SendToMinions(msg, ClientCommand{"image", media})
+	})
 }
 
 // Init registers all the commands for this module.
@@ -59,5 +66,17 @@ func (module ImageModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Immediate:       true,
+		RateLimit:       RateLimit{Quota: 10, Period: time.Minute},
+		Args: []ArgSpec{
+			{Name: "url", Type: ArgTypeURL},
+			{Name: "range", Type: ArgTypeTimeRange, Optional: true},
+		},
+		Description: "Display an image or a muted video on the minions.",
+		Examples: []string{
+			"image http://example.com/cat.gif",
+			"image https://www.youtube.com/watch?v=dQw4w9WgXcQ 0:10",
+			"image https://www.youtube.com/watch?v=dQw4w9WgXcQ 1m-1m10s",
+		},
 	})
 }
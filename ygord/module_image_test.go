@@ -15,15 +15,15 @@ func TestModuleImage_Usage(t *testing.T) {
 
 	m := &ImageModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
+	srv.RunCommand("image", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
 
-	msgs := srv.FlushIRCOutputQueue()
+	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "usage: image url [end]", msgs[0].Body)
+		assert.Equal(t, "usage: image url [range]", msgs[0].Body)
 	}
 
 	assert.Empty(t, client.FlushQueue())
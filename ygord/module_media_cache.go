@@ -0,0 +1,98 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This module provides the 'media-cache' command, used by the admins to
+// inspect the cache of the resolved media and to flush URLs which changed
+// since they were resolved.
+//
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MediaCacheModule controls the 'media-cache' command.
+type MediaCacheModule struct{}
+
+// PrivMsg is the message handler for user 'media-cache' requests.
+func (module *MediaCacheModule) PrivMsg(srv *Server, msg *InputMessage) {
+	link := msg.Params.String("url")
+
+	switch action := msg.Params.String("action"); action {
+	case "":
+		stats := srv.MediaCache.Stats()
+		srv.Reply(msg, fmt.Sprintf("%d cached URLs (%d failed), %d hits, "+
+			"%d misses", stats.Entries, stats.Failures, stats.Hits,
+			stats.Misses))
+	case "show":
+		if link == "" {
+			srv.ReplyError(msg, "show requires a URL")
+			return
+		}
+		entry, ok := srv.MediaCache.Peek(link)
+		if !ok {
+			srv.Reply(msg, link+" is not cached")
+			return
+		}
+		srv.Reply(msg, describeMediaCacheEntry(entry, time.Now()))
+	case "flush":
+		count := srv.MediaCache.Flush(link)
+		srv.Reply(msg, fmt.Sprintf("flushed %d cached URLs", count))
+	default:
+		srv.ReplyError(msg, "unknown action '"+action+
+			"' (expected show, flush)")
+	}
+}
+
+// describeMediaCacheEntry returns a one-line summary of the entry.
+func describeMediaCacheEntry(entry *MediaCacheEntry, now time.Time) string {
+	var text string
+	if entry.Error != "" {
+		text = entry.URL + " failed: " + entry.Error
+	} else {
+		text = entry.URL + " is " + entry.Format + " " + entry.Src
+		if entry.Metadata.Title != "" {
+			text += " (" + entry.Metadata.Title + ")"
+		}
+	}
+
+	if now.After(entry.Expires) {
+		return text + ", expired"
+	}
+
+	remaining := entry.Expires.Sub(now)
+	if remaining > time.Minute {
+		remaining = remaining.Round(time.Minute)
+	} else {
+		remaining = remaining.Round(time.Second)
+	}
+
+	return text + ", expires in " + shortDuration(remaining)
+}
+
+// Init registers all the commands for this module.
+func (module *MediaCacheModule) Init(srv *Server) {
+	srv.RegisterCommand(Command{
+		Name:            "media-cache",
+		PrivMsgFunction: module.PrivMsg,
+		Addressed:       true,
+		AllowPrivate:    false,
+		AllowChannel:    true,
+		Role:            RoleAdmin,
+		Admin:           true,
+		Args: []ArgSpec{
+			{Name: "action", Optional: true},
+			{Name: "url", Type: ArgTypeURL, Optional: true},
+		},
+		Description: "Show the media cache statistics, show or flush " +
+			"a cached URL, or flush the whole cache.",
+		Examples: []string{
+			"media-cache",
+			"media-cache show http://example.com/cat.gif",
+			"media-cache flush http://example.com/cat.gif",
+			"media-cache flush",
+		},
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleMediaCache(t *testing.T) {
+	srv := createTestServerWithAdmin()
+	m := &MediaCacheModule{}
+	m.Init(srv)
+
+	srv.MediaCache.Set(time.Now(), &MediaCacheEntry{
+		URL:      "http://example.com/pots",
+		Src:      "http://example.com/pots",
+		Format:   "web",
+		Metadata: MediaMetadata{Title: "Fresh Pots"},
+	})
+	srv.MediaCache.Set(time.Now(), &MediaCacheEntry{
+		URL:   "http://example.com/dog.gif",
+		Error: "response status code is 404",
+	})
+
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "show", "http://example.com/pots"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "show", "http://example.com/dog.gif"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "flush", "http://example.com/dog.gif"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "show", "http://example.com/dog.gif"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "flush"))
+	srv.RunCommand("media-cache", newAdminTestMessage("#admin", "wat"))
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 7) {
+		assert.Equal(t, "2 cached URLs (1 failed), 0 hits, 0 misses", msgs[0].Body)
+		assert.Equal(t, "http://example.com/pots is web http://example.com/pots (Fresh Pots), expires in 24h", msgs[1].Body)
+		assert.Equal(t, "http://example.com/dog.gif failed: response status code is 404, expires in 10m", msgs[2].Body)
+		assert.Equal(t, "flushed 1 cached URLs", msgs[3].Body)
+		assert.Equal(t, "http://example.com/dog.gif is not cached", msgs[4].Body)
+		assert.Equal(t, "flushed 1 cached URLs", msgs[5].Body)
+		assert.Equal(t, "unknown action 'wat' (expected show, flush)", msgs[6].Body)
+	}
+}
+
+func TestModuleMediaCache_AdminOnly(t *testing.T) {
+	srv := createTestServerWithAdmin()
+	m := &MediaCacheModule{}
+	m.Init(srv)
+
+	srv.RunCommand("media-cache", newAdminTestMessage("#test", "flush"))
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "permission denied: media-cache can only be used in the admin channel", msgs[0].Body)
+	}
+}
@@ -20,5 +20,9 @@ func (module NopModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Args: []ArgSpec{
+			{Name: "args", Optional: true, Variadic: true},
+		},
+		Description: "Do nothing, e.g. to neutralize an alias.",
 	})
 }
@@ -15,8 +15,8 @@ func TestModuleNop(t *testing.T) {
 
 	m := &NopModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{ReplyTo: "#test"})
+	srv.RunCommand("nop", &InputMessage{ReplyTo: "#test"})
 
-	assert.Empty(t, srv.FlushIRCOutputQueue())
+	assert.Empty(t, srv.FlushOutputQueue())
 	assert.Empty(t, client.FlushQueue())
 }
@@ -26,7 +26,7 @@ type PingModule struct {
 // timestamp.  This timestamp will be used to validated incoming ping
 // responses.
 func (module *PingModule) PrivMsg(srv *Server, msg *InputMessage) {
-	srv.Reply(msg, "error: not implemented")
+	srv.ReplyError(msg, "not implemented")
 	return
 
 	//	if len(module.PingStartTimes) > 0 {
@@ -109,6 +109,7 @@ func (module *PingModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    true,
 		AllowChannel:    true,
+		Description:     "Not implemented yet.",
 	})
 	// srv.RegisterCommand(Command{
 	// 	Name:              "pong",
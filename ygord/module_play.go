@@ -3,6 +3,21 @@
 
 package main
 
+import (
+	"context"
+	"time"
+)
+
+var (
+	playFormats = []string{
+		"soundcloud",
+		"vimeo",
+		"youtube",
+		"video",
+		"audio",
+	}
+)
+
 // PlayModule controls the 'play' command.
 type PlayModule struct {
 	*Server
@@ -10,31 +25,75 @@ type PlayModule struct {
 
 // PrivMsg is the message handler for user 'play' requests.
 func (module *PlayModule) PrivMsg(srv *Server, msg *InputMessage) {
-	usage := "usage: play url [end]"
+	mediaItem := newMediaItem(msg.Params)
+
+	srv.ResolveMedia(msg, func(ctx context.Context) (*Media, error) {
+		return NewMediaWithContext(ctx, srv, mediaItem, "playTrack",
+			false, false, playFormats)
+	}, func(media *Media, err error) {
+		if err != nil {
+			srv.Warningf("%s: media error for %s: %s", msg.Command,
+				mediaItem["url"], err.Error())
+			srv.ReplyError(msg, err.Error())
+			return
+		}
+
+		// Send the command to the connected minions.
+		srv.SendToMinions(msg, ClientCommand{"play", media})
 
-	// Validate the command's usage, and get back a map representing the media
-	// item that was passed, along with it's start and end bounds.
-	mediaItem, err := parseArgList(msg.Args)
-	if err != nil {
-		srv.Reply(msg, usage)
+		srv.ReplyNowPlaying(msg, media)
+	})
+}
+
+// ReplyNowPlaying describes the media being played, Mattermost users get a
+// card, the others a single line (e.g. "now playing: Fresh Pots (3:42)").
+func (srv *Server) ReplyNowPlaying(msg *InputMessage, media *Media) {
+	if msg.IsMattermost() {
+		srv.ReplyMediaCard(msg, "", NewPlayCard(msg, media))
 		return
 	}
+	srv.Reply(msg, NowPlayingText(media))
+}
+
+// NewPlayCard creates an attachment describing the media being played.
+func NewPlayCard(msg *InputMessage, media *Media) Attachment {
+	title := media.GetTitle()
 
-	media, err := NewMedia(srv, mediaItem, "playTrack", false, false,
-		[]string{
-			"soundcloud",
-			"vimeo",
-			"youtube",
-			"video",
-			"audio",
+	card := Attachment{
+		Fallback:  NowPlayingText(media),
+		Title:     title,
+		TitleLink: media.GetURL(),
+		ThumbURL:  media.GetThumbnail(),
+		Fields: []AttachmentField{
+			{Title: "Source", Value: media.GetSource(), Short: true},
+		},
+	}
+
+	if artist := media.GetArtist(); artist != "" {
+		card.Fields = append(card.Fields, AttachmentField{
+			Title: "Artist",
+			Value: artist,
+			Short: true,
+		})
+	}
+
+	if duration := media.GetDuration(); duration > 0 {
+		card.Fields = append(card.Fields, AttachmentField{
+			Title: "Duration",
+			Value: FormatTimestamp(duration),
+			Short: true,
+		})
+	}
+
+	if msg.Nickname != "" {
+		card.Fields = append(card.Fields, AttachmentField{
+			Title: "Requested by",
+			Value: msg.Nickname,
+			Short: true,
 		})
-	if err != nil {
-		srv.Reply(msg, err.Error())
-		return
 	}
 
-	// Send the command to the connected minions.
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"play", media})
+	return card
 }
 
 // Init registers all the commands for this module.
@@ -45,5 +104,18 @@ func (module PlayModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Immediate:       true,
+		RateLimit:       RateLimit{Quota: 10, Period: time.Minute},
+		Args: []ArgSpec{
+			{Name: "url", Type: ArgTypeURL},
+			{Name: "range", Type: ArgTypeTimeRange, Optional: true},
+		},
+		Description: "Play an audio or video track on the channel minions.",
+		Examples: []string{
+			"play http://example.com/freshpots.mp3",
+			"play https://www.youtube.com/watch?v=dQw4w9WgXcQ 0:30",
+			"play https://www.youtube.com/watch?v=dQw4w9WgXcQ 1:23-2:05",
+			"play https://youtu.be/dQw4w9WgXcQ?t=1m30s",
+		},
 	})
 }
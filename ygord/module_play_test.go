@@ -5,6 +5,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,7 +16,7 @@ func TestModulePlayUsageOnNoParams(t *testing.T) {
 
 	m := &PlayModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
+	srv.RunCommand("play", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -23,8 +24,123 @@ func TestModulePlayUsageOnNoParams(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "usage: play url [end]", msgs[0].Body)
+		assert.Equal(t, "usage: play url [range]", msgs[0].Body)
 	}
 
 	assert.Empty(t, client.FlushQueue())
 }
+
+func TestModulePlayCardOnMattermost(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "test")
+
+	m := &PlayModule{}
+	m.Init(srv)
+	srv.RunCommand("play", &InputMessage{
+		Adapter:  "mattermost",
+		Nickname: "alice",
+		ReplyTo:  "#test",
+		Args:     []string{"http://10.0.0.1/freshpots.mp3"},
+	})
+	srv.FlushMediaQueue()
+
+	assert.Len(t, client.FlushQueue(), 1)
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, []Attachment{{
+			Fallback:  "now playing: freshpots.mp3",
+			Title:     "freshpots.mp3",
+			TitleLink: "http://10.0.0.1/freshpots.mp3",
+			Fields: []AttachmentField{
+				{Title: "Source", Value: "10.0.0.1", Short: true},
+				{Title: "Requested by", Value: "alice", Short: true},
+			},
+		}}, msgs[0].Attachments)
+	}
+}
+
+func TestModulePlayNowPlaying(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "test")
+
+	m := &PlayModule{}
+	m.Init(srv)
+	srv.RunCommand("play", &InputMessage{
+		Adapter:  "test",
+		Nickname: "alice",
+		ReplyTo:  "#test",
+		Args:     []string{"http://10.0.0.1/freshpots.mp3"},
+	})
+	srv.FlushMediaQueue()
+
+	assert.Len(t, client.FlushQueue(), 1)
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "now playing: freshpots.mp3", msgs[0].Body)
+	}
+}
+
+func TestModulePlayRange(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "test")
+
+	m := &PlayModule{}
+	m.Init(srv)
+	srv.RunCommand("play", &InputMessage{
+		Adapter: "test",
+		ReplyTo: "#test",
+		Args:    []string{"http://10.0.0.1/freshpots.mp3", "1:23-2:05"},
+	})
+	srv.FlushMediaQueue()
+
+	if queue := client.FlushQueue(); assert.Len(t, queue, 1) {
+		media := queue[0].Data.(*Media)
+		assert.Equal(t, "83", media.Start)
+		assert.Equal(t, "125", media.End)
+	}
+}
+
+func TestModulePlayURLStart(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "test")
+
+	link := "https://youtu.be/dQw4w9WgXcQ?t=120"
+	srv.MediaCache.Set(time.Now(), &MediaCacheEntry{
+		URL:       link,
+		Src:       "dQw4w9WgXcQ",
+		Format:    "youtube",
+		MediaType: "youtube",
+		Start:     "120",
+	})
+
+	m := &PlayModule{}
+	m.Init(srv)
+	for _, timeRange := range []string{"90", "3:00", "1:00-1:30"} {
+		srv.RunCommand("play", &InputMessage{
+			Adapter: "test",
+			ReplyTo: "#test",
+			Args:    []string{link, timeRange},
+		})
+	}
+	srv.FlushMediaQueue()
+
+	queue := client.FlushQueue()
+	if assert.Len(t, queue, 2) {
+		media := queue[0].Data.(*Media)
+		assert.Equal(t, "120", media.Start)
+		assert.Equal(t, "180", media.End)
+
+		// An explicit start replaces the one of the URL.
+		media = queue[1].Data.(*Media)
+		assert.Equal(t, "60", media.Start)
+		assert.Equal(t, "90", media.End)
+	}
+
+	msgs := srv.FlushOutputQueue()
+	if assert.NotEmpty(t, msgs) {
+		assert.Equal(t, "error: the end must be after the start",
+			msgs[0].PlainText())
+	}
+}
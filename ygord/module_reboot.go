@@ -12,7 +12,7 @@ type RebootModule struct{}
 
 // PrivMsg is the message handler for user requests.
 func (module *RebootModule) PrivMsg(srv *Server, msg *InputMessage) {
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"reboot", nil})
+	srv.SendToMinions(msg, ClientCommand{"reboot", nil})
 
 	srv.Reply(msg, "attempting to reboot "+msg.ReplyTo+" minions...")
 }
@@ -25,5 +25,7 @@ func (module RebootModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Role:            RoleOperator,
+		Description:     "Reload the channel minions.",
 	})
 }
@@ -4,54 +4,54 @@
 package main
 
 import (
+	"context"
 	"net/url"
 	"strings"
-
-	"github.com/jessevdk/go-flags"
+	"time"
 )
 
-// SayCmdLine is the schema for the command-line parser of the 'say' command.
-type SayCmdLine struct {
-	Voice string `short:"v" description:"Voice" default:"bruce"`
-}
+// SayDefaultVoice is the voice used when none is given with -v.
+const SayDefaultVoice = "bruce"
 
 // SayModule controls all the 'say' commands.
 type SayModule struct{}
 
 // PrivMsg is the message handler for user requests.
 func (module *SayModule) PrivMsg(srv *Server, msg *InputMessage) {
-	cmd := SayCmdLine{}
-
-	flagParser := flags.NewParser(&cmd, flags.PassDoubleDash)
-	args, err := flagParser.ParseArgs(msg.Args)
-	if err != nil || len(args) == 0 {
-		srv.Reply(msg, "usage: say [-v voice] sentence")
-		return
+	voice := SayDefaultVoice
+	if msg.Params.Has("v") {
+		voice = msg.Params.String("v")
 	}
 
 	if srv.Config.SaydURL == "" {
-		srv.Reply(msg, "error: SaydURL is not configured")
+		srv.ReplyError(msg, "SaydURL is not configured")
 		return
 	}
 
-	sayURL := srv.Config.SaydURL + cmd.Voice + ".mp3?" + url.QueryEscape(strings.Join(args, " "))
+	sentence := strings.Join(msg.Params.Strings("sentence"), " ")
+	sayURL := srv.Config.SaydURL + voice + ".mp3?" + url.QueryEscape(sentence)
 	mediaItem := make(map[string]string)
 	mediaItem["url"] = sayURL
 
-	media, err := NewMedia(srv, mediaItem, "playTrack", false, false,
-		[]string{})
-	if err != nil {
-		srv.Reply(msg, err.Error())
-		return
-	}
+	srv.ResolveMedia(msg, func(ctx context.Context) (*Media, error) {
+		return NewMediaWithContext(ctx, srv, mediaItem, "playTrack",
+			false, false, []string{})
+	}, func(media *Media, err error) {
+		if err != nil {
+			srv.Warningf("say: media error for %s: %s", sayURL,
+				err.Error())
+			srv.ReplyError(msg, err.Error())
+			return
+		}
 
-	// Override the formatted Src to be the original sayURL, because, in this
-	// case, the query string is needed.
-	media.Src = sayURL
+		// Override the formatted Src to be the original sayURL,
+		// because, in this case, the query string is needed.
+		media.Src = sayURL
 
-	// Send the command to the connected minions, as though it were the play
-	// command.
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"play", media})
+		// Send the command to the connected minions, as though it were
+		// the play command.
+		srv.SendToMinions(msg, ClientCommand{"play", media})
+	})
 }
 
 // Init registers all the commands for this module.
@@ -62,5 +62,16 @@ func (module SayModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Immediate:       true,
+		RateLimit:       RateLimit{Quota: 10, Period: time.Minute},
+		Flags:           []FlagSpec{{Name: "v", Value: "voice"}},
+		Args: []ArgSpec{
+			{Name: "sentence", Variadic: true},
+		},
+		Description: "Speak a sentence on the channel minions.",
+		Examples: []string{
+			"say hello world",
+			"say -v bruce hello world",
+		},
 	})
 }
@@ -15,7 +15,7 @@ func TestModuleSayUsageOnNoParams(t *testing.T) {
 
 	m := &SayModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
+	srv.RunCommand("say", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{},
 	})
@@ -23,7 +23,7 @@ func TestModuleSayUsageOnNoParams(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "usage: say [-v voice] sentence", msgs[0].Body)
+		assert.Equal(t, "usage: say [-v voice] sentence ...", msgs[0].Body)
 	}
 
 	assert.Empty(t, client.FlushQueue())
@@ -35,7 +35,7 @@ func TestModuleSayNoConfig(t *testing.T) {
 
 	m := &SayModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
+	srv.RunCommand("say", &InputMessage{
 		ReplyTo: "#test",
 		Args:    []string{"hello"},
 	})
@@ -43,7 +43,7 @@ func TestModuleSayNoConfig(t *testing.T) {
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: SaydURL is not configured", msgs[0].Body)
+		assert.Equal(t, "error: SaydURL is not configured", msgs[0].PlainText())
 	}
 
 	assert.Empty(t, client.FlushQueue())
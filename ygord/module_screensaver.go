@@ -19,7 +19,7 @@ type ScreensaverModule struct {
 
 // PrivMsg is the message handler for user 'image' requests.
 func (module *ScreensaverModule) PrivMsg(srv *Server, msg *InputMessage) {
-	srv.Reply(msg, "error: not implemented yet")
+	srv.ReplyError(msg, "not implemented yet")
 }
 
 func (module *ScreensaverModule) StartScreensaver(srv *Server, client *Client, alias *alias.Alias) {
@@ -27,7 +27,7 @@ func (module *ScreensaverModule) StartScreensaver(srv *Server, client *Client, a
 
 	msgs, err := srv.NewMessagesFromBody(alias.Value, 0)
 	if err != nil {
-		log.Printf("screensaver: lexer/expand error: %s", err.Error)
+		srv.Warningf("screensaver: lexer/expand error: %s", err.Error())
 		return
 	}
 
@@ -92,5 +92,6 @@ func (module ScreensaverModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Description:     "Not implemented yet.",
 	})
 }
@@ -33,7 +33,9 @@ func (module *ShutUpModule) Toggle(srv *Server, msg *InputMessage) bool {
 
 // PrivMsg is the message handler for user requests.
 func (module *ShutUpModule) PrivMsg(srv *Server, msg *InputMessage) {
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"shutup", nil})
+	// Forget about the media still being resolved for this channel.
+	srv.MediaQueue.Cancel(msg.ReplyTo)
+	srv.SendToMinions(msg, ClientCommand{"shutup", nil})
 	srv.Reply(msg, "ok...")
 }
 
@@ -46,5 +48,10 @@ func (module ShutUpModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Immediate:       true,
+		Args: []ArgSpec{
+			{Name: "words", Optional: true, Variadic: true},
+		},
+		Description: "Stop everything (also \"stop\", \"shhh\", \"shut up\").",
 	})
 }
@@ -8,7 +8,7 @@ type SkipModule struct{}
 
 // PrivMsg is the message handler for user requests.
 func (module *SkipModule) PrivMsg(srv *Server, msg *InputMessage) {
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{"skip", nil})
+	srv.SendToMinions(msg, ClientCommand{"skip", nil})
 }
 
 // Init registers all the commands for this module.
@@ -19,5 +19,6 @@ func (module SkipModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Description:     "Skip the current track on the channel minions.",
 	})
 }
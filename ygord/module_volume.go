@@ -4,11 +4,7 @@
 package main
 
 import (
-	"regexp"
-)
-
-var (
-	rePercentage = regexp.MustCompile(`^\d{1,3}%$`)
+	"fmt"
 )
 
 // VolumeModule is the module handling all the volume related commands.
@@ -16,30 +12,16 @@ type VolumeModule struct{}
 
 // PrivMsg is the message handler for user 'volume' requests.
 func (module VolumeModule) PrivMsg(srv *Server, msg *InputMessage) {
-	if len(msg.Args) != 1 {
-		srv.Reply(msg, "usage: volume percent")
-		return
-	}
-
-	if !rePercentage.MatchString(msg.Args[0]) {
-		srv.Reply(msg, "error: bad input, must be absolute rounded percent value (e.g. 42%)")
-		return
-	}
-
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{
+	srv.SendToMinions(msg, ClientCommand{
 		Name: "volume",
-		Data: msg.Args[0],
+		Data: fmt.Sprintf("%d%%", msg.Params.Percentage("percent")),
 	})
 }
 
 // PrivMsgPlusPlus is the message handler for user 'volume++' requests, it
 // increments the volume by 1dB.
 func (module VolumeModule) PrivMsgPlusPlus(srv *Server, msg *InputMessage) {
-	if len(msg.Args) != 0 {
-		srv.Reply(msg, "usage: volume++")
-		return
-	}
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{
+	srv.SendToMinions(msg, ClientCommand{
 		Name: "volume",
 		Data: "1dB+",
 	})
@@ -48,11 +30,7 @@ func (module VolumeModule) PrivMsgPlusPlus(srv *Server, msg *InputMessage) {
 // PrivMsgMinusMinus is the message handler for user 'volume--' requests, it
 // decrements the volume by 1dB.
 func (module VolumeModule) PrivMsgMinusMinus(srv *Server, msg *InputMessage) {
-	if len(msg.Args) != 0 {
-		srv.Reply(msg, "usage: volume--")
-		return
-	}
-	srv.SendToChannelMinions(msg.ReplyTo, ClientCommand{
+	srv.SendToMinions(msg, ClientCommand{
 		Name: "volume",
 		Data: "1dB-",
 	})
@@ -66,6 +44,14 @@ func (module VolumeModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Role:            RoleOperator,
+		Args: []ArgSpec{
+			{Name: "percent", Type: ArgTypePercentage},
+		},
+		Description: "Set the volume of the channel minions.",
+		Examples: []string{
+			"volume 42%",
+		},
 	})
 
 	srv.RegisterCommand(Command{
@@ -74,6 +60,8 @@ func (module VolumeModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Role:            RoleOperator,
+		Description:     "Turn the volume of the channel minions up.",
 	})
 
 	srv.RegisterCommand(Command{
@@ -82,5 +70,7 @@ func (module VolumeModule) Init(srv *Server) {
 		Addressed:       true,
 		AllowPrivate:    false,
 		AllowChannel:    true,
+		Role:            RoleOperator,
+		Description:     "Turn the volume of the channel minions down.",
 	})
 }
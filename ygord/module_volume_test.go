@@ -13,11 +13,15 @@ func TestModuleVolume_UsageNoParams(t *testing.T) {
 	srv := CreateTestServer()
 	client := srv.RegisterClient("dummy", "#test")
 
+	srv.Roles.Set("test", "bob", RoleOperator)
+
 	m := &VolumeModule{}
 	m.Init(srv)
-	m.PrivMsg(srv, &InputMessage{
-		ReplyTo: "#test",
-		Args:    []string{},
+	srv.RunCommand("volume", &InputMessage{
+		Adapter:  "test",
+		Nickname: "bob",
+		ReplyTo:  "#test",
+		Args:     []string{},
 	})
 
 	msgs := srv.FlushOutputQueue()
@@ -33,30 +37,58 @@ func TestModuleVolume_BadValues(t *testing.T) {
 	srv := CreateTestServer()
 	client := srv.RegisterClient("dummy", "#test")
 
+	srv.Roles.Set("test", "bob", RoleOperator)
+
 	m := &VolumeModule{}
 	m.Init(srv)
 
-	m.PrivMsg(srv, &InputMessage{
-		ReplyTo: "#test",
-		Args:    []string{"-10%"},
+	srv.RunCommand("volume", &InputMessage{
+		Adapter:  "test",
+		Nickname: "bob",
+		ReplyTo:  "#test",
+		Args:     []string{"-10%"},
 	})
 
 	msgs := srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: bad input, must be absolute rounded percent value (e.g. 42%)", msgs[0].Body)
+		assert.Equal(t, "error: percent: must be a rounded percentage (e.g. 42%)", msgs[0].PlainText())
 	}
 	assert.Empty(t, client.FlushQueue())
 
-	m.PrivMsg(srv, &InputMessage{
-		ReplyTo: "#test",
-		Args:    []string{"wat"},
+	srv.RunCommand("volume", &InputMessage{
+		Adapter:  "test",
+		Nickname: "bob",
+		ReplyTo:  "#test",
+		Args:     []string{"wat"},
 	})
 
 	msgs = srv.FlushOutputQueue()
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].Channel)
-		assert.Equal(t, "error: bad input, must be absolute rounded percent value (e.g. 42%)", msgs[0].Body)
+		assert.Equal(t, "error: percent: must be a rounded percentage (e.g. 42%)", msgs[0].PlainText())
 	}
 	assert.Empty(t, client.FlushQueue())
 }
+
+func TestModuleVolume_PermissionDenied(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "#test")
+
+	m := &VolumeModule{}
+	m.Init(srv)
+	srv.RunCommand("volume", &InputMessage{
+		Adapter:  "test",
+		Nickname: "mallory",
+		ReplyTo:  "#test",
+		Args:     []string{"100%"},
+	})
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#test", msgs[0].Channel)
+		assert.Equal(t, "error: permission denied: volume requires the operator role", msgs[0].PlainText())
+	}
+
+	assert.Empty(t, client.FlushQueue())
+}
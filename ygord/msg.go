@@ -21,36 +21,91 @@ type OutputMsgType int
 // Types of messages to/from any various source (IRC, Mattermost, etc.).  The
 // first constants (Input*) are used to represent a message ingested from the
 // chat system, the second (Output*) represent a message traveling out of ygor
-// to the chat system.
+// to the chat system.  Which chat system is defined by the Adapter field of
+// the message.
+//
+// Output messages are rendered by each adapter according to their type:
+// PrivMsg is plain text, Action is the equivalent of "/me", Error is an error
+// message without "error:" prefix, List is a list of Items with an optional
+// heading in Body and MediaCard describes media with its Attachments.
 const (
 	InputMsgTypeUnknown     InputMsgType = iota
-	InputMsgTypeIRCChannel  InputMsgType = iota
-	InputMsgTypeIRCPrivate  InputMsgType = iota
-	InputMsgTypeMattermost  InputMsgType = iota
+	InputMsgTypeChannel     InputMsgType = iota
+	InputMsgTypePrivate     InputMsgType = iota
 	InputMsgTypeScreensaver InputMsgType = iota
 
-	OutputMsgTypePrivMsg     OutputMsgType = iota
-	OutputMsgTypeAction      OutputMsgType = iota
-	OutputMsgTypeMattermost  OutputMsgType = iota
-	OutputMsgTypeScreensaver OutputMsgType = iota
+	OutputMsgTypePrivMsg   OutputMsgType = iota
+	OutputMsgTypeAction    OutputMsgType = iota
+	OutputMsgTypeError     OutputMsgType = iota
+	OutputMsgTypeList      OutputMsgType = iota
+	OutputMsgTypeMediaCard OutputMsgType = iota
 )
 
-// OutputMessage is the representation of an outbound IRC message.
+// Attachment is a piece of structured content attached to an outbound
+// message, e.g. a card describing the media being played.  Adapters unable to
+// render it use the Fallback text.
+type Attachment struct {
+	Fallback  string
+	Title     string
+	TitleLink string
+	Text      string
+	ThumbURL  string
+	ImageURL  string
+	Fields    []AttachmentField
+}
+
+// AttachmentField is a single title/value pair displayed in an Attachment,
+// short fields can be displayed side by side.
+type AttachmentField struct {
+	Title string
+	Value string
+	Short bool
+}
+
+// OutputMessage is the representation of an outbound chat message.
 type OutputMessage struct {
-	Type    OutputMsgType
-	Channel string
-	Body    string
+	Type OutputMsgType
+	// Adapter is the name of the ChatAdapter expected to deliver this
+	// message.
+	Adapter string
+	// AdapterData is copied from the InputMessage this message responds
+	// to, see InputMessage.
+	AdapterData interface{}
+	Channel     string
+	Body        string
+	Items       []string
+	Attachments []Attachment
+	// ThreadRootID is the identifier of the message starting the thread
+	// this message should be posted in, for the adapters supporting it.
+	ThreadRootID string
 }
 
-// InputMessage is a representation of an incoming IRC message
+// InputMessage is a representation of an incoming chat message
 type InputMessage struct {
-	Type     InputMsgType
-	Nickname string
-	Command  string
-	Body     string
+	Type InputMsgType
+	// Adapter is the name of the ChatAdapter this message was received
+	// from, responses are sent back through the same adapter.
+	Adapter string
+	// AdapterData is opaque to ygor, it is set by the adapter when the
+	// message is received and copied to all the responses, e.g. to know
+	// where to send them.
+	AdapterData interface{}
+	Nickname    string
+	Command     string
+	Body        string
 	// ReplyTo could be a nickname or a channel.
 	ReplyTo string
 	Args    []string
+	// Params are the Args parsed according to the specification of the
+	// command, set right before the command is executed.
+	Params *Params
+	// Audit is the entry recording the execution of this message, set
+	// right before the command is executed.
+	Audit *AuditEntry
+
+	// pending is the number of media being resolved for this message, it
+	// is audited once they are all delivered, see ResolveMedia.
+	pending int
 
 	// Depth tracks the recursion and depth level in case commands
 	// create/call other commands and produce more messages.  A message
@@ -66,61 +121,114 @@ func NewInputMessage() *InputMessage {
 	return msg
 }
 
+// NewResponse creates a plain text OutputMessage addressed to the same adapter
+// and channel as the given message.
 func (msg *InputMessage) NewResponse(text string) *OutputMessage {
-	var outputType OutputMsgType
-
-	replyTo := msg.ReplyTo
+	return &OutputMessage{
+		Type:        OutputMsgTypePrivMsg,
+		Adapter:     msg.Adapter,
+		AdapterData: msg.AdapterData,
+		Channel:     msg.ReplyTo,
+		Body:        text,
+	}
+}
 
+// PlainText renders the message as plain text, for the adapters without any
+// formatting ability.  The body of actions is returned as-is, each adapter
+// has its own way to represent them.
+func (msg *OutputMessage) PlainText() string {
 	switch msg.Type {
-	case InputMsgTypeUnknown:
-		return nil
-	case InputMsgTypeIRCChannel:
-		if !strings.HasPrefix(replyTo, "#") {
-			replyTo = "#" + replyTo
-		}
-		if strings.HasPrefix(text, "/me ") {
-			outputType = OutputMsgTypeAction
-			text = strings.TrimPrefix(text, "/me ")
-		} else {
-			outputType = OutputMsgTypePrivMsg
+	case OutputMsgTypeError:
+		return "error: " + msg.Body
+	case OutputMsgTypeList:
+		items := strings.Join(msg.Items, ", ")
+		if msg.Body == "" {
+			return items
 		}
-	case InputMsgTypeIRCPrivate:
-		if strings.HasPrefix(text, "/me ") {
-			outputType = OutputMsgTypeAction
-			text = strings.TrimPrefix(text, "/me ")
-		} else {
-			outputType = OutputMsgTypePrivMsg
+		return msg.Body + ": " + items
+	case OutputMsgTypeMediaCard:
+		var lines []string
+		if msg.Body != "" {
+			lines = append(lines, msg.Body)
 		}
-	case InputMsgTypeMattermost:
-		outputType = OutputMsgTypeMattermost
-		if strings.HasPrefix(text, "/me ") {
-			text = "*" + strings.TrimPrefix(text, "/me ") + "*"
+		for _, attachment := range msg.Attachments {
+			lines = append(lines, attachment.Fallback)
 		}
-	case InputMsgTypeScreensaver:
-		outputType = OutputMsgTypeScreensaver
+		return strings.Join(lines, "\n")
 	}
 
-	return &OutputMessage{
-		Type:    outputType,
-		Channel: replyTo,
-		Body:    text,
+	return msg.Body
+}
+
+// Markdown renders the message with the markdown flavor shared by Mattermost
+// and Discord.
+func (msg *OutputMessage) Markdown() string {
+	switch msg.Type {
+	case OutputMsgTypeAction:
+		return "*" + msg.Body + "*"
+	case OutputMsgTypeError:
+		return "**error:** " + msg.Body
+	case OutputMsgTypeList:
+		var items []string
+		for _, item := range msg.Items {
+			items = append(items, "`"+item+"`")
+		}
+		if msg.Body == "" {
+			return strings.Join(items, ", ")
+		}
+		return "**" + msg.Body + ":** " + strings.Join(items, ", ")
 	}
+
+	return msg.PlainText()
 }
 
+// IsMattermost returns true if this message was received from Mattermost.
 func (msg *InputMessage) IsMattermost() bool {
-	if msg.Type == InputMsgTypeMattermost {
+	if msg.Adapter == "mattermost" {
 		return true
 	}
 	return false
 }
 
-// Reply sends a reply based on the given message (same channel, mirror messag)
+// Reply sends a plain text reply based on the given message (same adapter,
+// same channel).
 func (srv *Server) Reply(msg *InputMessage, text string) {
-	lines := strings.Split(text, "\n")
-	for i := 0; i < len(lines); i++ {
-		if lines[i] == "" {
-			continue
-		}
-		srv.OutputQueue <- msg.NewResponse(lines[i])
+	if text == "" {
+		return
 	}
+	srv.OutputQueue <- msg.NewResponse(text)
+}
+
+// ReplyAction sends an action reply (e.g. "/me dances") based on the given
+// message.
+func (srv *Server) ReplyAction(msg *InputMessage, text string) {
+	response := msg.NewResponse(text)
+	response.Type = OutputMsgTypeAction
+	srv.OutputQueue <- response
+}
+
+// ReplyError sends an error reply based on the given message, the adapter
+// is responsible for making it look like an error.
+func (srv *Server) ReplyError(msg *InputMessage, text string) {
+	response := msg.NewResponse(text)
+	response.Type = OutputMsgTypeError
+	srv.OutputQueue <- response
+}
+
+// ReplyList sends a list of items based on the given message, the heading is
+// optional.
+func (srv *Server) ReplyList(msg *InputMessage, heading string, items []string) {
+	response := msg.NewResponse(heading)
+	response.Type = OutputMsgTypeList
+	response.Items = items
+	srv.OutputQueue <- response
+}
+
+// ReplyMediaCard sends a card describing some media based on the given
+// message, the text is optional.
+func (srv *Server) ReplyMediaCard(msg *InputMessage, text string, card Attachment) {
+	response := msg.NewResponse(text)
+	response.Type = OutputMsgTypeMediaCard
+	response.Attachments = []Attachment{card}
+	srv.OutputQueue <- response
 }
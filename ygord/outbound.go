@@ -0,0 +1,204 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the policy applied to the requests ygord makes on behalf
+// of its users (e.g. to resolve media URLs).  The addresses are checked when
+// connecting, once the host name is resolved, so that a public name pointing
+// to a private address (or re-pointed after a first lookup) cannot be used to
+// reach the services next to ygord, such as the cloud metadata endpoints.
+//
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// blockedNetwork is a range of addresses ygord refuses to connect to.
+type blockedNetwork struct {
+	*net.IPNet
+	Reason string
+}
+
+var (
+	// Ranges which are not reachable from the Internet, or not meant to
+	// be.  The IPv4-mapped IPv6 addresses are checked as IPv4.
+	blockedNetworks = []blockedNetwork{
+		{mustParseCIDR("0.0.0.0/8"), "unspecified"},
+		{mustParseCIDR("10.0.0.0/8"), "private"},
+		{mustParseCIDR("100.64.0.0/10"), "shared"},
+		{mustParseCIDR("127.0.0.0/8"), "loopback"},
+		{mustParseCIDR("169.254.0.0/16"), "link-local"},
+		{mustParseCIDR("172.16.0.0/12"), "private"},
+		{mustParseCIDR("192.0.0.0/24"), "reserved"},
+		{mustParseCIDR("192.168.0.0/16"), "private"},
+		{mustParseCIDR("198.18.0.0/15"), "reserved"},
+		{mustParseCIDR("224.0.0.0/4"), "multicast"},
+		{mustParseCIDR("240.0.0.0/4"), "reserved"},
+		{mustParseCIDR("::/128"), "unspecified"},
+		{mustParseCIDR("::1/128"), "loopback"},
+		{mustParseCIDR("64:ff9b::/96"), "translated"},
+		{mustParseCIDR("fc00::/7"), "private"},
+		{mustParseCIDR("fe80::/10"), "link-local"},
+		{mustParseCIDR("ff00::/8"), "multicast"},
+	}
+
+	// The cloud metadata endpoints, reported as such.
+	metadataIPs = []net.IP{
+		net.ParseIP("169.254.169.254"),
+		net.ParseIP("100.100.100.200"),
+		net.ParseIP("fd00:ec2::254"),
+	}
+
+	outboundPolicyLock sync.RWMutex
+	outboundPolicy     = &OutboundPolicy{}
+
+	// outboundTransport is the Transport of all the clients fetching URLs
+	// given by the users.  No proxy is used, the addresses checked are
+	// the ones actually connected to.
+	outboundTransport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   outboundControl,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	outboundClient = &http.Client{
+		Transport:     outboundTransport,
+		CheckRedirect: checkRedirect,
+	}
+)
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// OutboundPolicy decides which addresses ygord may connect to.  Only the
+// public addresses are allowed, unless they are part of Allowlist (e.g. the
+// intranet hosting some of the media).
+type OutboundPolicy struct {
+	Allowlist []*net.IPNet
+}
+
+// NewOutboundPolicy creates an OutboundPolicy from a list of addresses or
+// CIDR ranges (e.g. "10.1.0.0/16").
+func NewOutboundPolicy(allowlist []string) (*OutboundPolicy, error) {
+	policy := &OutboundPolicy{}
+
+	for _, value := range allowlist {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.New("invalid address: " + value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.New("invalid range: " + value)
+		}
+		policy.Allowlist = append(policy.Allowlist, network)
+	}
+
+	return policy, nil
+}
+
+// Check returns an error explaining why the address is blocked, nil if it is
+// allowed.
+func (policy *OutboundPolicy) Check(ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	for _, network := range policy.Allowlist {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, metadataIP := range metadataIPs {
+		if metadataIP.Equal(ip) {
+			return errors.New("address " + ip.String() +
+				" is blocked (cloud metadata)")
+		}
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return errors.New("address " + ip.String() +
+				" is blocked (" + network.Reason + ")")
+		}
+	}
+
+	return nil
+}
+
+// SetOutboundPolicy replaces the policy applied to the outbound requests.
+func SetOutboundPolicy(policy *OutboundPolicy) {
+	outboundPolicyLock.Lock()
+	outboundPolicy = policy
+	outboundPolicyLock.Unlock()
+}
+
+// GetOutboundPolicy returns the policy applied to the outbound requests.
+func GetOutboundPolicy() *OutboundPolicy {
+	outboundPolicyLock.RLock()
+	defer outboundPolicyLock.RUnlock()
+	return outboundPolicy
+}
+
+// outboundControl is called by the dialer of the outbound requests with the
+// resolved address, before connecting.
+func outboundControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.New("invalid address: " + address)
+	}
+
+	return GetOutboundPolicy().Check(ip)
+}
+
+// hostIsPrivateIP returns true if the host (optionally with a port) is an IP
+// address ygord is not allowed to connect to.  These URLs are not resolved,
+// they are passed as-is to the minions, which may be able to reach them.
+//
+// Host names are not checked here, their addresses are checked when
+// connecting.
+func hostIsPrivateIP(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	return GetOutboundPolicy().Check(ip) != nil
+}
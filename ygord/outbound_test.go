@@ -0,0 +1,119 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// The test HTTP servers listen on the loopback.
+	policy, _ := NewOutboundPolicy([]string{"127.0.0.1"})
+	SetOutboundPolicy(policy)
+}
+
+func TestOutboundPolicyCheck(t *testing.T) {
+	policy, err := NewOutboundPolicy([]string{"10.1.0.0/16", "fd12::1"})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, test := range []struct {
+		ip  string
+		err string
+	}{
+		{"93.184.216.34", ""},
+		{"2606:2800:220:1::248", ""},
+		{"0.0.0.0", "address 0.0.0.0 is blocked (unspecified)"},
+		{"10.0.0.1", "address 10.0.0.1 is blocked (private)"},
+		{"10.1.2.3", ""},
+		{"100.64.0.1", "address 100.64.0.1 is blocked (shared)"},
+		{"100.100.100.200", "address 100.100.100.200 is blocked (cloud metadata)"},
+		{"127.0.0.1", "address 127.0.0.1 is blocked (loopback)"},
+		{"127.1.2.3", "address 127.1.2.3 is blocked (loopback)"},
+		{"169.254.1.1", "address 169.254.1.1 is blocked (link-local)"},
+		{"169.254.169.254", "address 169.254.169.254 is blocked (cloud metadata)"},
+		{"172.16.0.1", "address 172.16.0.1 is blocked (private)"},
+		{"172.32.0.1", ""},
+		{"192.168.1.1", "address 192.168.1.1 is blocked (private)"},
+		{"224.0.0.1", "address 224.0.0.1 is blocked (multicast)"},
+		{"255.255.255.255", "address 255.255.255.255 is blocked (reserved)"},
+		{"::", "address :: is blocked (unspecified)"},
+		{"::1", "address ::1 is blocked (loopback)"},
+		{"::ffff:127.0.0.1", "address 127.0.0.1 is blocked (loopback)"},
+		{"::ffff:10.1.0.1", ""},
+		{"64:ff9b::a00:1", "address 64:ff9b::a00:1 is blocked (translated)"},
+		{"fd00:ec2::254", "address fd00:ec2::254 is blocked (cloud metadata)"},
+		{"fd12::1", ""},
+		{"fd12::2", "address fd12::2 is blocked (private)"},
+		{"fe80::1", "address fe80::1 is blocked (link-local)"},
+		{"ff02::1", "address ff02::1 is blocked (multicast)"},
+	} {
+		err := policy.Check(net.ParseIP(test.ip))
+		if test.err == "" {
+			assert.Nil(t, err, test.ip)
+		} else if assert.NotNil(t, err, test.ip) {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+}
+
+func TestNewOutboundPolicyInvalid(t *testing.T) {
+	for _, value := range []string{"example.com", "10.0.0.0/33", "10.0.0"} {
+		_, err := NewOutboundPolicy([]string{value})
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestHostIsPrivateIP(t *testing.T) {
+	for _, test := range []struct {
+		host    string
+		private bool
+	}{
+		{"example.com", false},
+		{"example.com:8080", false},
+		{"93.184.216.34", false},
+		{"10.0.0.1", true},
+		{"10.0.0.1:8080", true},
+		{"169.254.169.254", true},
+		{"[::1]", true},
+		{"[::1]:8080", true},
+		{"[fe80::1]:80", true},
+		{"[2606:2800:220:1::248]:443", false},
+		// Allowed by the test policy, see init.
+		{"127.0.0.1:8080", false},
+	} {
+		assert.Equal(t, test.private, hostIsPrivateIP(test.host), test.host)
+	}
+}
+
+func TestOutboundClientChecksResolvedAddress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	res, err := outboundClient.Get(ts.URL)
+	if assert.Nil(t, err) {
+		res.Body.Close()
+	}
+
+	policy := GetOutboundPolicy()
+	defer SetOutboundPolicy(policy)
+	SetOutboundPolicy(&OutboundPolicy{})
+	outboundTransport.CloseIdleConnections()
+
+	// Names are resolved before the check.
+	link := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	for _, link := range []string{ts.URL, link} {
+		_, err = outboundClient.Get(link)
+		if assert.NotNil(t, err, link) {
+			assert.Contains(t, err.Error(), "is blocked (loopback)")
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the permission model.  Each command requires a role,
+// users are given roles per chat adapter in the configuration (globally or
+// per channel) and at runtime by the admins.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Role defines what a user is allowed to do, each role includes the
+// permissions of the roles before it.
+type Role int
+
+// Roles available, by increasing privilege.  Everyone is a user, operators
+// can control the minions (e.g. reboot, volume) and admins can also manage
+// the roles of other users.
+const (
+	RoleUser     Role = iota
+	RoleOperator Role = iota
+	RoleAdmin    Role = iota
+)
+
+var roleNames = []string{"user", "operator", "admin"}
+
+// String returns the name of the role (e.g. "operator").
+func (role Role) String() string {
+	if role < 0 || int(role) >= len(roleNames) {
+		return fmt.Sprintf("role%d", role)
+	}
+	return roleNames[role]
+}
+
+// ParseRole returns the Role with the given name.
+func ParseRole(name string) (Role, error) {
+	for i, roleName := range roleNames {
+		if roleName == name {
+			return Role(i), nil
+		}
+	}
+	return RoleUser, errors.New("unknown role '" + name + "' (expected " +
+		strings.Join(roleNames, ", ") + ")")
+}
+
+// RoleRegistry holds the roles of the users for each adapter.  Nicknames are
+// case-insensitive.
+type RoleRegistry struct {
+	sync.RWMutex
+	roles map[string]map[string]Role
+}
+
+// NewRoleRegistry creates a registry from the configuration format: role
+// names per nickname per adapter (e.g. "irc": {"bob": "admin"}).
+func NewRoleRegistry(cfg map[string]map[string]string) (*RoleRegistry, error) {
+	registry := &RoleRegistry{roles: make(map[string]map[string]Role)}
+
+	for adapter, users := range cfg {
+		for nickname, name := range users {
+			role, err := ParseRole(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %s", adapter,
+					nickname, err.Error())
+			}
+			registry.Set(adapter, nickname, role)
+		}
+	}
+
+	return registry, nil
+}
+
+// Get returns the role of the given user, RoleUser if none was set.
+func (registry *RoleRegistry) Get(adapter, nickname string) Role {
+	if registry == nil {
+		return RoleUser
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.roles[adapter][strings.ToLower(nickname)]
+}
+
+// Set changes the role of the given user.
+func (registry *RoleRegistry) Set(adapter, nickname string, role Role) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	users, ok := registry.roles[adapter]
+	if !ok {
+		users = make(map[string]Role)
+		registry.roles[adapter] = users
+	}
+
+	users[strings.ToLower(nickname)] = role
+}
+
+// Delete removes the role of the given user, returning false if they did not
+// have any.
+func (registry *RoleRegistry) Delete(adapter, nickname string) bool {
+	registry.Lock()
+	defer registry.Unlock()
+
+	nickname = strings.ToLower(nickname)
+	if _, ok := registry.roles[adapter][nickname]; !ok {
+		return false
+	}
+
+	delete(registry.roles[adapter], nickname)
+	return true
+}
+
+// List returns all the users with a role as "adapter/nickname: role", sorted.
+func (registry *RoleRegistry) List() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	var items []string
+	for adapter, users := range registry.roles {
+		for nickname, role := range users {
+			items = append(items, adapter+"/"+nickname+": "+
+				role.String())
+		}
+	}
+	sort.Strings(items)
+
+	return items
+}
+
+// GetRole returns the role of the author of the message: the highest of their
+// global role and the role they have in the channel of the message.
+// Screensaver messages come from aliases anyone can edit, they are run as
+// plain users.
+func (srv *Server) GetRole(msg *InputMessage) Role {
+	if msg.Type == InputMsgTypeScreensaver {
+		return RoleUser
+	}
+
+	role := srv.Roles.Get(msg.Adapter, msg.Nickname)
+
+	if msg.Type == InputMsgTypePrivate {
+		return role
+	}
+
+	channel, ok := srv.Config.Channels[msg.ReplyTo]
+	if !ok {
+		return role
+	}
+
+	for nickname, name := range channel.Roles[msg.Adapter] {
+		if !strings.EqualFold(nickname, msg.Nickname) {
+			continue
+		}
+		if channelRole, err := ParseRole(name); err == nil && channelRole > role {
+			role = channelRole
+		}
+	}
+
+	return role
+}
+
+// CheckPermission returns an error if the author of the message is not
+// allowed to run the given command.
+func (srv *Server) CheckPermission(cmd Command, msg *InputMessage) error {
+	if cmd.Admin && (srv.Config.AdminChannel == "" ||
+		msg.ReplyTo != srv.Config.AdminChannel) {
+		return errors.New("permission denied: " + cmd.Name +
+			" can only be used in the admin channel")
+	}
+
+	if srv.GetRole(msg) < cmd.Role {
+		return errors.New("permission denied: " + cmd.Name +
+			" requires the " + cmd.Role.String() + " role")
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("operator")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleOperator, role)
+
+	_, err = ParseRole("root")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "unknown role 'root' (expected user, operator, admin)", err.Error())
+	}
+}
+
+func TestNewRoleRegistry(t *testing.T) {
+	registry, err := NewRoleRegistry(map[string]map[string]string{
+		"irc":        {"Alice": "admin", "bob": "operator"},
+		"mattermost": {"alice": "user"},
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, RoleAdmin, registry.Get("irc", "alice"))
+		assert.Equal(t, RoleAdmin, registry.Get("irc", "ALICE"))
+		assert.Equal(t, RoleOperator, registry.Get("irc", "bob"))
+		assert.Equal(t, RoleUser, registry.Get("mattermost", "alice"))
+		assert.Equal(t, RoleUser, registry.Get("discord", "alice"))
+		assert.Equal(t, []string{
+			"irc/alice: admin",
+			"irc/bob: operator",
+			"mattermost/alice: user",
+		}, registry.List())
+	}
+
+	_, err = NewRoleRegistry(map[string]map[string]string{
+		"irc": {"bob": "god"},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestServerGetRole(t *testing.T) {
+	srv := CreateTestServer()
+	srv.Config.Channels["#ops"] = ChannelCfg{
+		Roles: map[string]map[string]string{
+			"test": {"Bob": "admin"},
+		},
+	}
+	srv.Roles.Set("test", "bob", RoleOperator)
+
+	msg := &InputMessage{Adapter: "test", Nickname: "bob", ReplyTo: "#test"}
+	assert.Equal(t, RoleOperator, srv.GetRole(msg))
+
+	msg.ReplyTo = "#ops"
+	assert.Equal(t, RoleAdmin, srv.GetRole(msg))
+
+	msg.Type = InputMsgTypePrivate
+	assert.Equal(t, RoleOperator, srv.GetRole(msg))
+
+	msg = &InputMessage{Adapter: "irc", Nickname: "bob", ReplyTo: "#ops"}
+	assert.Equal(t, RoleUser, srv.GetRole(msg))
+
+	msg = &InputMessage{Type: InputMsgTypeScreensaver, ReplyTo: "#test"}
+	assert.Equal(t, RoleUser, srv.GetRole(msg))
+}
+
+func TestServerCheckPermission(t *testing.T) {
+	srv := CreateTestServer()
+	srv.Roles.Set("test", "alice", RoleAdmin)
+	cmd := Command{Name: "grant", Role: RoleAdmin, Admin: true}
+
+	msg := &InputMessage{Adapter: "test", Nickname: "alice", ReplyTo: "#test"}
+	err := srv.CheckPermission(cmd, msg)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "permission denied: grant can only be used in the admin channel", err.Error())
+	}
+
+	srv.Config.AdminChannel = "#test"
+	assert.Nil(t, srv.CheckPermission(cmd, msg))
+
+	msg.Nickname = "bob"
+	err = srv.CheckPermission(cmd, msg)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "permission denied: grant requires the admin role", err.Error())
+	}
+}
+
+func TestServerScreensaverAdminCommand(t *testing.T) {
+	srv := CreateTestServer()
+	client := srv.RegisterClient("dummy", "#test")
+
+	ran := false
+	srv.RegisterCommand(Command{
+		Name: "secret",
+		PrivMsgFunction: func(srv *Server, msg *InputMessage) {
+			ran = true
+		},
+		Addressed:    true,
+		AllowChannel: true,
+		Role:         RoleAdmin,
+		Admin:        true,
+	})
+
+	// Anyone can edit the screensaver aliases.
+	srv.Aliases.Add("screensaver/#test/1", "secret", "mallory", fakeNow)
+	alias := srv.Aliases.Get("screensaver/#test/1")
+
+	for _, adminChannel := range []string{"#test", client.ID} {
+		srv.Config.AdminChannel = adminChannel
+		(&ScreensaverModule{}).StartScreensaver(srv, client, alias)
+		for _, msg := range srv.FlushInputQueue() {
+			srv.RunCommand(msg.Command, msg)
+		}
+	}
+
+	assert.False(t, ran)
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "error: permission denied: secret can only be "+
+			"used in the admin channel", msgs[0].PlainText())
+		assert.Equal(t, "error: permission denied: secret requires the "+
+			"admin role", msgs[1].PlainText())
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the rate limiting of the commands, preventing a single
+// user from flooding the minions.  Each command can have a cooldown (minimum
+// delay between two runs in a channel) and a quota (maximum number of runs
+// per user over a period), the "*" limit applies to all the commands
+// combined.
+//
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit defines how often a command can be run.  Zero values disable the
+// corresponding limit.
+type RateLimit struct {
+	// Minimum delay between two runs of the command in a channel, by
+	// anyone.
+	Cooldown time.Duration
+
+	// Maximum number of runs of the command by a single user within
+	// Period.
+	Quota  int
+	Period time.Duration
+}
+
+// RateLimitCfg is the configuration of a RateLimit, durations are in the
+// format accepted by ParseDuration (e.g. "2s", "1m" or "1:30").
+type RateLimitCfg struct {
+	Cooldown string
+	Quota    int
+	Period   string
+}
+
+// Parse validates and converts the configuration.
+func (cfg RateLimitCfg) Parse() (RateLimit, error) {
+	var limit RateLimit
+	var err error
+
+	if cfg.Cooldown != "" {
+		limit.Cooldown, err = ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return limit, fmt.Errorf("'Cooldown': %s", err.Error())
+		}
+	}
+
+	if cfg.Quota < 0 {
+		return limit, fmt.Errorf("'Quota' must be positive")
+	}
+	limit.Quota = cfg.Quota
+
+	if cfg.Period != "" {
+		limit.Period, err = ParseDuration(cfg.Period)
+		if err != nil {
+			return limit, fmt.Errorf("'Period': %s", err.Error())
+		}
+	}
+
+	if limit.Quota > 0 && limit.Period == 0 {
+		return limit, fmt.Errorf("'Period' is required with 'Quota'")
+	}
+
+	return limit, nil
+}
+
+// RateLimitCounter counts the runs of a command, or the runs of a user, and
+// how many times they were throttled.
+type RateLimitCounter struct {
+	Runs      int `json:"runs"`
+	Throttled int `json:"throttled"`
+}
+
+// ThrottledError is returned by RateLimiter.Allow when a command is run too
+// often.
+type ThrottledError struct {
+	// Name of the limit reached: a command or "*".
+	Name  string
+	Limit RateLimit
+
+	// Set if the cooldown was hit, otherwise the quota was reached.
+	Cooldown bool
+
+	// How long to wait before trying again.
+	Wait time.Duration
+}
+
+// Error returns a polite explanation, meant to be sent to the user.
+func (err *ThrottledError) Error() string {
+	wait := shortDuration(time.Duration(math.Ceil(err.Wait.Seconds())) *
+		time.Second)
+
+	if err.Cooldown {
+		return fmt.Sprintf("%s was used a moment ago, please try again "+
+			"in %s", err.Name, wait)
+	}
+
+	what := err.Name
+	if err.Name == "*" {
+		what = "commands"
+	}
+
+	times := fmt.Sprintf("%d times", err.Limit.Quota)
+	if err.Limit.Quota == 1 {
+		times = "once"
+	}
+
+	return fmt.Sprintf("you can only use %s %s every %s, please try "+
+		"again in %s", what, times, shortDuration(err.Limit.Period),
+		wait)
+}
+
+// shortDuration formats a duration without its trailing zero units (e.g. "1m"
+// instead of "1m0s").
+func shortDuration(duration time.Duration) string {
+	text := duration.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// RateLimiter keeps track of the recent runs of the commands to enforce their
+// RateLimit, and counts all the runs per command and per user.
+type RateLimiter struct {
+	sync.Mutex
+
+	// End of the cooldown per command and channel.
+	cooldowns map[string]time.Time
+
+	// Expiration of the recent runs per command and user.
+	recentRuns map[string][]time.Time
+
+	// Counters per command and per user.
+	commands map[string]*RateLimitCounter
+	users    map[string]*RateLimitCounter
+}
+
+// NewRateLimiter creates an empty RateLimiter.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		cooldowns:  make(map[string]time.Time),
+		recentRuns: make(map[string][]time.Time),
+		commands:   make(map[string]*RateLimitCounter),
+		users:      make(map[string]*RateLimitCounter),
+	}
+}
+
+// check returns an error if the command named 'name' reached its limit in the
+// given channel or for the given user.
+func (limiter *RateLimiter) check(now time.Time, name string, limit RateLimit, channel, user string) error {
+	if limit.Cooldown > 0 {
+		end, ok := limiter.cooldowns[name+" "+channel]
+		if ok {
+			return &ThrottledError{name, limit, true, end.Sub(now)}
+		}
+	}
+
+	if limit.Quota > 0 {
+		runs := limiter.recentRuns[name+" "+user]
+		if len(runs) >= limit.Quota {
+			wait := runs[0].Sub(now)
+			return &ThrottledError{name, limit, false, wait}
+		}
+	}
+
+	return nil
+}
+
+// record remembers a run of the command named 'name'.
+func (limiter *RateLimiter) record(now time.Time, name string, limit RateLimit, channel, user string) {
+	if limit.Cooldown > 0 {
+		limiter.cooldowns[name+" "+channel] = now.Add(limit.Cooldown)
+	}
+
+	if limit.Quota > 0 {
+		key := name + " " + user
+		limiter.recentRuns[key] = append(limiter.recentRuns[key],
+			now.Add(limit.Period))
+	}
+}
+
+// prune forgets the cooldowns and the runs which expired, so that only the
+// recent activity is kept in memory.
+func (limiter *RateLimiter) prune(now time.Time) {
+	for key, end := range limiter.cooldowns {
+		if !end.After(now) {
+			delete(limiter.cooldowns, key)
+		}
+	}
+
+	for key, runs := range limiter.recentRuns {
+		var pending []time.Time
+		for _, run := range runs {
+			if run.After(now) {
+				pending = append(pending, run)
+			}
+		}
+
+		if len(pending) == 0 {
+			delete(limiter.recentRuns, key)
+		} else {
+			limiter.recentRuns[key] = pending
+		}
+	}
+}
+
+// count returns the counter with the given name, creating it if needed.
+func count(counters map[string]*RateLimitCounter, name string) *RateLimitCounter {
+	counter, ok := counters[name]
+	if !ok {
+		counter = &RateLimitCounter{}
+		counters[name] = counter
+	}
+	return counter
+}
+
+// Allow checks the limit of the command and the limit of all the commands
+// combined ("*"), then records the run.  If either is reached, nothing is
+// recorded and a ThrottledError is returned.
+func (limiter *RateLimiter) Allow(now time.Time, name string, limit, global RateLimit, channel, user string) error {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	cmdCounter := count(limiter.commands, name)
+	userCounter := count(limiter.users, user)
+
+	limiter.prune(now)
+
+	err := limiter.check(now, name, limit, channel, user)
+	if err == nil {
+		err = limiter.check(now, "*", global, channel, user)
+	}
+	if err != nil {
+		cmdCounter.Throttled++
+		userCounter.Throttled++
+		return err
+	}
+
+	limiter.record(now, name, limit, channel, user)
+	limiter.record(now, "*", global, channel, user)
+	cmdCounter.Runs++
+	userCounter.Runs++
+
+	return nil
+}
+
+// Counters returns a copy of the counters per command and per user.
+func (limiter *RateLimiter) Counters() (map[string]RateLimitCounter, map[string]RateLimitCounter) {
+	limiter.Lock()
+	defer limiter.Unlock()
+
+	commands := make(map[string]RateLimitCounter)
+	for name, counter := range limiter.commands {
+		commands[name] = *counter
+	}
+
+	users := make(map[string]RateLimitCounter)
+	for name, counter := range limiter.users {
+		users[name] = *counter
+	}
+
+	return commands, users
+}
+
+// GetRateLimit returns the limit of the command, as configured or else as
+// defined by the command itself.
+func (srv *Server) GetRateLimit(cmd Command) RateLimit {
+	if limit, ok := srv.Limits[cmd.Name]; ok {
+		return limit
+	}
+	return cmd.RateLimit
+}
+
+// CheckRateLimit records the run of the command by the author of the message,
+// or returns a ThrottledError if they have to wait.  Admins and the
+// screensaver (run by ygor itself) are not limited, but still counted.
+func (srv *Server) CheckRateLimit(cmd Command, msg *InputMessage) error {
+	limit := srv.GetRateLimit(cmd)
+	global := srv.Limits["*"]
+
+	if msg.Type == InputMsgTypeScreensaver || srv.GetRole(msg) >= RoleAdmin {
+		limit, global = RateLimit{}, RateLimit{}
+	}
+
+	return srv.RateLimiter.Allow(time.Now(), cmd.Name, limit, global,
+		msg.ReplyTo, msg.Adapter+"/"+strings.ToLower(msg.Nickname))
+}
@@ -0,0 +1,141 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCfgParse(t *testing.T) {
+	limit, err := RateLimitCfg{Cooldown: "2s", Quota: 5, Period: "1m"}.Parse()
+	if assert.Nil(t, err) {
+		assert.Equal(t, RateLimit{2 * time.Second, 5, time.Minute}, limit)
+	}
+
+	_, err = RateLimitCfg{Quota: 5}.Parse()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "'Period' is required with 'Quota'", err.Error())
+	}
+
+	_, err = RateLimitCfg{Cooldown: "soon"}.Parse()
+	assert.NotNil(t, err)
+}
+
+func TestRateLimiterCooldown(t *testing.T) {
+	limiter := NewRateLimiter()
+	limit := RateLimit{Cooldown: 2 * time.Second}
+
+	assert.Nil(t, limiter.Allow(fakeNow, "play", limit, RateLimit{}, "#test", "irc/bob"))
+
+	// Anyone else in the same channel has to wait.
+	err := limiter.Allow(fakeNow.Add(time.Second), "play", limit, RateLimit{}, "#test", "irc/alice")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "play was used a moment ago, please try again in 1s", err.Error())
+	}
+
+	// Other channels are not affected.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(time.Second), "play", limit, RateLimit{}, "#dev", "irc/alice"))
+
+	assert.Nil(t, limiter.Allow(fakeNow.Add(2*time.Second), "play", limit, RateLimit{}, "#test", "irc/alice"))
+
+	// The cooldowns are forgotten once over.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(time.Hour), "ping", RateLimit{}, RateLimit{}, "#test", "irc/alice"))
+	assert.Empty(t, limiter.cooldowns)
+}
+
+func TestRateLimiterQuota(t *testing.T) {
+	limiter := NewRateLimiter()
+	limit := RateLimit{Quota: 2, Period: time.Minute}
+	global := RateLimit{Quota: 3, Period: time.Minute}
+
+	assert.Nil(t, limiter.Allow(fakeNow, "play", limit, global, "#test", "irc/bob"))
+	assert.Nil(t, limiter.Allow(fakeNow.Add(10*time.Second), "play", limit, global, "#test", "irc/bob"))
+
+	err := limiter.Allow(fakeNow.Add(20*time.Second), "play", limit, global, "#test", "irc/bob")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "you can only use play 2 times every 1m, please try again in 40s", err.Error())
+	}
+
+	// Other users have their own quota.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(20*time.Second), "play", limit, global, "#test", "irc/alice"))
+
+	// The throttled run was not counted against the global quota.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(20*time.Second), "say", RateLimit{}, global, "#test", "irc/bob"))
+	err = limiter.Allow(fakeNow.Add(30*time.Second), "image", RateLimit{}, global, "#test", "irc/bob")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "you can only use commands 3 times every 1m, please try again in 30s", err.Error())
+	}
+
+	// The oldest run expired.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(time.Minute), "play", limit, global, "#test", "irc/bob"))
+
+	// The expired runs are forgotten.
+	assert.Nil(t, limiter.Allow(fakeNow.Add(time.Hour), "ping", RateLimit{}, RateLimit{}, "#test", "irc/bob"))
+	assert.Empty(t, limiter.recentRuns)
+
+	commands, users := limiter.Counters()
+	assert.Equal(t, RateLimitCounter{Runs: 4, Throttled: 1}, commands["play"])
+	assert.Equal(t, RateLimitCounter{Runs: 0, Throttled: 1}, commands["image"])
+	assert.Equal(t, RateLimitCounter{Runs: 5, Throttled: 2}, users["irc/bob"])
+	assert.Equal(t, RateLimitCounter{Runs: 1, Throttled: 0}, users["irc/alice"])
+}
+
+func TestServerRateLimit(t *testing.T) {
+	var calls int
+
+	srv := CreateTestServer()
+	srv.Limits["ping"] = RateLimit{Quota: 1, Period: time.Hour}
+	srv.RegisterCommand(Command{
+		Name: "ping",
+		PrivMsgFunction: func(srv *Server, msg *InputMessage) {
+			calls++
+		},
+		RateLimit: RateLimit{Cooldown: time.Hour},
+	})
+	srv.Roles.Set("test", "alice", RoleAdmin)
+
+	msg := &InputMessage{Adapter: "test", Nickname: "bob", ReplyTo: "#test"}
+	srv.RunCommand("ping", msg)
+	srv.RunCommand("ping", msg)
+
+	// Admins are not limited.
+	msg = &InputMessage{Adapter: "test", Nickname: "alice", ReplyTo: "#test"}
+	srv.RunCommand("ping", msg)
+	srv.RunCommand("ping", msg)
+
+	// Neither is the screensaver.
+	msg = &InputMessage{
+		Type:     InputMsgTypeScreensaver,
+		Nickname: srv.Config.Nickname,
+		ReplyTo:  "#test",
+	}
+	srv.RunCommand("ping", msg)
+	srv.RunCommand("ping", msg)
+
+	assert.Equal(t, 5, calls)
+
+	msgs := srv.FlushOutputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#test", msgs[0].Channel)
+		assert.Equal(t, "sorry bob, you can only use ping once every 1h, please try again in 1h", msgs[0].Body)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler := &CommandStatsHandler{srv}
+	handler.ServeHTTP(recorder, &http.Request{Header: http.Header{}})
+
+	var response CommandStatsResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	if assert.Nil(t, err) {
+		assert.Equal(t, RateLimitCounter{Runs: 5, Throttled: 1}, response.Commands["ping"])
+		assert.Equal(t, RateLimitCounter{Runs: 1, Throttled: 1}, response.Users["test/bob"])
+		assert.Equal(t, RateLimitCounter{Runs: 2, Throttled: 0}, response.Users["test/alice"])
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This file contains the registry of media resolvers.  A Resolver handles
+// the URLs of a given provider (e.g. YouTube) which need special formatting
+// so the minions can embed them, each provider lives in its own
+// resolver_*.go file.
+//
+
+package main
+
+import (
+	"net/http"
+)
+
+// Resolver is the interface implemented by all the media providers.
+type Resolver interface {
+	// Name returns the human readable name of the provider (e.g.
+	// "YouTube"), see Media.GetSource().
+	Name() string
+
+	// Match returns true if this resolver handles the URL of the media
+	// (media.url, media.host).
+	Match(media *Media) bool
+
+	// Resolve sets the Src, Format and metadata (e.g. title) of the
+	// media.  The header is the result of the HEAD request made on the
+	// URL.  Resolvers unable to make sense of a URL should fall back to
+	// media.setFormatFromHeader().
+	Resolve(media *Media, header http.Header) error
+}
+
+// RegisterResolver adds a media resolver to the registry.  Resolvers are
+// tried in the order they are registered.
+func (srv *Server) RegisterResolver(resolver Resolver) {
+	srv.Resolvers = append(srv.Resolvers, resolver)
+}
+
+// GetResolver returns the first resolver handling the URL of the given media,
+// or nil.
+func (srv *Server) GetResolver(media *Media) Resolver {
+	for _, resolver := range srv.Resolvers {
+		if resolver.Match(media) {
+			return resolver
+		}
+	}
+
+	return nil
+}
+
+// matchHost returns true if the host is one of the given host names.
+func matchHost(host string, hostNames []string) bool {
+	for _, hostName := range hostNames {
+		if host == hostName {
+			return true
+		}
+	}
+	return false
+}
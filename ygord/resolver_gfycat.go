@@ -0,0 +1,121 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	gfycatHostNames = []string{
+		"gfycat.com",
+		"www.gfycat.com",
+		"zippy.gfycat.com",
+		"fat.gfycat.com",
+		"giant.gfycat.com",
+		"center.gfycat.com",
+		"center2.gfycat.com",
+		"centre.gfycat.com",
+		"test.gfycat.com",
+		"upload.gfycat.com",
+	}
+)
+
+// GfycatResolver handles the Gfycat links, they are resolved to their mp4
+// version (or webm, or gif) using the Gfycat API.
+type GfycatResolver struct {
+	// URL of the API endpoint the name of the gfy is appended to,
+	// defaults to the official one.  Host names handled, defaults to the
+	// Gfycat ones.
+	APIURL    string
+	HostNames []string
+}
+
+// Name returns the name of the provider.
+func (resolver *GfycatResolver) Name() string {
+	return "Gfycat"
+}
+
+// Match returns true if the media is hosted on Gfycat.
+func (resolver *GfycatResolver) Match(media *Media) bool {
+	if resolver.HostNames != nil {
+		return matchHost(media.host, resolver.HostNames)
+	}
+	return matchHost(media.host, gfycatHostNames)
+}
+
+// Resolve sets the format from the header and, unless it is already an mp4,
+// attempts to find the mp4 version using the Gfycat API.
+func (resolver *GfycatResolver) Resolve(media *Media, header http.Header) error {
+	err := media.setFormatFromHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(strings.ToLower(media.mediaType), "video/mp4") {
+		return nil
+	}
+
+	// The content itself is fine, the API is only used to get a better
+	// version of it, errors are not fatal.
+	resolver.resolve(media)
+
+	return nil
+}
+
+// resolve queries the Gfycat API to find the URL of the mp4 version of the
+// media, falling back to the webm, then the gif versions.  If the API does
+// not know about this gfy, nothing is changed.
+func (resolver *GfycatResolver) resolve(media *Media) error {
+	apiURL := resolver.APIURL
+	if apiURL == "" {
+		apiURL = "http://gfycat.com/cajax/get/"
+	}
+
+	res, err := media.httpRequest("GET", apiURL+path.Base(media.Src))
+	if err != nil {
+		return err
+	}
+	rBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return errors.New("malformed Gfycat API response " +
+			"(could not read all)")
+	}
+
+	var dat map[string]map[string]interface{}
+	if err := json.Unmarshal(rBody, &dat); err != nil {
+		return errors.New("malformed Gfycat API response " +
+			"(could not parse)")
+	}
+
+	gfyItem, ok := dat["gfyItem"]
+	if !ok {
+		return nil
+	}
+
+	if mp4URL, ok := gfyItem["mp4Url"].(string); ok {
+		media.Src = mp4URL
+		media.Format = "video"
+		media.mediaType = "video/mp4"
+	} else if webmURL, ok := gfyItem["webmUrl"].(string); ok {
+		media.Src = webmURL
+		media.Format = "video"
+		media.mediaType = "video/webm"
+	} else if gifURL, ok := gfyItem["gifUrl"].(string); ok {
+		media.Src = gifURL
+		media.Format = "img"
+		media.mediaType = "image/gif"
+	} else {
+		return errors.New("malformed Gfycat API response " +
+			"(no content URLs provided)")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGfycatResolver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/DancingCat":
+			fmt.Fprint(w, `{"gfyItem": {"mp4Url": "https://giant.gfycat.com/DancingCat.mp4", "webmUrl": "https://giant.gfycat.com/DancingCat.webm"}}`)
+		case "/api/WebmCat":
+			fmt.Fprint(w, `{"gfyItem": {"webmUrl": "https://giant.gfycat.com/WebmCat.webm"}}`)
+		case "/api/GifCat":
+			fmt.Fprint(w, `{"gfyItem": {"gifUrl": "https://giant.gfycat.com/GifCat.gif"}}`)
+		default:
+			fmt.Fprint(w, `{"error": "not found"}`)
+		}
+	}))
+	defer ts.Close()
+	resolver := &GfycatResolver{APIURL: ts.URL + "/api/"}
+	header := http.Header{"Content-Type": {"text/html"}}
+
+	for name, expected := range map[string][]string{
+		"DancingCat": {"https://giant.gfycat.com/DancingCat.mp4", "video"},
+		"WebmCat":    {"https://giant.gfycat.com/WebmCat.webm", "video"},
+		"GifCat":     {"https://giant.gfycat.com/GifCat.gif", "img"},
+		"Unknown":    {"https://gfycat.com/Unknown", "web"},
+	} {
+		media := &Media{Src: "https://gfycat.com/" + name, host: "gfycat.com"}
+		assert.True(t, resolver.Match(media))
+		if assert.Nil(t, resolver.Resolve(media, header), name) {
+			assert.Equal(t, expected[0], media.Src, name)
+			assert.Equal(t, expected[1], media.Format, name)
+		}
+	}
+
+	// Direct links to the mp4 are not resolved.
+	media := &Media{Src: "https://giant.gfycat.com/Other.mp4", host: "giant.gfycat.com"}
+	header = http.Header{"Content-Type": {"video/mp4"}}
+	if assert.Nil(t, resolver.Resolve(media, header)) {
+		assert.Equal(t, "https://giant.gfycat.com/Other.mp4", media.Src)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+var (
+	imgurHostNames = []string{
+		"i.imgur.com",
+		"www.imgur.com",
+		"imgur.com",
+	}
+)
+
+// ImgurResolver handles the imgur images, GIFs are replaced with the far more
+// efficient mp4 version when there is one.
+type ImgurResolver struct {
+	// Host names handled, defaults to the imgur ones.
+	HostNames []string
+}
+
+// Name returns the name of the provider.
+func (resolver *ImgurResolver) Name() string {
+	return "imgur"
+}
+
+// Match returns true if the media is hosted on imgur.
+func (resolver *ImgurResolver) Match(media *Media) bool {
+	if resolver.HostNames != nil {
+		return matchHost(media.host, resolver.HostNames)
+	}
+	return matchHost(media.host, imgurHostNames)
+}
+
+// Resolve sets the format from the header, then switches GIFs to their mp4
+// version.
+func (resolver *ImgurResolver) Resolve(media *Media, header http.Header) error {
+	err := media.setFormatFromHeader(header)
+	if err != nil {
+		return err
+	}
+
+	isGIF := strings.Contains(strings.ToLower(media.mediaType), "image/gif")
+	hasGIFVExt := media.GetExt() == ".gifv"
+	if (isGIF || hasGIFVExt) && resolver.hasMP4(media) {
+		media.replaceSrcExt(".mp4")
+		media.Format = "video"
+		media.mediaType = "video/mp4"
+	}
+
+	return nil
+}
+
+// hasMP4 checks if there is an mp4 version of the media.
+func (resolver *ImgurResolver) hasMP4(media *Media) bool {
+	mp4URL := media.Src[0:len(media.Src)-len(media.GetExt())] + ".mp4"
+	res, err := media.httpRequest("HEAD", mp4URL)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK
+}
@@ -0,0 +1,47 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImgurResolver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dancing.gif", "/static.gif":
+			w.Header().Set("Content-Type", "image/gif")
+		case "/dancing.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	uri, _ := url.Parse(ts.URL)
+
+	srv := CreateTestServer()
+	srv.RegisterResolver(&ImgurResolver{HostNames: []string{uri.Host}})
+
+	media, err := NewMedia(srv, map[string]string{"url": ts.URL + "/dancing.gif"},
+		"imageTrack", true, true, nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, ts.URL+"/dancing.mp4", media.Src)
+		assert.Equal(t, "video", media.Format)
+		assert.Equal(t, "imgur", media.GetSource())
+	}
+
+	// Without mp4 version, the GIF is used as-is.
+	media, err = NewMedia(srv, map[string]string{"url": ts.URL + "/static.gif"},
+		"imageTrack", true, true, nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, ts.URL+"/static.gif", media.Src)
+		assert.Equal(t, "img", media.Format)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	soundcloudHostNames = []string{
+		"soundcloud.com",
+		"www.soundcloud.com",
+		"api.soundcloud.com",
+		"www.api.soundcloud.com",
+	}
+)
+
+// SoundCloudResolver handles the SoundCloud tracks, they are resolved to their
+// track URI using the SoundCloud API.
+type SoundCloudResolver struct {
+	// Client ID used to query the API, see Config.SoundCloudClientID.
+	ClientID string
+
+	// URL of the resolve endpoint of the API and of the oEmbed endpoint,
+	// default to the official ones.  Host names handled, defaults to the
+	// SoundCloud ones.
+	APIURL    string
+	OEmbedURL string
+	HostNames []string
+}
+
+// Name returns the name of the provider.
+func (resolver *SoundCloudResolver) Name() string {
+	return "SoundCloud"
+}
+
+// Match returns true if the media is hosted on SoundCloud.
+func (resolver *SoundCloudResolver) Match(media *Media) bool {
+	if resolver.HostNames != nil {
+		return matchHost(media.host, resolver.HostNames)
+	}
+	return matchHost(media.host, soundcloudHostNames)
+}
+
+// Resolve attempts to find the track URI of the SoundCloud link. If it is not
+// a link to a track, the URL is handled like any other.
+func (resolver *SoundCloudResolver) Resolve(media *Media, header http.Header) error {
+	if resolver.ClientID == "" {
+		return errors.New("SoundCloudClientID is not configured")
+	}
+
+	apiURL := resolver.APIURL
+	if apiURL == "" {
+		apiURL = "http://api.soundcloud.com/resolve"
+	}
+
+	res, err := media.httpRequest("GET", apiURL+"?url="+
+		url.QueryEscape(media.Src)+"&client_id="+
+		url.QueryEscape(resolver.ClientID))
+	if err != nil {
+		return err
+	}
+	rBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return errors.New("malformed SoundCloud API response " +
+			"(could not read all)")
+	}
+
+	var dat map[string]interface{}
+	if err := json.Unmarshal(rBody, &dat); err != nil {
+		return errors.New("malformed SoundCloud API response " +
+			"(could not parse)")
+	}
+
+	if kind, _ := dat["kind"].(string); kind != "track" {
+		// Not a link to a SoundCloud track, handle the URL like any
+		// other.
+		return media.setFormatFromHeader(header)
+	}
+
+	trackURI, ok := dat["uri"].(string)
+	if !ok {
+		return errors.New("malformed SoundCloud API response " +
+			"(no track uri)")
+	}
+
+	if title, ok := dat["title"].(string); ok {
+		media.metadata.Title = title
+	}
+	if duration, ok := dat["duration"].(float64); ok {
+		media.metadata.Duration = time.Duration(duration) * time.Millisecond
+	}
+	media.Src = trackURI
+	media.Format = "soundcloud"
+	media.mediaType = "soundcloud"
+
+	return nil
+}
+
+// FetchMetadata queries the oEmbed endpoint for the artist and artwork of the
+// track, its title and duration are already known from the API.
+func (resolver *SoundCloudResolver) FetchMetadata(media *Media) (*MediaMetadata, error) {
+	if media.Format != "soundcloud" {
+		return &MediaMetadata{}, nil
+	}
+
+	endpoint := resolver.OEmbedURL
+	if endpoint == "" {
+		endpoint = "https://soundcloud.com/oembed"
+	}
+
+	return fetchOEmbed(media.context(), endpoint, media.url)
+}
@@ -0,0 +1,79 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSoundCloudTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client-id" {
+			http.Error(w, "unauthorized", 401)
+			return
+		}
+
+		switch r.FormValue("url") {
+		case "https://soundcloud.com/artist/track":
+			fmt.Fprint(w, `{"kind": "track", "title": "Fresh Pots", "duration": 222000, "uri": "https://api.soundcloud.com/tracks/42"}`)
+		case "https://soundcloud.com/artist/broken":
+			fmt.Fprint(w, `{"kind": "track"}`)
+		case "https://soundcloud.com/artist":
+			fmt.Fprint(w, `{"kind": "user"}`)
+		default:
+			fmt.Fprint(w, `nope`)
+		}
+	}))
+}
+
+func TestSoundCloudResolver(t *testing.T) {
+	ts := newSoundCloudTestServer()
+	defer ts.Close()
+	resolver := &SoundCloudResolver{ClientID: "client-id", APIURL: ts.URL}
+
+	media := &Media{Src: "https://soundcloud.com/artist/track", host: "soundcloud.com"}
+	assert.True(t, resolver.Match(media))
+	if assert.Nil(t, resolver.Resolve(media, nil)) {
+		assert.Equal(t, "https://api.soundcloud.com/tracks/42", media.Src)
+		assert.Equal(t, "soundcloud", media.Format)
+		assert.Equal(t, "Fresh Pots", media.GetTitle())
+		assert.Equal(t, 222*time.Second, media.GetDuration())
+	}
+
+	// Not a track, handled according to its Content-Type.
+	media = &Media{Src: "https://soundcloud.com/artist", host: "soundcloud.com"}
+	header := http.Header{"Content-Type": {"text/html"}}
+	if assert.Nil(t, resolver.Resolve(media, header)) {
+		assert.Equal(t, "https://soundcloud.com/artist", media.Src)
+		assert.Equal(t, "web", media.Format)
+	}
+}
+
+func TestSoundCloudResolverErrors(t *testing.T) {
+	ts := newSoundCloudTestServer()
+	defer ts.Close()
+
+	for src, expected := range map[string]string{
+		"https://soundcloud.com/artist/broken": "malformed SoundCloud API response (no track uri)",
+		"https://soundcloud.com/wat":           "malformed SoundCloud API response (could not parse)",
+	} {
+		resolver := &SoundCloudResolver{ClientID: "client-id", APIURL: ts.URL}
+		err := resolver.Resolve(&Media{Src: src}, nil)
+		if assert.NotNil(t, err, src) {
+			assert.Equal(t, expected, err.Error())
+		}
+	}
+
+	resolver := &SoundCloudResolver{APIURL: ts.URL}
+	err := resolver.Resolve(&Media{Src: "https://soundcloud.com/artist/track"}, nil)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "SoundCloudClientID is not configured", err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerGetResolver(t *testing.T) {
+	srv := CreateTestServer()
+	youtube := &YouTubeResolver{}
+	vimeo := &VimeoResolver{}
+	srv.RegisterResolver(youtube)
+	srv.RegisterResolver(vimeo)
+
+	assert.Equal(t, youtube, srv.GetResolver(&Media{host: "youtu.be"}))
+	assert.Equal(t, vimeo, srv.GetResolver(&Media{host: "player.vimeo.com"}))
+	assert.Nil(t, srv.GetResolver(&Media{host: "example.com"}))
+}
+
+func TestYouTubeResolver(t *testing.T) {
+	resolver := &YouTubeResolver{}
+
+	media := &Media{Src: "https://www.youtube.com/watch?v=dQw4w9WgXcQ", host: "www.youtube.com"}
+	if assert.Nil(t, resolver.Resolve(media, nil)) {
+		assert.Equal(t, "dQw4w9WgXcQ", media.Src)
+		assert.Equal(t, "youtube", media.Format)
+	}
+
+	// Anything else is handled according to its Content-Type.
+	media = &Media{Src: "https://www.youtube.com/", host: "www.youtube.com"}
+	header := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	if assert.Nil(t, resolver.Resolve(media, header)) {
+		assert.Equal(t, "https://www.youtube.com/", media.Src)
+		assert.Equal(t, "web", media.Format)
+	}
+}
+
+func TestYouTubeResolverStart(t *testing.T) {
+	resolver := &YouTubeResolver{}
+
+	link := "https://youtu.be/dQw4w9WgXcQ?t=1m30s"
+	media := &Media{Src: link, url: link, host: "youtu.be"}
+	if assert.Nil(t, resolver.Resolve(media, nil)) {
+		assert.Equal(t, "dQw4w9WgXcQ", media.Src)
+		assert.Equal(t, "90", media.Start)
+	}
+
+	for link, expected := range map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ":          "",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42":     "42",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&start=42": "42",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ#t=1:02":   "62",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=soon":   "",
+	} {
+		assert.Equal(t, expected, youtubeStart(link), link)
+	}
+}
+
+func TestVimeoResolver(t *testing.T) {
+	resolver := &VimeoResolver{}
+
+	media := &Media{Src: "https://vimeo.com/76979871", host: "vimeo.com"}
+	if assert.Nil(t, resolver.Resolve(media, nil)) {
+		assert.Equal(t, "76979871", media.Src)
+		assert.Equal(t, "vimeo", media.Format)
+	}
+
+	media = &Media{Src: "https://vimeo.com/channels", host: "vimeo.com"}
+	header := http.Header{"Content-Type": {"text/html"}}
+	if assert.Nil(t, resolver.Resolve(media, header)) {
+		assert.Equal(t, "https://vimeo.com/channels", media.Src)
+		assert.Equal(t, "web", media.Format)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+)
+
+var (
+	vimeoHostNames = []string{
+		"vimeo.com",
+		"www.vimeo.com",
+		"player.vimeo.com",
+		"www.player.vimeo.com",
+	}
+)
+
+// VimeoResolver handles the Vimeo videos, the minions embed them using their
+// video ID.
+type VimeoResolver struct {
+	// URL of the oEmbed endpoint, defaults to the official one.
+	OEmbedURL string
+}
+
+// Name returns the name of the provider.
+func (resolver *VimeoResolver) Name() string {
+	return "Vimeo"
+}
+
+// Match returns true if the media is hosted on Vimeo.
+func (resolver *VimeoResolver) Match(media *Media) bool {
+	return matchHost(media.host, vimeoHostNames)
+}
+
+// Resolve extracts the video ID from the URL.
+func (resolver *VimeoResolver) Resolve(media *Media, header http.Header) error {
+	// Vimeo video IDs are the last element in the URL (represented as an
+	// integer between 6 and 11 digits long) before the query string
+	// and/or fragment. media.Src has the query string and fragment
+	// stripped off, so if this is a link to a Vimeo video,
+	// potentialVideoID should be an integer between 6 and 11 digits long.
+	potentialVideoID := path.Base(media.Src)
+	if len(potentialVideoID) < 6 || len(potentialVideoID) > 11 {
+		return media.setFormatFromHeader(header)
+	}
+	if _, err := strconv.Atoi(potentialVideoID); err != nil {
+		return media.setFormatFromHeader(header)
+	}
+
+	media.Src = potentialVideoID
+	media.Format = "vimeo"
+	media.mediaType = "vimeo"
+
+	return nil
+}
+
+// FetchMetadata queries the oEmbed endpoint for the title and duration of the
+// video.
+func (resolver *VimeoResolver) FetchMetadata(media *Media) (*MediaMetadata, error) {
+	if media.Format != "vimeo" {
+		return &MediaMetadata{}, nil
+	}
+
+	endpoint := resolver.OEmbedURL
+	if endpoint == "" {
+		endpoint = "https://vimeo.com/api/oembed.json"
+	}
+
+	return fetchOEmbed(media.context(), endpoint, media.url)
+}
@@ -0,0 +1,97 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+var (
+	youtubeHostNames = []string{
+		"www.youtube.com",
+		"www.youtu.be",
+		"youtube.com",
+		"youtu.be",
+	}
+
+	reYTVideoID = regexp.MustCompile(
+		`^.*(youtu.be\/|v\/|u\/\w\/|embed\/|watch\?v=|\&v=)([^#\&\?]*).*`)
+)
+
+// YouTubeResolver handles the YouTube videos, the minions embed them using
+// their video ID.
+type YouTubeResolver struct {
+	// URL of the oEmbed endpoint, defaults to the official one.
+	OEmbedURL string
+}
+
+// Name returns the name of the provider.
+func (resolver *YouTubeResolver) Name() string {
+	return "YouTube"
+}
+
+// Match returns true if the media is hosted on YouTube.
+func (resolver *YouTubeResolver) Match(media *Media) bool {
+	return matchHost(media.host, youtubeHostNames)
+}
+
+// Resolve extracts the video ID from the URL.
+func (resolver *YouTubeResolver) Resolve(media *Media, header http.Header) error {
+	match := reYTVideoID.FindAllStringSubmatch(media.Src, -1)
+	if len(match) == 0 {
+		return media.setFormatFromHeader(header)
+	}
+
+	media.Src = match[0][2]
+	media.Format = "youtube"
+	media.mediaType = "youtube"
+	media.Start = youtubeStart(media.url)
+
+	return nil
+}
+
+// youtubeStart returns the start offset in seconds found in the "t" or
+// "start" parameter of a YouTube URL (e.g. "t=90", "t=1m30s" or "#t=1:30"),
+// or an empty string.
+func youtubeStart(link string) string {
+	uri, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	query := uri.Query()
+	fragment, _ := url.ParseQuery(uri.Fragment)
+
+	for _, value := range []string{
+		query.Get("t"),
+		query.Get("start"),
+		fragment.Get("t"),
+	} {
+		if value == "" {
+			continue
+		}
+		start, err := ParseDuration(value)
+		if err == nil && start > 0 {
+			return FormatSeconds(start)
+		}
+	}
+
+	return ""
+}
+
+// FetchMetadata queries the oEmbed endpoint for the title of the video.
+func (resolver *YouTubeResolver) FetchMetadata(media *Media) (*MediaMetadata, error) {
+	if media.Format != "youtube" {
+		return &MediaMetadata{}, nil
+	}
+
+	endpoint := resolver.OEmbedURL
+	if endpoint == "" {
+		endpoint = "https://www.youtube.com/oembed"
+	}
+
+	return fetchOEmbed(media.context(), endpoint, media.url)
+}
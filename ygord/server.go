@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"io"
 	"log"
+	"sort"
 	"strings"
 
 	"github.com/truveris/ygor/ygord/alias"
@@ -16,12 +17,25 @@ import (
 // singleton and is available in most sub-system to access the current state
 // or the configuration struct.
 type Server struct {
+	AdminLog           *AdminLog
+	AliasChecker       *AliasChecker
 	Aliases            *alias.File
+	AuditLog           *AuditLog
+	ChatAdapters       map[string]ChatAdapter
 	ClientRegistry     map[string]*Client
 	InputQueue         chan *InputMessage
+	Library            *Library
+	Limits             map[string]RateLimit
+	MediaCache         *MediaCache
+	MediaProxy         *MediaProxy
+	MediaQueue         *MediaQueue
 	OutputQueue        chan *OutputMessage
 	Modules            []Module
+	RateLimiter        *RateLimiter
 	RegisteredCommands map[string]Command
+	Resolvers          []Resolver
+	Roles              *RoleRegistry
+	ToggleCommands     []Command
 	Salt               []byte
 	*Config
 }
@@ -37,7 +51,74 @@ func CreateServer(config *Config) *Server {
 		log.Fatal("alias file error: ", err.Error())
 	}
 
+	srv.AdminLog, err = NewAdminLog(config.AdminLogLevel,
+		config.AdminLogQuota, config.AdminLogPeriod)
+	if err != nil {
+		log.Fatal("admin log error: ", err.Error())
+	}
+
+	srv.AuditLog, err = OpenAuditLog(config.AuditLogPath,
+		int64(config.AuditLogMaxSize)*1024*1024, config.AuditLogMaxFiles)
+	if err != nil {
+		log.Fatal("audit log error: ", err.Error())
+	}
+
+	srv.Roles, err = NewRoleRegistry(config.Roles)
+	if err != nil {
+		log.Fatal("roles error: ", err.Error())
+	}
+
+	srv.Limits = make(map[string]RateLimit)
+	for name, cfg := range config.RateLimits {
+		srv.Limits[name], err = cfg.Parse()
+		if err != nil {
+			log.Fatal("rate limit error: ", name, ": ", err.Error())
+		}
+	}
+	srv.RateLimiter = NewRateLimiter()
+
+	srv.MediaCache, err = NewMediaCache(config.MediaCacheSize,
+		config.MediaCacheTTL, config.MediaCacheNegativeTTL)
+	if err != nil {
+		log.Fatal("media cache error: ", err.Error())
+	}
+	if err := srv.MediaCache.Load(config.MediaCachePath); err != nil {
+		log.Fatal("media cache error: ", err.Error())
+	}
+
+	if config.LibraryDir != "" {
+		srv.Library, err = OpenLibrary(config.LibraryDir,
+			int64(config.LibraryMaxFileSize)*1024*1024,
+			config.LibraryURL)
+		if err != nil {
+			log.Fatal("library error: ", err.Error())
+		}
+	}
+
+	if config.MediaProxyDir != "" {
+		srv.MediaProxy, err = NewMediaProxy(config.MediaProxyDir,
+			int64(config.MediaProxyMaxSize)*1024*1024,
+			int64(config.MediaProxyMaxFileSize)*1024*1024,
+			config.MediaProxyURL)
+		if err != nil {
+			log.Fatal("media proxy error: ", err.Error())
+		}
+	}
+
+	srv.AliasChecker, err = NewAliasChecker(config.AliasCheckInterval)
+	if err != nil {
+		log.Fatal("alias checker error: ", err.Error())
+	}
+
+	srv.MediaQueue, err = NewMediaQueue(config.MediaWorkers,
+		config.MediaTimeout)
+	if err != nil {
+		log.Fatal("media queue error: ", err.Error())
+	}
+	srv.MediaQueue.Start()
+
 	srv.RegisteredCommands = make(map[string]Command)
+	srv.ChatAdapters = make(map[string]ChatAdapter)
 	srv.InputQueue = make(chan *InputMessage, 128)
 	srv.OutputQueue = make(chan *OutputMessage, 128)
 
@@ -52,29 +133,43 @@ func CreateServer(config *Config) *Server {
 	return srv
 }
 
-func (srv *Server) SendToClient(client *Client, cmd ClientCommand) {
-	if client.IsAlive() {
-		client.Queue <- cmd
-	} else {
+// SendToClient queues a command for the given client, returning false if the
+// client is dead (it is then unregistered).
+func (srv *Server) SendToClient(client *Client, cmd ClientCommand) bool {
+	if !client.IsAlive() {
+		srv.Warningf("client %s (%s) is dead, unregistering",
+			client.ID, client.Channel)
 		srv.UnregisterClient(client)
+		return false
 	}
+
+	client.Queue <- cmd
+	return true
 }
 
 // SendToChannelMinions sends a message to all the minions of the given
-// channel.
-func (srv *Server) SendToChannelMinions(channel string, cmd ClientCommand) {
+// channel, returning the IDs of the clients it was sent to.
+func (srv *Server) SendToChannelMinions(channel string, cmd ClientCommand) []string {
+	var ids []string
+
 	// If that channel is really just a client ID, just send it there (this
 	// is done by the screensaver module for example to reach a particular
 	// client).
 	if client, ok := srv.ClientRegistry[channel]; ok {
-		srv.SendToClient(client, cmd)
-		return
+		if srv.SendToClient(client, cmd) {
+			ids = append(ids, client.ID)
+		}
+		return ids
 	}
 
 	channel = strings.TrimPrefix(channel, "#")
 	for _, client := range srv.GetClientsByChannel(channel) {
-		srv.SendToClient(client, cmd)
+		if srv.SendToClient(client, cmd) {
+			ids = append(ids, client.ID)
+		}
 	}
+
+	return ids
 }
 
 // RegisterModule adds a module to our global registry.
@@ -84,9 +179,18 @@ func (srv *Server) RegisterModule(module Module) {
 }
 
 // RegisterCommand adds a command to the registry.  There could be only one
-// command registered for each name.
+// command registered for each name.  Commands with a ToggleFunction are also
+// kept in ToggleCommands, sorted by priority.
 func (srv *Server) RegisterCommand(cmd Command) {
 	srv.RegisteredCommands[cmd.Name] = cmd
+
+	srv.ToggleCommands = nil
+	for _, cmd := range srv.RegisteredCommands {
+		if cmd.ToggleFunction != nil {
+			srv.ToggleCommands = append(srv.ToggleCommands, cmd)
+		}
+	}
+	sort.Sort(commandsByPriority(srv.ToggleCommands))
 }
 
 // GetCommand returns a registered command or nil.
@@ -98,6 +202,67 @@ func (srv *Server) GetCommand(name string) *Command {
 	return nil
 }
 
+// FindCommand returns the command to execute for the given message or nil.
+// The command registered with the exact name of the message always wins,
+// otherwise the toggle commands are tried in priority order.
+func (srv *Server) FindCommand(msg *InputMessage) *Command {
+	cmd := srv.GetCommand(msg.Command)
+	if cmd != nil && cmd.PrivMsgFunction != nil && cmd.IsAllowed(msg) {
+		return cmd
+	}
+
+	for _, cmd := range srv.ToggleCommands {
+		if !cmd.ToggleFunction(srv, msg) || !cmd.IsAllowed(msg) {
+			continue
+		}
+
+		if cmd.PrivMsgFunction == nil {
+			srv.Warningf("misconfigured command: %s (no PrivMsg)",
+				cmd.Name)
+			continue
+		}
+
+		return &cmd
+	}
+
+	return nil
+}
+
+// RunCommand executes the named command with the given message, see
+// Command.Run.
+func (srv *Server) RunCommand(name string, msg *InputMessage) {
+	cmd := srv.GetCommand(name)
+	if cmd == nil || cmd.PrivMsgFunction == nil {
+		srv.ReplyError(msg, "command not found: "+name)
+		return
+	}
+
+	cmd.Run(srv, msg)
+}
+
+// GetUserCommands returns all the commands available to users (minion
+// commands are skipped), sorted by name.
+func (srv *Server) GetUserCommands() []Command {
+	var cmds []Command
+
+	for _, cmd := range srv.RegisteredCommands {
+		if cmd.PrivMsgFunction == nil {
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Sort(commandsByName(cmds))
+
+	return cmds
+}
+
+type commandsByName []Command
+
+func (cmds commandsByName) Len() int           { return len(cmds) }
+func (cmds commandsByName) Swap(i, j int)      { cmds[i], cmds[j] = cmds[j], cmds[i] }
+func (cmds commandsByName) Less(i, j int) bool { return cmds[i].Name < cmds[j].Name }
+
 // FlushOutputQueue removes every single messages from the
 // OutputQueue and returns them in the form of an array.
 func (srv *Server) FlushOutputQueue() []*OutputMessage {
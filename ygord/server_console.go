@@ -0,0 +1,114 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This server_console file contains the console adapter, used to talk to ygor
+// from a terminal without any chat server.  Every line read is treated as if
+// it had been addressed to ygor by the configured nickname in the configured
+// channel.
+//
+// The message in this adapter is roughly converted as such:
+//
+//     stdin -> server_console -> ygor.InputMessage
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// ConsoleAdapter is the ChatAdapter reading commands from an input stream
+// (generally stdin) and writing replies to an output stream (generally
+// stdout).
+type ConsoleAdapter struct {
+	Input    io.Reader
+	Output   io.Writer
+	Nickname string
+	Channel  string
+	srv      *Server
+}
+
+// Name returns the name of this adapter.
+func (adapter *ConsoleAdapter) Name() string {
+	return "console"
+}
+
+// Start reads the input in the background.
+func (adapter *ConsoleAdapter) Start(srv *Server) error {
+	adapter.srv = srv
+	go adapter.readLines(srv)
+	return nil
+}
+
+// Stop does nothing, the input is closed by its owner.
+func (adapter *ConsoleAdapter) Stop() error {
+	return nil
+}
+
+// Send prints the message to the output.
+func (adapter *ConsoleAdapter) Send(msg *OutputMessage) error {
+	var err error
+
+	nickname := adapter.srv.Config.Nickname
+
+	switch msg.Type {
+	case OutputMsgTypeAction:
+		_, err = fmt.Fprintf(adapter.Output, "%s * %s %s\n",
+			msg.Channel, nickname, msg.Body)
+	default:
+		_, err = fmt.Fprintf(adapter.Output, "%s <%s> %s\n",
+			msg.Channel, nickname, msg.PlainText())
+	}
+
+	return err
+}
+
+// NewMessagesFromConsoleLine creates a new array of messages based on a single
+// line of input.
+func (adapter *ConsoleAdapter) NewMessagesFromConsoleLine(srv *Server, line string) []*InputMessage {
+	body := strings.TrimSpace(line)
+	if body == "" {
+		return nil
+	}
+
+	msgs, err := srv.NewMessagesFromBody(body, 0)
+	if err != nil {
+		errmsg := NewInputMessage()
+		errmsg.Adapter = adapter.Name()
+		errmsg.ReplyTo = adapter.Channel
+		srv.ReplyError(errmsg, "lexer/expand: "+err.Error())
+		return nil
+	}
+
+	for _, msg := range msgs {
+		msg.Type = InputMsgTypeChannel
+		msg.Adapter = adapter.Name()
+		msg.Nickname = adapter.Nickname
+		msg.ReplyTo = adapter.Channel
+	}
+
+	return msgs
+}
+
+// readLines feeds every line of the input to the server until EOF.
+func (adapter *ConsoleAdapter) readLines(srv *Server) {
+	scanner := bufio.NewScanner(adapter.Input)
+	for scanner.Scan() {
+		msgs := adapter.NewMessagesFromConsoleLine(srv, scanner.Text())
+		for _, msg := range msgs {
+			srv.InputQueue <- msg
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		log.Printf("console: read error: %s", err.Error())
+		return
+	}
+
+	log.Printf("console: end of input")
+}
@@ -0,0 +1,79 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestServerAndConsoleAdapter(input string) (*Server, *ConsoleAdapter, *bytes.Buffer) {
+	srv := CreateTestServer()
+	output := &bytes.Buffer{}
+
+	adapter := &ConsoleAdapter{
+		Input:    strings.NewReader(input),
+		Output:   output,
+		Nickname: "human",
+		Channel:  "#test",
+	}
+	srv.RegisterChatAdapter(adapter)
+	adapter.srv = srv
+
+	return srv, adapter, output
+}
+
+func TestServerConsoleLines(t *testing.T) {
+	srv, adapter, _ := createTestServerAndConsoleAdapter(
+		"play freshpots.mp3\n\n  image freshpots.gif; nop\n")
+
+	adapter.readLines(srv)
+
+	msgs := srv.FlushInputQueue()
+	if assert.Len(t, msgs, 3) {
+		assert.Equal(t, "play freshpots.mp3", msgs[0].Body)
+		assert.Equal(t, "image freshpots.gif", msgs[1].Body)
+		assert.Equal(t, "nop", msgs[2].Body)
+		for _, msg := range msgs {
+			assert.Equal(t, "console", msg.Adapter)
+			assert.Equal(t, "human", msg.Nickname)
+			assert.Equal(t, "#test", msg.ReplyTo)
+		}
+	}
+}
+
+func TestServerConsoleResolveAlias(t *testing.T) {
+	srv, adapter, _ := createTestServerAndConsoleAdapter("")
+	srv.Aliases.Add("coffee", "play freshpots.mp3", "human", fakeNow)
+
+	msgs := adapter.NewMessagesFromConsoleLine(srv, "coffee")
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "play freshpots.mp3", msgs[0].Body)
+	}
+}
+
+func TestServerConsoleReply(t *testing.T) {
+	srv, _, output := createTestServerAndConsoleAdapter("")
+
+	msg := &InputMessage{Adapter: "console", ReplyTo: "#test"}
+	srv.Reply(msg, "hello")
+	srv.ReplyAction(msg, "waves")
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, "#test <whygore> hello\n#test * whygore waves\n", output.String())
+}
+
+func TestServerConsoleLexerError(t *testing.T) {
+	srv, adapter, output := createTestServerAndConsoleAdapter("")
+
+	msgs := adapter.NewMessagesFromConsoleLine(srv, `play "freshpots`)
+	assert.Empty(t, msgs)
+
+	srv.DispatchOutputQueue()
+	assert.Equal(t, "#test <whygore> error: lexer/expand: missing quote termination\n",
+		output.String())
+}
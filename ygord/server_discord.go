@@ -0,0 +1,435 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This server_discord file contains the Discord adapter.  ygor connects to the
+// Discord gateway as a bot to receive messages and replies using the REST
+// API.  Every configured channel with a DiscordChannelID is bridged to that
+// Discord channel.
+//
+// The message in this adapter is roughly converted as such:
+//
+//     Discord gateway -> MESSAGE_CREATE -> server_discord -> ygor.InputMessage
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Gateway opcodes used by this adapter.
+const (
+	DiscordOpDispatch     = 0
+	DiscordOpHeartbeat    = 1
+	DiscordOpIdentify     = 2
+	DiscordOpReconnect    = 7
+	DiscordOpHello        = 10
+	DiscordOpHeartbeatAck = 11
+
+	// DiscordRetryDelay is how long to wait before reconnecting to the
+	// gateway after a failure.
+	DiscordRetryDelay = 5 * time.Second
+)
+
+// DiscordUser is the representation of a Discord user (or bot).
+type DiscordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+// DiscordMessage is the payload of a MESSAGE_CREATE gateway event.
+type DiscordMessage struct {
+	ID        string        `json:"id"`
+	ChannelID string        `json:"channel_id"`
+	Content   string        `json:"content"`
+	Author    DiscordUser   `json:"author"`
+	Mentions  []DiscordUser `json:"mentions"`
+}
+
+type discordPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  int             `json:"s"`
+	T  string          `json:"t"`
+}
+
+type discordOutgoingPayload struct {
+	Op int         `json:"op"`
+	D  interface{} `json:"d"`
+}
+
+type discordHello struct {
+	HeartbeatInterval int `json:"heartbeat_interval"`
+}
+
+type discordReady struct {
+	User DiscordUser `json:"user"`
+}
+
+type discordIdentify struct {
+	Token      string            `json:"token"`
+	Properties map[string]string `json:"properties"`
+}
+
+type discordGatewayResponse struct {
+	URL string `json:"url"`
+}
+
+type discordMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// DiscordAdapter is the ChatAdapter connecting ygord to Discord as a bot.
+type DiscordAdapter struct {
+	chatOutbox
+
+	srv    *Server
+	client *http.Client
+	userID string
+
+	// mutex protects the fields below, accessed by the heartbeat routine.
+	mutex sync.Mutex
+	conn  *websocket.Conn
+	seq   int
+	quit  chan bool
+}
+
+// Name returns the name of this adapter.
+func (adapter *DiscordAdapter) Name() string {
+	return "discord"
+}
+
+// Start connects to the Discord gateway in the background, if Discord is
+// configured.
+func (adapter *DiscordAdapter) Start(srv *Server) error {
+	if srv.Config.DiscordBotToken == "" {
+		return nil
+	}
+
+	adapter.srv = srv
+	adapter.client = &http.Client{Timeout: 30 * time.Second}
+	adapter.quit = make(chan bool)
+	adapter.startOutbox(srv, adapter.send)
+
+	go adapter.run()
+
+	return nil
+}
+
+// Stop closes the gateway connection and stops reconnecting.
+func (adapter *DiscordAdapter) Stop() error {
+	if adapter.quit == nil {
+		return nil
+	}
+
+	close(adapter.quit)
+	adapter.stopOutbox()
+
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+	if adapter.conn != nil {
+		return adapter.conn.Close()
+	}
+
+	return nil
+}
+
+// Send queues the message for delivery in the background.
+func (adapter *DiscordAdapter) Send(msg *OutputMessage) error {
+	return adapter.push(msg)
+}
+
+// send posts the message to the Discord channel bridged to its channel,
+// rendered in markdown.  Discord bots have no /me, actions are rendered in
+// italic.
+func (adapter *DiscordAdapter) send(msg *OutputMessage) error {
+	channelID := adapter.srv.Config.Channels[msg.Channel].DiscordChannelID
+	if channelID == "" {
+		return errors.New("no Discord channel for " + msg.Channel)
+	}
+
+	return adapter.request("POST", "/channels/"+channelID+"/messages",
+		discordMessageRequest{Content: msg.Markdown()}, nil)
+}
+
+// request sends a single request to the Discord REST API.
+func (adapter *DiscordAdapter) request(method, path string, input, output interface{}) error {
+	cfg := adapter.srv.Config
+	endpoint := strings.TrimSuffix(cfg.DiscordAPIURL, "/") + path
+	return apiRequest(adapter.client, method, endpoint,
+		"Bot "+cfg.DiscordBotToken, input, output)
+}
+
+// write sends a single payload to the gateway.
+func (adapter *DiscordAdapter) write(op int, data interface{}) error {
+	adapter.mutex.Lock()
+	defer adapter.mutex.Unlock()
+
+	if adapter.conn == nil {
+		return errors.New("not connected")
+	}
+
+	return adapter.conn.WriteJSON(discordOutgoingPayload{Op: op, D: data})
+}
+
+// read waits for a single payload from the gateway and keeps track of the
+// sequence number.
+func (adapter *DiscordAdapter) read(conn *websocket.Conn) (*discordPayload, error) {
+	payload := &discordPayload{}
+
+	err := conn.ReadJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload.S != 0 {
+		adapter.mutex.Lock()
+		adapter.seq = payload.S
+		adapter.mutex.Unlock()
+	}
+
+	return payload, nil
+}
+
+// connect opens a new gateway session: it waits for the server hello,
+// identifies the bot and waits for the READY event.
+func (adapter *DiscordAdapter) connect() error {
+	gateway := &discordGatewayResponse{}
+	err := adapter.request("GET", "/gateway/bot", nil, gateway)
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		gateway.URL+"?v=6&encoding=json", nil)
+	if err != nil {
+		return err
+	}
+
+	adapter.mutex.Lock()
+	adapter.conn = conn
+	adapter.seq = 0
+	adapter.mutex.Unlock()
+
+	payload, err := adapter.read(conn)
+	if err != nil {
+		return err
+	}
+	if payload.Op != DiscordOpHello {
+		return fmt.Errorf("expected hello, got op %d", payload.Op)
+	}
+
+	hello := &discordHello{}
+	err = json.Unmarshal(payload.D, hello)
+	if err != nil {
+		return err
+	}
+
+	err = adapter.write(DiscordOpIdentify, discordIdentify{
+		Token: adapter.srv.Config.DiscordBotToken,
+		Properties: map[string]string{
+			"$os":      "linux",
+			"$browser": "ygor",
+			"$device":  "ygor",
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		payload, err = adapter.read(conn)
+		if err != nil {
+			return err
+		}
+		if payload.Op == DiscordOpDispatch && payload.T == "READY" {
+			break
+		}
+	}
+
+	ready := &discordReady{}
+	err = json.Unmarshal(payload.D, ready)
+	if err != nil {
+		return err
+	}
+	adapter.userID = ready.User.ID
+
+	interval := time.Duration(hello.HeartbeatInterval) * time.Millisecond
+	if interval <= 0 {
+		return errors.New("invalid heartbeat interval")
+	}
+	go adapter.heartbeat(conn, interval)
+
+	return nil
+}
+
+// heartbeat keeps the gateway session alive until its connection is replaced
+// or closed.
+func (adapter *DiscordAdapter) heartbeat(conn *websocket.Conn, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		adapter.mutex.Lock()
+		if adapter.conn != conn {
+			adapter.mutex.Unlock()
+			return
+		}
+		err := conn.WriteJSON(discordOutgoingPayload{
+			Op: DiscordOpHeartbeat,
+			D:  adapter.seq,
+		})
+		adapter.mutex.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readLoop dispatches all the gateway events until the connection fails or
+// the server asks us to reconnect.
+func (adapter *DiscordAdapter) readLoop() error {
+	adapter.mutex.Lock()
+	conn := adapter.conn
+	adapter.mutex.Unlock()
+
+	for {
+		payload, err := adapter.read(conn)
+		if err != nil {
+			return err
+		}
+
+		switch payload.Op {
+		case DiscordOpReconnect:
+			return errors.New("reconnect requested")
+		case DiscordOpDispatch:
+			if payload.T != "MESSAGE_CREATE" {
+				continue
+			}
+			message := &DiscordMessage{}
+			err = json.Unmarshal(payload.D, message)
+			if err != nil {
+				log.Printf("discord: bad message: %s", err.Error())
+				continue
+			}
+			msgs := adapter.srv.NewMessagesFromDiscordMessage(
+				adapter.userID, message)
+			for _, msg := range msgs {
+				adapter.srv.InputQueue <- msg
+			}
+		}
+	}
+}
+
+// run connects to the gateway and reads from it forever, reconnecting when
+// needed, until the adapter is stopped.
+func (adapter *DiscordAdapter) run() {
+	for {
+		err := adapter.connect()
+		if err == nil {
+			err = adapter.readLoop()
+		}
+
+		select {
+		case <-adapter.quit:
+			return
+		default:
+		}
+
+		adapter.srv.Warningf("discord: gateway error: %s", err.Error())
+
+		adapter.mutex.Lock()
+		if adapter.conn != nil {
+			adapter.conn.Close()
+			adapter.conn = nil
+		}
+		adapter.mutex.Unlock()
+
+		select {
+		case <-adapter.quit:
+			return
+		case <-time.After(DiscordRetryDelay):
+		}
+	}
+}
+
+// stripDiscordMention removes the mention of the given user at the beginning
+// of the message content, with or without nickname marker (<@id> or <@!id>).
+// It returns false if the content does not start with such a mention.
+func stripDiscordMention(content, userID string) (string, bool) {
+	for _, mention := range []string{"<@" + userID + ">", "<@!" + userID + ">"} {
+		if strings.HasPrefix(content, mention) {
+			content = strings.TrimPrefix(content, mention)
+			return strings.TrimLeft(content, ":,. "), true
+		}
+	}
+
+	return content, false
+}
+
+// NewMessagesFromDiscordMessage creates a new array of messages based on a
+// MESSAGE_CREATE event.  The bot is addressed either with a mention or with
+// its nickname.  Users are identified by their ID, their username can be
+// changed at will and isn't unique.
+func (srv *Server) NewMessagesFromDiscordMessage(botUserID string, message *DiscordMessage) []*InputMessage {
+	cfg := srv.Config
+
+	if message.Author.ID == botUserID || message.Author.Bot {
+		return nil
+	}
+
+	// Check if we should ignore this message.
+	for _, ignore := range cfg.Ignore {
+		if ignore == message.Author.ID {
+			log.Printf("Ignoring %s", ignore)
+			return nil
+		}
+	}
+
+	target := cfg.GetChannelByDiscordChannelID(message.ChannelID)
+	if target == "" {
+		return nil
+	}
+
+	// Ignore the message if not prefixed with a mention of the bot or with
+	// our nickname.  If it is, remove this prefix from the body of the
+	// message.
+	content := strings.TrimSpace(message.Content)
+	body, ok := stripDiscordMention(content, botUserID)
+	if !ok {
+		tokens := reAddressed.FindStringSubmatch(content)
+		if tokens == nil || tokens[1] != cfg.Nickname {
+			return nil
+		}
+		body = tokens[2]
+	}
+	body = strings.TrimSpace(body)
+
+	msgs, err := srv.NewMessagesFromBody(body, 0)
+	if err != nil {
+		errmsg := NewInputMessage()
+		errmsg.Adapter = "discord"
+		errmsg.ReplyTo = target
+		srv.ReplyError(errmsg, "lexer/expand: "+err.Error())
+		return nil
+	}
+
+	for _, msg := range msgs {
+		msg.Type = InputMsgTypeChannel
+		msg.Adapter = "discord"
+		msg.Nickname = message.Author.ID
+		msg.ReplyTo = target
+	}
+
+	return msgs
+}
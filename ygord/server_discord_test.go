@@ -0,0 +1,229 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDiscord is a stand-in for both the Discord gateway and REST API.  Once
+// the bot is identified, the gateway sends the queued events.
+type fakeDiscord struct {
+	URL      string
+	Events   []string
+	Identify chan string
+	Sent     chan string
+}
+
+func (fd *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/gateway" {
+		fd.serveGateway(w, r)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bot s3cr3t" {
+		http.Error(w, `{"code":0}`, 401)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/gateway/bot":
+		fmt.Fprintf(w, `{"url":"%s/gateway"}`,
+			strings.Replace(fd.URL, "http://", "ws://", 1))
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/channels/"):
+		body, _ := ioutil.ReadAll(r.Body)
+		fd.Sent <- r.URL.Path + " " + string(body)
+		fmt.Fprint(w, `{"id":"1"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (fd *fakeDiscord) serveGateway(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"op":10,"d":{"heartbeat_interval":41250}}`))
+
+	_, identify, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+	fd.Identify <- string(identify)
+
+	conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"op":0,"s":1,"t":"READY","d":{"user":{"id":"42","username":"whygore","bot":true}}}`))
+
+	for i, event := range fd.Events {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(
+			`{"op":0,"s":%d,"t":"MESSAGE_CREATE","d":%s}`, i+2, event)))
+	}
+
+	// Wait for the client to go away.
+	conn.ReadMessage()
+}
+
+func createTestServerAndDiscordAdapter(events ...string) (*Server, *DiscordAdapter, *fakeDiscord, func()) {
+	fd := &fakeDiscord{
+		Events:   events,
+		Identify: make(chan string, 1),
+		Sent:     make(chan string, 16),
+	}
+	ts := httptest.NewServer(fd)
+	fd.URL = ts.URL
+
+	srv := CreateTestServer()
+	srv.Config.DiscordBotToken = "s3cr3t"
+	srv.Config.DiscordAPIURL = ts.URL
+	srv.Config.Channels["#test"] = ChannelCfg{DiscordChannelID: "1001"}
+
+	adapter := &DiscordAdapter{}
+	srv.RegisterChatAdapter(adapter)
+
+	return srv, adapter, fd, func() {
+		adapter.Stop()
+		ts.Close()
+	}
+}
+
+func TestServerDiscordGateway(t *testing.T) {
+	srv, adapter, fd, done := createTestServerAndDiscordAdapter(
+		`{"channel_id":"1001","content":"<@42> play freshpots.mp3; nop","author":{"id":"7","username":"alice"}}`,
+		`{"channel_id":"1001","content":"not for the bot","author":{"id":"7","username":"alice"}}`,
+		`{"channel_id":"1001","content":"whygore: skip","author":{"id":"42","username":"whygore"}}`,
+		`{"channel_id":"2002","content":"<@42> reboot","author":{"id":"7","username":"alice"}}`,
+		`{"channel_id":"1001","content":"<@42> reboot","author":{"id":"9","username":"robot","bot":true}}`,
+		`{"channel_id":"1001","content":"<@!42>: image freshpots.gif","author":{"id":"8","username":"bob"}}`,
+	)
+	defer done()
+
+	err := adapter.Start(srv)
+	assert.Nil(t, err)
+
+	select {
+	case identify := <-fd.Identify:
+		payload := &discordPayload{}
+		json.Unmarshal([]byte(identify), payload)
+		assert.Equal(t, DiscordOpIdentify, payload.Op)
+		assert.Contains(t, string(payload.D), `"token":"s3cr3t"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot never identified")
+	}
+
+	var msgs []*InputMessage
+	for len(msgs) < 3 {
+		select {
+		case msg := <-srv.InputQueue:
+			msgs = append(msgs, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing messages")
+		}
+	}
+
+	assert.Equal(t, "play freshpots.mp3", msgs[0].Body)
+	assert.Equal(t, "7", msgs[0].Nickname)
+	assert.Equal(t, "nop", msgs[1].Body)
+	assert.Equal(t, "image freshpots.gif", msgs[2].Body)
+	assert.Equal(t, "8", msgs[2].Nickname)
+	for _, msg := range msgs {
+		assert.Equal(t, "discord", msg.Adapter)
+		assert.Equal(t, "#test", msg.ReplyTo)
+	}
+}
+
+func TestServerDiscordSend(t *testing.T) {
+	srv, adapter, fd, done := createTestServerAndDiscordAdapter()
+	defer done()
+
+	err := adapter.Start(srv)
+	assert.Nil(t, err)
+
+	msg := &InputMessage{Adapter: "discord", ReplyTo: "#test"}
+	srv.Reply(msg, "hello")
+	srv.ReplyAction(msg, "waves")
+	srv.DispatchOutputQueue()
+
+	for _, expected := range []string{
+		`/channels/1001/messages {"content":"hello"}`,
+		`/channels/1001/messages {"content":"*waves*"}`,
+	} {
+		select {
+		case sent := <-fd.Sent:
+			assert.Equal(t, expected, sent)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message never sent")
+		}
+	}
+}
+
+func TestServerDiscordNickname(t *testing.T) {
+	srv := CreateTestServer()
+	srv.Config.Channels["#test"] = ChannelCfg{DiscordChannelID: "1001"}
+
+	msgs := srv.NewMessagesFromDiscordMessage("42", &DiscordMessage{
+		ChannelID: "1001",
+		Content:   "whygore: play freshpots.mp3",
+		Author:    DiscordUser{ID: "7", Username: "alice"},
+	})
+
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "play freshpots.mp3", msgs[0].Body)
+	}
+}
+
+func TestServerDiscordIdentity(t *testing.T) {
+	srv := CreateTestServer()
+	srv.Config.Channels["#test"] = ChannelCfg{DiscordChannelID: "1001"}
+	srv.Roles.Set("discord", "7", RoleAdmin)
+	srv.Config.Ignore = []string{"8"}
+
+	// Another user taking the admin's username gets nothing.
+	msgs := srv.NewMessagesFromDiscordMessage("42", &DiscordMessage{
+		ChannelID: "1001",
+		Content:   "<@42> reboot",
+		Author:    DiscordUser{ID: "10", Username: "7"},
+	})
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "10", msgs[0].Nickname)
+		assert.Equal(t, RoleUser, srv.GetRole(msgs[0]))
+	}
+
+	msgs = srv.NewMessagesFromDiscordMessage("42", &DiscordMessage{
+		ChannelID: "1001",
+		Content:   "<@42> reboot",
+		Author:    DiscordUser{ID: "7", Username: "alice"},
+	})
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, RoleAdmin, srv.GetRole(msgs[0]))
+	}
+
+	msgs = srv.NewMessagesFromDiscordMessage("42", &DiscordMessage{
+		ChannelID: "1001",
+		Content:   "<@42> reboot",
+		Author:    DiscordUser{ID: "8", Username: "alice"},
+	})
+	assert.Len(t, msgs, 0)
+
+	msgs = srv.NewMessagesFromDiscordMessage("42", &DiscordMessage{
+		ChannelID: "1001",
+		Content:   "<@42> reboot",
+		Author:    DiscordUser{ID: "11", Username: "bob", Bot: true},
+	})
+	assert.Len(t, msgs, 0)
+}
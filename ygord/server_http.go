@@ -87,7 +87,14 @@ func HTTPServer(srv *Server, address string) {
 	http.Handle("/channel/register", &ChannelRegisterHandler{srv})
 	http.Handle("/channel/poll", &ChannelPollHandler{srv})
 	http.Handle("/client/list", &ClientListHandler{srv})
+	http.Handle("/command/list", &CommandListHandler{srv})
+	http.Handle("/command/stats", &CommandStatsHandler{srv})
+	http.Handle("/history", &HistoryHandler{srv})
+	http.Handle("/library/list", &LibraryListHandler{srv})
+	http.Handle("/library/upload", &LibraryUploadHandler{srv})
+	http.Handle(LibraryFilePath, &LibraryFileHandler{srv})
 	http.Handle("/mattermost", &MattermostHandler{srv})
+	http.Handle(MediaProxyPath, &MediaProxyHandler{srv})
 
 	err := http.ListenAndServe(address, nil)
 	if err != nil {
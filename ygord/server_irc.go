@@ -12,6 +12,7 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"strings"
 
@@ -19,8 +20,11 @@ import (
 	"github.com/truveris/ygor/ygord/lexer"
 )
 
-var (
-	conn *irc.Connection
+// mIRC control codes used to format the messages.
+const (
+	IRCBold     = "\x02"
+	IRCColorRed = "\x0304"
+	IRCReset    = "\x0f"
 )
 
 // NewMessagesFromBody creates a new ygor message from a plain string.
@@ -83,18 +87,22 @@ func (srv *Server) NewMessagesFromIRCEvent(e *irc.Event) []*InputMessage {
 
 	// Sent directly to the bot, fuck that.  Everything is public.
 	if target == cfg.Nickname {
-		log.Printf("Ignoring private message: %s", e)
+		log.Printf("Ignoring private message: %s", e.Raw)
 		return nil
 	}
 
 	msgs, err := srv.NewMessagesFromBody(body, 0)
 	if err != nil {
-		e.Connection.Privmsg(target, "lexer/expand error: "+err.Error())
+		errmsg := NewInputMessage()
+		errmsg.Adapter = "irc"
+		errmsg.ReplyTo = target
+		srv.ReplyError(errmsg, "lexer/expand: "+err.Error())
 		return nil
 	}
 
 	for _, msg := range msgs {
-		msg.Type = InputMsgTypeIRCChannel
+		msg.Type = InputMsgTypeChannel
+		msg.Adapter = "irc"
 		msg.Nickname = e.Nick
 		msg.ReplyTo = target
 	}
@@ -102,38 +110,44 @@ func (srv *Server) NewMessagesFromIRCEvent(e *irc.Event) []*InputMessage {
 	return msgs
 }
 
-// IRCMessageHandler loops through the command registry to find a matching
-// command and executes it.
+// IRCMessageHandler finds the command matching the message (see FindCommand)
+// and executes it.
 func (srv *Server) IRCMessageHandler(msg *InputMessage) {
-	for _, cmd := range srv.RegisteredCommands {
-		if !cmd.IRCMessageMatches(srv, msg) {
-			continue
-		}
-
-		if cmd.PrivMsgFunction == nil {
-			log.Printf("misconfigured command: %s (no PrivMsg)",
-				cmd.Name)
-			continue
-		}
+	cmd := srv.FindCommand(msg)
+	if cmd == nil {
+		srv.ReplyError(msg, "command not found: "+msg.Command)
+		return
+	}
 
-		log.Printf("cmd.PrivMsgFunction %s (rec:%d)", cmd.Name, msg.Depth)
-		cmd.PrivMsgFunction(srv, msg)
+	log.Printf("cmd.PrivMsgFunction %s (rec:%d)", cmd.Name, msg.Depth)
+	if cmd.Immediate {
+		cmd.Run(srv, msg)
 		return
 	}
 
-	// If we got that far, we didn't find a command.
-	srv.Reply(msg, "command not found: "+msg.Command)
+	srv.MediaQueue.After(msg.ReplyTo, func() {
+		cmd.Run(srv, msg)
+	})
 }
 
-//
-// All the functions below are used as wrappers to send data to the IRC server.
-// They are convenience functions for ygor to speak.
-//
+// IRCAdapter is the ChatAdapter connecting ygord to an IRC server.
+type IRCAdapter struct {
+	conn *irc.Connection
+}
+
+// Name returns the name of this adapter.
+func (adapter *IRCAdapter) Name() string {
+	return "irc"
+}
 
-// StartIRCClient connects the server to the IRC server.
-func (srv *Server) StartIRCClient() error {
+// Start connects the server to the IRC server, if configured.
+func (adapter *IRCAdapter) Start(srv *Server) error {
 	cfg := srv.Config
-	conn = irc.IRC(cfg.Nickname, cfg.Nickname)
+	if cfg.IRCServer == "" {
+		return nil
+	}
+
+	conn := irc.IRC(cfg.Nickname, cfg.Nickname)
 	//conn.VerboseCallbackHandler = true
 	//conn.Debug = true
 
@@ -155,5 +169,82 @@ func (srv *Server) StartIRCClient() error {
 		}
 	})
 
+	adapter.conn = conn
+
+	return nil
+}
+
+// Stop disconnects from the IRC server.
+func (adapter *IRCAdapter) Stop() error {
+	if adapter.conn != nil {
+		adapter.conn.Quit()
+	}
 	return nil
 }
+
+// Send delivers the message to its channel, as an ACTION if needed.
+func (adapter *IRCAdapter) Send(msg *OutputMessage) error {
+	if adapter.conn == nil {
+		return errors.New("not connected")
+	}
+
+	for _, line := range RenderIRC(msg) {
+		if line == "" {
+			continue
+		}
+		if msg.Type == OutputMsgTypeAction {
+			adapter.conn.Action(msg.Channel, line)
+		} else {
+			adapter.conn.Privmsg(msg.Channel, line)
+		}
+	}
+
+	return nil
+}
+
+// RenderIRC converts the message to IRC lines, using the mIRC control codes
+// for colors and bold text.
+func RenderIRC(msg *OutputMessage) []string {
+	var text string
+
+	switch msg.Type {
+	case OutputMsgTypeError:
+		text = IRCColorRed + IRCBold + "error:" + IRCReset + " " + msg.Body
+	case OutputMsgTypeList:
+		text = strings.Join(msg.Items, ", ")
+		if msg.Body != "" {
+			text = IRCBold + msg.Body + ":" + IRCReset + " " + text
+		}
+	case OutputMsgTypeMediaCard:
+		var lines []string
+		if msg.Body != "" {
+			lines = append(lines, msg.Body)
+		}
+		for _, attachment := range msg.Attachments {
+			lines = append(lines, renderIRCAttachment(attachment))
+		}
+		text = strings.Join(lines, "\n")
+	default:
+		text = msg.Body
+	}
+
+	return strings.Split(text, "\n")
+}
+
+// renderIRCAttachment renders an attachment on a single line: its title in
+// bold, its fields and its link.
+func renderIRCAttachment(attachment Attachment) string {
+	if attachment.Title == "" {
+		return attachment.Fallback
+	}
+
+	parts := []string{IRCBold + attachment.Title + IRCReset}
+	for _, field := range attachment.Fields {
+		parts = append(parts, "("+field.Title+": "+field.Value+")")
+	}
+	if attachment.TitleLink != "" {
+		parts = append(parts, attachment.TitleLink)
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -143,10 +143,11 @@ func TestServerIRCMaxRecursionAlias(t *testing.T) {
 
 	assert.Empty(t, msgs)
 
-	omsgs := srv.FlushIRCOutputQueue()
+	omsgs := srv.FlushOutputQueue()
 	if assert.Len(t, omsgs, 1) {
 		assert.Equal(t, "#test", omsgs[0].Channel)
-		assert.Equal(t, "lexer/expand error: max recursion reached", omsgs[0].Body)
+		assert.Equal(t, OutputMsgTypeError, omsgs[0].Type)
+		assert.Equal(t, "lexer/expand: max depth reached", omsgs[0].Body)
 	}
 }
 
@@ -164,7 +165,39 @@ func TestServerIRCMaxRecursionRandom(t *testing.T) {
 	if assert.Len(t, msgs, 1) {
 		assert.Equal(t, "#test", msgs[0].ReplyTo)
 		assert.Equal(t, "random coffee", msgs[0].Body)
-		assert.Equal(t, 1, msgs[0].Recursion)
+		assert.Equal(t, 1, msgs[0].Depth)
 	}
 
 }
+
+func TestServerIRCRender(t *testing.T) {
+	assert.Equal(t, []string{"hello", "world"}, RenderIRC(&OutputMessage{
+		Type: OutputMsgTypePrivMsg,
+		Body: "hello\nworld",
+	}))
+
+	assert.Equal(t, []string{"\x0304\x02error:\x0f unknown alias"},
+		RenderIRC(&OutputMessage{
+			Type: OutputMsgTypeError,
+			Body: "unknown alias",
+		}))
+
+	assert.Equal(t, []string{"\x02aliases:\x0f a, b"},
+		RenderIRC(&OutputMessage{
+			Type:  OutputMsgTypeList,
+			Body:  "aliases",
+			Items: []string{"a", "b"},
+		}))
+
+	assert.Equal(t, []string{"\x02freshpots\x0f (Source: YouTube) http://y/1"},
+		RenderIRC(&OutputMessage{
+			Type: OutputMsgTypeMediaCard,
+			Attachments: []Attachment{{
+				Title:     "freshpots",
+				TitleLink: "http://y/1",
+				Fields: []AttachmentField{
+					{Title: "Source", Value: "YouTube"},
+				},
+			}},
+		}))
+}
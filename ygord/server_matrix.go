@@ -0,0 +1,284 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+//
+// This server_matrix file contains the Matrix adapter, talking to a Matrix
+// homeserver using the client-server API.  Every configured channel with a
+// MatrixRoomID is bridged to that room.
+//
+// The message in this adapter is roughly converted as such:
+//
+//     Matrix homeserver -> /sync -> server_matrix -> ygor.InputMessage
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MatrixSyncTimeout is how long the homeserver is allowed to hold a
+	// sync request while waiting for events.
+	MatrixSyncTimeout = 30 * time.Second
+
+	// MatrixRetryDelay is how long to wait after a failed sync.
+	MatrixRetryDelay = 5 * time.Second
+)
+
+// MatrixEvent is a single room event as returned by the /sync endpoint.
+type MatrixEvent struct {
+	Type    string `json:"type"`
+	Sender  string `json:"sender"`
+	EventID string `json:"event_id"`
+	Content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"content"`
+}
+
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []MatrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+type matrixWhoAmIResponse struct {
+	UserID string `json:"user_id"`
+}
+
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+// MatrixAdapter is the ChatAdapter connecting ygord to a Matrix homeserver.
+type MatrixAdapter struct {
+	chatOutbox
+
+	srv    *Server
+	client *http.Client
+	userID string
+	since  string
+	txnID  int
+	quit   chan bool
+}
+
+// Name returns the name of this adapter.
+func (adapter *MatrixAdapter) Name() string {
+	return "matrix"
+}
+
+// Start connects to the homeserver and starts the sync loop, if Matrix is
+// configured.
+func (adapter *MatrixAdapter) Start(srv *Server) error {
+	cfg := srv.Config
+	if cfg.MatrixHomeserverURL == "" || cfg.MatrixAccessToken == "" {
+		return nil
+	}
+
+	err := adapter.connect(srv)
+	if err != nil {
+		return err
+	}
+
+	go adapter.syncLoop()
+
+	return nil
+}
+
+// connect identifies the bot user, joins all the configured rooms and fetches
+// the current position in the event stream.
+func (adapter *MatrixAdapter) connect(srv *Server) error {
+	cfg := srv.Config
+
+	adapter.srv = srv
+	adapter.client = &http.Client{Timeout: MatrixSyncTimeout * 2}
+	adapter.quit = make(chan bool)
+	adapter.startOutbox(srv, adapter.send)
+
+	whoami := &matrixWhoAmIResponse{}
+	err := adapter.request("GET", "/account/whoami", nil, whoami)
+	if err != nil {
+		return err
+	}
+	adapter.userID = whoami.UserID
+
+	for name, channel := range cfg.Channels {
+		if channel.MatrixRoomID == "" {
+			continue
+		}
+		err = adapter.request("POST", "/join/"+
+			url.PathEscape(channel.MatrixRoomID), struct{}{}, nil)
+		if err != nil {
+			return errors.New("failed to join " + name + ": " +
+				err.Error())
+		}
+	}
+
+	// The first sync only fetches the position in the event stream, we
+	// don't want to replay the commands sent while we were away.
+	return adapter.sync(0)
+}
+
+// Stop interrupts the sync loop.
+func (adapter *MatrixAdapter) Stop() error {
+	if adapter.quit != nil {
+		close(adapter.quit)
+	}
+	adapter.stopOutbox()
+	return nil
+}
+
+// Send queues the message for delivery in the background.
+func (adapter *MatrixAdapter) Send(msg *OutputMessage) error {
+	return adapter.push(msg)
+}
+
+// send delivers the message to the room bridged to its channel, actions are
+// sent as m.emote.
+func (adapter *MatrixAdapter) send(msg *OutputMessage) error {
+	roomID := adapter.srv.Config.Channels[msg.Channel].MatrixRoomID
+	if roomID == "" {
+		return errors.New("no Matrix room for " + msg.Channel)
+	}
+
+	content := matrixMessage{MsgType: "m.text", Body: msg.PlainText()}
+	if msg.Type == OutputMsgTypeAction {
+		content.MsgType = "m.emote"
+	}
+
+	adapter.txnID++
+	txnID := fmt.Sprintf("ygor.%d.%d", time.Now().Unix(), adapter.txnID)
+
+	return adapter.request("PUT", "/rooms/"+url.PathEscape(roomID)+
+		"/send/m.room.message/"+txnID, content, nil)
+}
+
+// request sends a single request to the homeserver client-server API.
+func (adapter *MatrixAdapter) request(method, path string, input, output interface{}) error {
+	cfg := adapter.srv.Config
+	endpoint := strings.TrimSuffix(cfg.MatrixHomeserverURL, "/") +
+		"/_matrix/client/r0" + path
+	return apiRequest(adapter.client, method, endpoint,
+		"Bearer "+cfg.MatrixAccessToken, input, output)
+}
+
+// sync fetches all the events since the last sync, waiting for up to
+// 'timeout' for new events to come in.  The first sync only records the
+// position in the stream.
+func (adapter *MatrixAdapter) sync(timeout time.Duration) error {
+	params := url.Values{}
+	params.Set("timeout", fmt.Sprintf("%d", timeout/time.Millisecond))
+	if adapter.since != "" {
+		params.Set("since", adapter.since)
+	}
+
+	response := &matrixSyncResponse{}
+	err := adapter.request("GET", "/sync?"+params.Encode(), nil, response)
+	if err != nil {
+		return err
+	}
+
+	first := adapter.since == ""
+	adapter.since = response.NextBatch
+	if first {
+		return nil
+	}
+
+	for roomID, room := range response.Rooms.Join {
+		for _, event := range room.Timeline.Events {
+			if event.Sender == adapter.userID {
+				continue
+			}
+			msgs := adapter.srv.NewMessagesFromMatrixEvent(roomID, &event)
+			for _, msg := range msgs {
+				adapter.srv.InputQueue <- msg
+			}
+		}
+	}
+
+	return nil
+}
+
+// syncLoop syncs forever until the adapter is stopped.
+func (adapter *MatrixAdapter) syncLoop() {
+	for {
+		select {
+		case <-adapter.quit:
+			return
+		default:
+		}
+
+		err := adapter.sync(MatrixSyncTimeout)
+		if err != nil {
+			adapter.srv.Warningf("matrix: sync error: %s",
+				err.Error())
+			select {
+			case <-adapter.quit:
+				return
+			case <-time.After(MatrixRetryDelay):
+			}
+		}
+	}
+}
+
+// NewMessagesFromMatrixEvent creates a new array of messages based on a room
+// event.
+func (srv *Server) NewMessagesFromMatrixEvent(roomID string, event *MatrixEvent) []*InputMessage {
+	cfg := srv.Config
+
+	if event.Type != "m.room.message" || event.Content.MsgType != "m.text" {
+		return nil
+	}
+
+	// Check if we should ignore this message.
+	for _, ignore := range cfg.Ignore {
+		if ignore == event.Sender {
+			log.Printf("Ignoring %s", ignore)
+			return nil
+		}
+	}
+
+	target := cfg.GetChannelByMatrixRoomID(roomID)
+	if target == "" {
+		return nil
+	}
+
+	// Ignore the message if not prefixed with our nickname.  If it is,
+	// remove this prefix from the body of the message.
+	tokens := reAddressed.FindStringSubmatch(event.Content.Body)
+	if tokens == nil || tokens[1] != cfg.Nickname {
+		return nil
+	}
+
+	body := strings.TrimSpace(tokens[2])
+
+	msgs, err := srv.NewMessagesFromBody(body, 0)
+	if err != nil {
+		errmsg := NewInputMessage()
+		errmsg.Adapter = "matrix"
+		errmsg.ReplyTo = target
+		srv.ReplyError(errmsg, "lexer/expand: "+err.Error())
+		return nil
+	}
+
+	for _, msg := range msgs {
+		msg.Type = InputMsgTypeChannel
+		msg.Adapter = "matrix"
+		msg.Nickname = event.Sender
+		msg.ReplyTo = target
+	}
+
+	return msgs
+}
@@ -0,0 +1,165 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHomeserver is a stand-in for a Matrix homeserver.  The first sync
+// returns an old message which should be ignored, the following syncs return
+// the queued events.
+type fakeHomeserver struct {
+	Events []string
+	Joined []string
+	Sent   []string
+	syncs  int
+}
+
+func (hs *fakeHomeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+		http.Error(w, `{"errcode":"M_UNKNOWN_TOKEN"}`, 401)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/_matrix/client/r0")
+
+	switch {
+	case path == "/account/whoami":
+		fmt.Fprint(w, `{"user_id":"@whygore:example.org"}`)
+	case strings.HasPrefix(path, "/join/"):
+		hs.Joined = append(hs.Joined, strings.TrimPrefix(path, "/join/"))
+		fmt.Fprint(w, `{}`)
+	case path == "/sync":
+		events := `{"type":"m.room.message","sender":"@old:example.org","content":{"msgtype":"m.text","body":"whygore: old"}}`
+		if hs.syncs > 0 {
+			events = strings.Join(hs.Events, ",")
+			hs.Events = nil
+		}
+		hs.syncs++
+		fmt.Fprintf(w, `{"next_batch":"s%d","rooms":{"join":{"!room:example.org":{"timeline":{"events":[%s]}}}}}`,
+			hs.syncs, events)
+	case r.Method == "PUT" && strings.HasPrefix(path, "/rooms/"):
+		body, _ := ioutil.ReadAll(r.Body)
+		hs.Sent = append(hs.Sent, path+" "+string(body))
+		fmt.Fprint(w, `{"event_id":"$1"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func createTestServerAndMatrixAdapter() (*Server, *MatrixAdapter, *fakeHomeserver, func()) {
+	hs := &fakeHomeserver{}
+	ts := httptest.NewServer(hs)
+
+	srv := CreateTestServer()
+	srv.Config.MatrixHomeserverURL = ts.URL
+	srv.Config.MatrixAccessToken = "s3cr3t"
+	srv.Config.Channels["#test"] = ChannelCfg{
+		MatrixRoomID: "!room:example.org",
+	}
+
+	adapter := &MatrixAdapter{}
+	srv.RegisterChatAdapter(adapter)
+
+	return srv, adapter, hs, ts.Close
+}
+
+func TestServerMatrixSync(t *testing.T) {
+	srv, adapter, hs, done := createTestServerAndMatrixAdapter()
+	defer done()
+
+	err := adapter.connect(srv)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"%21room:example.org"}, hs.Joined)
+	assert.Empty(t, srv.FlushInputQueue())
+
+	hs.Events = []string{
+		`{"type":"m.room.message","sender":"@alice:example.org","content":{"msgtype":"m.text","body":"whygore: play freshpots.mp3; nop"}}`,
+		`{"type":"m.room.message","sender":"@alice:example.org","content":{"msgtype":"m.text","body":"not for the bot"}}`,
+		`{"type":"m.room.message","sender":"@whygore:example.org","content":{"msgtype":"m.text","body":"whygore: skip"}}`,
+		`{"type":"m.room.member","sender":"@bob:example.org","content":{}}`,
+	}
+	err = adapter.sync(0)
+	assert.Nil(t, err)
+
+	msgs := srv.FlushInputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "play freshpots.mp3", msgs[0].Body)
+		assert.Equal(t, "nop", msgs[1].Body)
+		for _, msg := range msgs {
+			assert.Equal(t, "matrix", msg.Adapter)
+			assert.Equal(t, "@alice:example.org", msg.Nickname)
+			assert.Equal(t, "#test", msg.ReplyTo)
+		}
+	}
+}
+
+func TestServerMatrixSend(t *testing.T) {
+	srv, adapter, hs, done := createTestServerAndMatrixAdapter()
+	defer done()
+
+	err := adapter.connect(srv)
+	assert.Nil(t, err)
+
+	msg := &InputMessage{Adapter: "matrix", ReplyTo: "#test"}
+	srv.Reply(msg, "hello")
+	srv.ReplyAction(msg, "waves")
+	srv.DispatchOutputQueue()
+
+	if assert.Len(t, hs.Sent, 2) {
+		var content matrixMessage
+
+		tokens := strings.SplitN(hs.Sent[0], " ", 2)
+		assert.True(t, strings.HasPrefix(tokens[0],
+			"/rooms/%21room:example.org/send/m.room.message/"))
+		json.Unmarshal([]byte(tokens[1]), &content)
+		assert.Equal(t, matrixMessage{"m.text", "hello"}, content)
+
+		tokens = strings.SplitN(hs.Sent[1], " ", 2)
+		json.Unmarshal([]byte(tokens[1]), &content)
+		assert.Equal(t, matrixMessage{"m.emote", "waves"}, content)
+	}
+}
+
+func TestServerMatrixRoomIDEscaping(t *testing.T) {
+	srv, adapter, hs, done := createTestServerAndMatrixAdapter()
+	defer done()
+
+	srv.Config.Channels["#test"] = ChannelCfg{
+		MatrixRoomID: "!a b/c?d#e+f:example.org",
+	}
+
+	err := adapter.connect(srv)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"%21a%20b%2Fc%3Fd%23e+f:example.org"}, hs.Joined)
+
+	srv.Reply(&InputMessage{Adapter: "matrix", ReplyTo: "#test"}, "hello")
+	srv.DispatchOutputQueue()
+
+	if assert.Len(t, hs.Sent, 1) {
+		assert.True(t, strings.HasPrefix(hs.Sent[0],
+			"/rooms/%21a%20b%2Fc%3Fd%23e+f:example.org/send/m.room.message/"),
+			hs.Sent[0])
+	}
+}
+
+func TestServerMatrixUnknownRoom(t *testing.T) {
+	srv := CreateTestServer()
+
+	event := &MatrixEvent{Type: "m.room.message", Sender: "@alice:example.org"}
+	event.Content.MsgType = "m.text"
+	event.Content.Body = "whygore: play freshpots.mp3"
+
+	assert.Empty(t, srv.NewMessagesFromMatrixEvent("!other:example.org", event))
+}
@@ -2,76 +2,195 @@
 // Use of this source code is governed by the ISC license in the LICENSE file.
 //
 // This server_mattermost file contains the mattermost server code, responsible
-// for converting mattermost http Request into ygor messages.
+// for converting mattermost http Request into ygor messages.  Requests come
+// either from an outgoing webhook (e.g. "ygor: play ...") or from a slash
+// command (e.g. "/ygor play ...").
 //
 // The message in this adapter is roughly converted as such:
 //
 //     Mattermost Server -> ygor webhook -> go http.Request -> ygor.Message
 //
+// Multiple integrations can be configured (e.g. one per team), each with its
+// own tokens.  Replies are sent through the integration the request came from:
+// to the response URL provided by slash commands, otherwise either through
+// the incoming webhook or, in bot-account mode, through the REST API.
+//
 
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
-	"io/ioutil"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// MattermostAttachmentField is a single field of a MattermostAttachment.
+type MattermostAttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// MattermostAttachment is the Mattermost representation of an Attachment, in
+// the same format as the Slack message attachments.
+type MattermostAttachment struct {
+	Fallback  string                      `json:"fallback"`
+	Title     string                      `json:"title,omitempty"`
+	TitleLink string                      `json:"title_link,omitempty"`
+	Text      string                      `json:"text,omitempty"`
+	ThumbURL  string                      `json:"thumb_url,omitempty"`
+	ImageURL  string                      `json:"image_url,omitempty"`
+	Fields    []MattermostAttachmentField `json:"fields,omitempty"`
+}
+
+// MattermostResponse is the JSON payload sent to the incoming webhook.
 type MattermostResponse struct {
-	Text     string `json:"text"`
-	Channel  string `json:"channel"`
-	IconURL  string `json:"icon_url"`
-	Username string `json:"username"`
+	Text        string                 `json:"text"`
+	Channel     string                 `json:"channel"`
+	IconURL     string                 `json:"icon_url"`
+	Username    string                 `json:"username"`
+	Attachments []MattermostAttachment `json:"attachments,omitempty"`
+}
+
+// MattermostCommandResponse is the JSON payload sent to the response URL of a
+// slash command.
+type MattermostCommandResponse struct {
+	ResponseType string                 `json:"response_type"`
+	Text         string                 `json:"text"`
+	IconURL      string                 `json:"icon_url,omitempty"`
+	Username     string                 `json:"username,omitempty"`
+	Attachments  []MattermostAttachment `json:"attachments,omitempty"`
+}
+
+// MattermostPostProps holds the properties of a post, only the attachments
+// are used by ygor.
+type MattermostPostProps struct {
+	Attachments []MattermostAttachment `json:"attachments"`
+}
+
+// MattermostPost is a post created or fetched through the REST API.
+type MattermostPost struct {
+	ID        string               `json:"id,omitempty"`
+	ChannelID string               `json:"channel_id"`
+	RootID    string               `json:"root_id,omitempty"`
+	Message   string               `json:"message"`
+	Props     *MattermostPostProps `json:"props,omitempty"`
 }
 
-func (srv *Server) NewMattermostResponse(channel, text string) *MattermostResponse {
+// MattermostContext is attached to the messages received from Mattermost
+// (see InputMessage.AdapterData) and defines where their responses go.
+type MattermostContext struct {
+	Integration *MattermostCfg
+	ChannelName string
+	ChannelID   string
+	PostID      string
+	ResponseURL string
+}
+
+// NewMattermostResponse creates a webhook payload with the username and icon
+// of the given integration.
+func NewMattermostResponse(integration *MattermostCfg, channel, text string) *MattermostResponse {
 	return &MattermostResponse{
 		Text:     text,
 		Channel:  channel,
-		IconURL:  srv.Config.MattermostIconURL,
-		Username: srv.Config.MattermostUsername,
+		IconURL:  integration.IconURL,
+		Username: integration.Username,
 	}
 }
 
-func (srv *Server) SendToMattermost(response *MattermostResponse) {
-	client := &http.Client{}
-	buf, err := json.Marshal(response)
-	if err != nil {
-		log.Printf("SendToMattermost: invalid JSON: %s", err.Error())
-		return
+// SendToMattermost posts the response to the incoming webhook of the given
+// integration.
+func SendToMattermost(integration *MattermostCfg, response *MattermostResponse) error {
+	if integration.Webhook == "" {
+		return errors.New(integration.Name + ": no webhook configured")
 	}
-	resp, err := client.Post(srv.Config.MattermostWebhook,
-		"application/json", bytes.NewReader(buf))
-	if err != nil {
-		log.Printf("SendToMattermost: http error: %s", err.Error())
-		return
+	client := &http.Client{Timeout: 30 * time.Second}
+	return apiRequest(client, "POST", integration.Webhook, "", response,
+		nil)
+}
+
+// NewMattermostAttachments converts the attachments of an OutputMessage to
+// their Mattermost representation.
+func NewMattermostAttachments(attachments []Attachment) []MattermostAttachment {
+	var converted []MattermostAttachment
+
+	for _, attachment := range attachments {
+		var fields []MattermostAttachmentField
+		for _, field := range attachment.Fields {
+			fields = append(fields, MattermostAttachmentField{
+				Title: field.Title,
+				Value: field.Value,
+				Short: field.Short,
+			})
+		}
+
+		converted = append(converted, MattermostAttachment{
+			Fallback:  attachment.Fallback,
+			Title:     attachment.Title,
+			TitleLink: attachment.TitleLink,
+			Text:      attachment.Text,
+			ThumbURL:  attachment.ThumbURL,
+			ImageURL:  attachment.ImageURL,
+			Fields:    fields,
+		})
+	}
+
+	return converted
+}
+
+// isMattermostIgnored checks if we should ignore this user.
+func (srv *Server) isMattermostIgnored(r *http.Request) bool {
+	for _, ignore := range srv.Config.Ignore {
+		if ignore == r.Form.Get("user_name") {
+			log.Printf("Ignoring %s", ignore)
+			return true
+		}
 	}
-	defer resp.Body.Close()
 
-	// Read entire body to completion to re-use keep-alive connections.
-	io.Copy(ioutil.Discard, resp.Body)
+	return false
+}
+
+// newMessagesFromMattermostBody creates the messages from the body of a
+// request, all attached to the same context.  The Mattermost channel is
+// converted to a ygor channel if the integration maps it.
+func (srv *Server) newMessagesFromMattermostBody(r *http.Request, body string, ctx *MattermostContext) []*InputMessage {
+	ctx.ChannelName = r.Form.Get("channel_name")
+
+	target := ctx.ChannelName
+	if channel, ok := ctx.Integration.Channels[target]; ok {
+		target = channel
+	}
+
+	msgs, err := srv.NewMessagesFromBody(body, 0)
+	if err != nil {
+		errmsg := NewInputMessage()
+		errmsg.Adapter = "mattermost"
+		errmsg.AdapterData = ctx
+		errmsg.ReplyTo = target
+		srv.ReplyError(errmsg, "lexer/expand: "+err.Error())
+		return nil
+	}
 
-	if resp.StatusCode != 200 {
-		log.Printf("SendToMattermost: unexpected status code: %d",
-			resp.StatusCode)
+	for _, msg := range msgs {
+		msg.Type = InputMsgTypeChannel
+		msg.Adapter = "mattermost"
+		msg.AdapterData = ctx
+		msg.Nickname = r.Form.Get("user_name")
+		msg.ReplyTo = target
 	}
+
+	return msgs
 }
 
-// NewMessagesFromMattermostRequest creates a new array of messages based on a
-// PRIVMSG event.
-func (srv *Server) NewMessagesFromMattermostRequest(r *http.Request) []*InputMessage {
+// NewMessagesFromMattermostRequest creates a new array of messages based on
+// an outgoing webhook request of the given integration.
+func (srv *Server) NewMessagesFromMattermostRequest(integration *MattermostCfg, r *http.Request) []*InputMessage {
 	cfg := srv.Config
 
-	// Check if we should ignore this message.
-	for _, ignore := range cfg.Ignore {
-		if ignore == r.Form.Get("userName") {
-			log.Printf("Ignoring %s", ignore)
-			return nil
-		}
+	if srv.isMattermostIgnored(r) {
+		return nil
 	}
 
 	// Ignore the message if not prefixed with our nickname.  If it is,
@@ -82,20 +201,156 @@ func (srv *Server) NewMessagesFromMattermostRequest(r *http.Request) []*InputMes
 	}
 
 	body := strings.TrimSpace(tokens[2])
-	target := r.Form.Get("channel_name")
 
-	msgs, err := srv.NewMessagesFromBody(body, 0)
-	if err != nil {
-		srv.SendToMattermost(srv.NewMattermostResponse(target,
-			"lexer/expand error: "+err.Error()))
+	return srv.newMessagesFromMattermostBody(r, body, &MattermostContext{
+		Integration: integration,
+		ChannelID:   r.Form.Get("channel_id"),
+		PostID:      r.Form.Get("post_id"),
+	})
+}
+
+// NewMessagesFromMattermostSlashCommand creates a new array of messages based
+// on a slash command request of the given integration.  The whole text is the
+// command, there is no need to address ygor.
+func (srv *Server) NewMessagesFromMattermostSlashCommand(integration *MattermostCfg, r *http.Request) []*InputMessage {
+	if srv.isMattermostIgnored(r) {
 		return nil
 	}
 
-	for _, msg := range msgs {
-		msg.Type = InputMsgTypeMattermost
-		msg.Nickname = r.Form.Get("user_name")
-		msg.ReplyTo = target
+	body := strings.TrimSpace(r.Form.Get("text"))
+	if body == "" {
+		return nil
 	}
 
-	return msgs
+	return srv.newMessagesFromMattermostBody(r, body, &MattermostContext{
+		Integration: integration,
+		ChannelID:   r.Form.Get("channel_id"),
+		ResponseURL: r.Form.Get("response_url"),
+	})
+}
+
+// MattermostAdapter is the ChatAdapter replying to Mattermost.  Messages are
+// received by the MattermostHandler on the HTTP server.
+type MattermostAdapter struct {
+	chatOutbox
+
+	srv    *Server
+	client *http.Client
+}
+
+// Name returns the name of this adapter.
+func (adapter *MattermostAdapter) Name() string {
+	return "mattermost"
+}
+
+// Start keeps a reference to the server, there is no connection to maintain.
+func (adapter *MattermostAdapter) Start(srv *Server) error {
+	adapter.srv = srv
+	adapter.client = &http.Client{Timeout: 30 * time.Second}
+	adapter.startOutbox(srv, adapter.send)
+	return nil
+}
+
+// Stop stops sending messages, there is no connection to close.
+func (adapter *MattermostAdapter) Stop() error {
+	adapter.stopOutbox()
+	return nil
+}
+
+// request sends a single request to the REST API of the given integration.
+func (adapter *MattermostAdapter) request(integration *MattermostCfg, method, path string, input, output interface{}) error {
+	endpoint := strings.TrimSuffix(integration.URL, "/") + "/api/v4" + path
+	return apiRequest(adapter.client, method, endpoint,
+		"Bearer "+integration.AccessToken, input, output)
+}
+
+// createPost creates the post in the channel of the original request.  Unless
+// the post already belongs to a thread, it is added to the thread of the
+// original request if configured.
+func (adapter *MattermostAdapter) createPost(ctx *MattermostContext, post MattermostPost) error {
+	integration := ctx.Integration
+	post.ChannelID = ctx.ChannelID
+
+	if post.RootID == "" && integration.ReplyInThread && ctx.PostID != "" {
+		// Replies must refer to the first post of the thread.
+		original := &MattermostPost{}
+		err := adapter.request(integration, "GET", "/posts/"+ctx.PostID,
+			nil, original)
+		if err != nil {
+			return errors.New("failed to fetch original post: " +
+				err.Error())
+		}
+		post.RootID = original.RootID
+		if post.RootID == "" {
+			post.RootID = original.ID
+		}
+	}
+
+	return adapter.request(integration, "POST", "/posts", post, nil)
+}
+
+// Send queues the message for delivery in the background.
+func (adapter *MattermostAdapter) Send(msg *OutputMessage) error {
+	return adapter.push(msg)
+}
+
+// send delivers the message to Mattermost through the integration it came
+// from, rendered in markdown.  Threads are only supported in
+// bot-account mode.  Messages without context (e.g.
+// generated by ygor itself) go through the first integration.
+func (adapter *MattermostAdapter) send(msg *OutputMessage) error {
+	cfg := adapter.srv.Config
+
+	// Attachments are rendered natively, their fallback text is not
+	// needed.
+	text := msg.Markdown()
+	if msg.Type == OutputMsgTypeMediaCard {
+		text = msg.Body
+	}
+	attachments := NewMattermostAttachments(msg.Attachments)
+
+	// Messages not replying to a request (e.g. admin log) go to the
+	// Mattermost channel mapped to their ygor channel.
+	ctx, _ := msg.AdapterData.(*MattermostContext)
+	if ctx == nil {
+		integration, name := cfg.GetMattermostChannel(msg.Channel)
+		if integration == nil {
+			logf := adapter.srv.Warningf
+			if adapter.srv.IsAdminChannel(msg) {
+				logf = log.Printf
+			}
+			logf("mattermost: no channel mapped to %s, message "+
+				"dropped", msg.Channel)
+			return nil
+		}
+		ctx = &MattermostContext{
+			Integration: integration,
+			ChannelName: name,
+		}
+	}
+	integration := ctx.Integration
+
+	if ctx.ResponseURL != "" {
+		return apiRequest(adapter.client, "POST", ctx.ResponseURL, "",
+			MattermostCommandResponse{
+				ResponseType: integration.SlashResponseType,
+				Text:         text,
+				IconURL:      integration.IconURL,
+				Username:     integration.Username,
+				Attachments:  attachments,
+			}, nil)
+	}
+
+	if integration.URL != "" && integration.AccessToken != "" &&
+		ctx.ChannelID != "" {
+		post := MattermostPost{Message: text, RootID: msg.ThreadRootID}
+		if attachments != nil {
+			post.Props = &MattermostPostProps{Attachments: attachments}
+		}
+		return adapter.createPost(ctx, post)
+	}
+
+	response := NewMattermostResponse(integration, ctx.ChannelName, text)
+	response.Attachments = attachments
+	return SendToMattermost(integration, response)
 }
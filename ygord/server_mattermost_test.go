@@ -0,0 +1,290 @@
+// Copyright 2016, Truveris Inc. All Rights Reserved.
+// Use of this source code is governed by the ISC license in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMattermost is a stand-in for the Mattermost server, recording every
+// request made to its webhook, response URLs and REST API.
+type fakeMattermost struct {
+	Requests []string
+}
+
+func (fm *fakeMattermost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	if strings.HasPrefix(r.URL.Path, "/api/v4/") &&
+		r.Header.Get("Authorization") != "Bearer s3cr3t" {
+		http.Error(w, "{}", 401)
+		return
+	}
+
+	if r.Method == "GET" && r.URL.Path == "/api/v4/posts/reply" {
+		w.Write([]byte(`{"id":"reply","channel_id":"c1","root_id":"root"}`))
+		return
+	}
+
+	fm.Requests = append(fm.Requests, r.Method+" "+r.URL.Path+" "+
+		strings.TrimSpace(string(body)))
+	w.Write([]byte("{}"))
+}
+
+func createTestServerAndMattermostAdapter() (*Server, *fakeMattermost, func()) {
+	fm := &fakeMattermost{}
+	ts := httptest.NewServer(fm)
+
+	srv := CreateTestServer()
+	srv.Config.MattermostIntegrations = []MattermostCfg{
+		{
+			Name:              "engineering",
+			Token:             "webhook-token",
+			SlashCommandToken: "slash-token",
+			SlashResponseType: "ephemeral",
+			Webhook:           ts.URL + "/hooks/abc",
+			Username:          "ygor",
+			URL:               ts.URL,
+		},
+		{
+			Name:     "sales",
+			Token:    "sales-token",
+			Webhook:  ts.URL + "/hooks/def",
+			Username: "ygor-sales",
+			Channels: map[string]string{"town-square": "#test"},
+		},
+	}
+
+	adapter := &MattermostAdapter{}
+	srv.RegisterChatAdapter(adapter)
+	adapter.Start(srv)
+
+	return srv, fm, ts.Close
+}
+
+func postMattermostForm(srv *Server, form url.Values) int {
+	r, _ := http.NewRequest("POST", "/mattermost",
+		strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	(&MattermostHandler{srv}).ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestServerMattermostWebhook(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	code := postMattermostForm(srv, url.Values{
+		"token":        {"webhook-token"},
+		"channel_id":   {"c1"},
+		"channel_name": {"#test"},
+		"post_id":      {"p1"},
+		"user_name":    {"alice"},
+		"text":         {"whygore: wat"},
+	})
+	assert.Equal(t, 200, code)
+
+	msgs := srv.FlushInputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "wat", msgs[0].Body)
+		assert.Equal(t, "alice", msgs[0].Nickname)
+		srv.IRCMessageHandler(msgs[0])
+	}
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, []string{
+		`POST /hooks/abc {"text":"**error:** command not found: wat","channel":"#test","icon_url":"","username":"ygor"}`,
+	}, fm.Requests)
+}
+
+func TestServerMattermostBadToken(t *testing.T) {
+	srv, _, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	code := postMattermostForm(srv, url.Values{
+		"token":        {"webhook-token"},
+		"command":      {"/ygor"},
+		"channel_name": {"#test"},
+		"text":         {"wat"},
+	})
+	assert.Equal(t, 500, code)
+	assert.Empty(t, srv.FlushInputQueue())
+}
+
+func TestServerMattermostSlashCommand(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	code := postMattermostForm(srv, url.Values{
+		"token":        {"slash-token"},
+		"command":      {"/ygor"},
+		"channel_id":   {"c1"},
+		"channel_name": {"#test"},
+		"user_name":    {"alice"},
+		"text":         {"wat; nop"},
+		"response_url": {srv.Config.MattermostIntegrations[0].URL +
+			"/hooks/commands/123"},
+	})
+	assert.Equal(t, 200, code)
+
+	msgs := srv.FlushInputQueue()
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "wat", msgs[0].Body)
+		assert.Equal(t, "nop", msgs[1].Body)
+		srv.ReplyAction(msgs[0], "is confused")
+	}
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, []string{
+		`POST /hooks/commands/123 {"response_type":"ephemeral","text":"*is confused*","username":"ygor"}`,
+	}, fm.Requests)
+}
+
+func TestServerMattermostBotThread(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	integration := &srv.Config.MattermostIntegrations[0]
+	integration.AccessToken = "s3cr3t"
+	integration.ReplyInThread = true
+
+	msg := &InputMessage{
+		Adapter: "mattermost",
+		AdapterData: &MattermostContext{
+			Integration: integration,
+			ChannelID:   "c1",
+			PostID:      "reply",
+		},
+		ReplyTo: "#test",
+	}
+	srv.Reply(msg, "ok...")
+
+	msg.AdapterData = &MattermostContext{
+		Integration: integration,
+		ChannelID:   "c1",
+	}
+	srv.Reply(msg, "ok!")
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, []string{
+		`POST /api/v4/posts {"channel_id":"c1","root_id":"root","message":"ok..."}`,
+		`POST /api/v4/posts {"channel_id":"c1","message":"ok!"}`,
+	}, fm.Requests)
+}
+
+func TestServerMattermostMultipleIntegrations(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	code := postMattermostForm(srv, url.Values{
+		"token":        {"sales-token"},
+		"channel_name": {"town-square"},
+		"user_name":    {"bob"},
+		"text":         {"whygore: wat"},
+	})
+	assert.Equal(t, 200, code)
+
+	msgs := srv.FlushInputQueue()
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "#test", msgs[0].ReplyTo)
+		srv.IRCMessageHandler(msgs[0])
+	}
+
+	// Messages without context go to the channel mapped to theirs.
+	srv.Reply(&InputMessage{Adapter: "mattermost", ReplyTo: "#test"},
+		"hello")
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, []string{
+		`POST /hooks/def {"text":"**error:** command not found: wat","channel":"town-square","icon_url":"","username":"ygor-sales"}`,
+		`POST /hooks/def {"text":"hello","channel":"town-square","icon_url":"","username":"ygor-sales"}`,
+	}, fm.Requests)
+}
+
+func TestServerMattermostUnmappedChannel(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	srv.Reply(&InputMessage{Adapter: "mattermost", ReplyTo: "#lobby"},
+		"hello")
+	srv.DispatchOutputQueue()
+
+	assert.Empty(t, fm.Requests)
+}
+
+func TestServerMattermostAttachments(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	integration := &srv.Config.MattermostIntegrations[0]
+	card := Attachment{
+		Fallback: "now playing: freshpots.mp3",
+		Title:    "freshpots.mp3",
+		Fields:   []AttachmentField{{"Source", "example.com", true}},
+	}
+
+	msg := &InputMessage{
+		Adapter: "mattermost",
+		AdapterData: &MattermostContext{
+			Integration: integration,
+			ChannelName: "#test",
+		},
+		ReplyTo: "#test",
+	}
+	srv.ReplyMediaCard(msg, "", card)
+
+	// In bot-account mode, the thread of the message has precedence over
+	// the thread of the original request.
+	integration.AccessToken = "s3cr3t"
+	integration.ReplyInThread = true
+	msg.AdapterData = &MattermostContext{
+		Integration: integration,
+		ChannelID:   "c1",
+		PostID:      "reply",
+	}
+	response := msg.NewResponse("ok")
+	response.Type = OutputMsgTypeMediaCard
+	response.ThreadRootID = "other"
+	response.Attachments = []Attachment{card}
+	srv.OutputQueue <- response
+	srv.DispatchOutputQueue()
+
+	assert.Equal(t, []string{
+		`POST /hooks/abc {"text":"","channel":"#test","icon_url":"","username":"ygor","attachments":[{"fallback":"now playing: freshpots.mp3","title":"freshpots.mp3","fields":[{"title":"Source","value":"example.com","short":true}]}]}`,
+		`POST /api/v4/posts {"channel_id":"c1","root_id":"other","message":"ok","props":{"attachments":[{"fallback":"now playing: freshpots.mp3","title":"freshpots.mp3","fields":[{"title":"Source","value":"example.com","short":true}]}]}}`,
+	}, fm.Requests)
+}
+
+func TestServerMattermostAdminLog(t *testing.T) {
+	srv, fm, done := createTestServerAndMattermostAdapter()
+	defer done()
+
+	srv.Config.AdminAdapter = "mattermost"
+	srv.Config.AdminChannel = "#test"
+
+	srv.Warningf("client %s is dead", "dummy")
+	srv.DispatchOutputQueue()
+
+	// The admin channel is mapped to "town-square" by the second
+	// integration, the notice is not sent to a "#test" channel.
+	assert.Equal(t, []string{
+		`POST /hooks/def {"text":"warning: client dummy is dead","channel":"town-square","icon_url":"","username":"ygor-sales"}`,
+	}, fm.Requests)
+
+	// An unmapped admin channel drops the notices without looping.
+	fm.Requests = nil
+	srv.Config.AdminChannel = "#admin"
+	srv.Warningf("client %s is dead", "dummy")
+	srv.DispatchOutputQueue()
+	assert.Empty(t, srv.FlushOutputQueue())
+	assert.Empty(t, fm.Requests)
+}
@@ -1,4 +1,4 @@
-// Copyright 2014-2015, Truveris Inc. All Rights Reserved.
+// Copyright 2014-2016, Truveris Inc. All Rights Reserved.
 // Use of this source code is governed by the ISC license in the LICENSE file.
 
 package main
@@ -11,7 +11,42 @@ var (
 	fakeNow = time.Date(1982, 10, 20, 19, 0, 0, 0, time.UTC)
 )
 
-// CreateTestServer creates an ygor server for testing.
+// TestAdapter is a ChatAdapter recording all the messages it is asked to send
+// instead of talking to an actual chat system.
+type TestAdapter struct {
+	Sent []*OutputMessage
+}
+
+// Name returns the name of this adapter.
+func (adapter *TestAdapter) Name() string {
+	return "test"
+}
+
+// Start does nothing.
+func (adapter *TestAdapter) Start(srv *Server) error {
+	return nil
+}
+
+// Stop does nothing.
+func (adapter *TestAdapter) Stop() error {
+	return nil
+}
+
+// Send records the message.
+func (adapter *TestAdapter) Send(msg *OutputMessage) error {
+	adapter.Sent = append(adapter.Sent, msg)
+	return nil
+}
+
+// Flush returns all the recorded messages and forgets about them.
+func (adapter *TestAdapter) Flush() []*OutputMessage {
+	msgs := adapter.Sent
+	adapter.Sent = nil
+	return msgs
+}
+
+// CreateTestServer creates an ygor server for testing.  It comes with a
+// TestAdapter registered under the name "test".
 func CreateTestServer() *Server {
 	srv := CreateServer(&Config{
 		Nickname:      "whygore",
@@ -21,5 +56,35 @@ func CreateTestServer() *Server {
 		},
 	})
 
+	srv.RegisterChatAdapter(&TestAdapter{})
+
 	return srv
 }
+
+// GetTestAdapter returns the TestAdapter registered by CreateTestServer.
+func (srv *Server) GetTestAdapter() *TestAdapter {
+	return srv.GetChatAdapter("test").(*TestAdapter)
+}
+
+// FlushMediaQueue waits for all the media being resolved and delivers them,
+// as the main loop would.
+func (srv *Server) FlushMediaQueue() {
+	for srv.MediaQueue.Len() > 0 {
+		srv.MediaQueue.Deliver(<-srv.MediaQueue.Ready)
+	}
+}
+
+// DispatchOutputQueue sends all the queued output messages to their chat
+// adapters, as the main loop would, and waits for the adapters sending them
+// in the background.
+func (srv *Server) DispatchOutputQueue() {
+	for _, msg := range srv.FlushOutputQueue() {
+		srv.SendToChatAdapter(msg)
+	}
+
+	for _, adapter := range srv.ChatAdapters {
+		if outbox, ok := adapter.(interface{ waitOutbox() }); ok {
+			outbox.waitOutbox()
+		}
+	}
+}
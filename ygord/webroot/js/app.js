@@ -19,10 +19,18 @@ ygorMinion.config(["$routeProvider",
                 templateUrl: "partials/channel-list.html",
                 controller: "ChannelListController"
             }).
+            when("/command/list", {
+                templateUrl: "partials/command-list.html",
+                controller: "CommandListController"
+            }).
             when("/client/list", {
                 templateUrl: "partials/client-list.html",
                 controller: "ClientListController"
             }).
+            when("/library/list", {
+                templateUrl: "partials/library-list.html",
+                controller: "LibraryListController"
+            }).
             when("/channel/:channelID", {
                 templateUrl: "partials/channel.html",
                 controller: "ChannelController"
@@ -12,11 +12,30 @@ ygorMinionControllers.controller("AliasListController", ["$scope", "$http",
     function($scope, $http) {
         $http.get('/alias/list').success(function(data) {
             $scope.aliases = data.aliases;
+            $scope.broken = data.broken;
+            $scope.checked = data.checked;
         });
         $scope.orderProp = "Name";
     }
 ]);
 
+ygorMinionControllers.controller("CommandListController", ["$scope", "$http",
+    function($scope, $http) {
+        $http.get('/command/list').success(function(data) {
+            $scope.commands = data.commands;
+        });
+    }
+]);
+
+ygorMinionControllers.controller("LibraryListController", ["$scope", "$http",
+    function($scope, $http) {
+        $http.get('/library/list').success(function(data) {
+            $scope.items = data.items;
+        });
+        $scope.orderProp = "name";
+    }
+]);
+
 ygorMinionControllers.controller("ClientListController", ["$scope", "$http",
     function($scope, $http) {
         $http.get('/client/list').success(function(data) {
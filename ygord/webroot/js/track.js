@@ -64,7 +64,7 @@ function modifyMediaElementPrototypes() {
                 this.duration != "Inf" &&
                 !this.didEnd){
             if (this.soloLoop){
-                this.currentTime = 0;
+                this.currentTime = this.startTime;
                 this.play();
             } else {
                 this.hide();
@@ -74,6 +74,10 @@ function modifyMediaElementPrototypes() {
         }
     };
     HTMLMediaElement.prototype.loadMedia = function() {
+        var s = this.media.start;
+        if (s && s.length > 0) {
+            this.startTime = parseFloat(s);
+        }
         var e = this.media.end;
         if (e.length > 0) {
             this.endTime = e;
@@ -96,12 +100,18 @@ function modifyMediaElementPrototypes() {
             this.show();
             this.didEnd = false;
         };
+        this.onloadedmetadata = function() {
+            if (this.startTime > 0) {
+                this.currentTime = this.startTime;
+            }
+        };
         this.ontimeupdate = function(event) {this.timeUpdated(event);};
         this.onerror = function(event) {this.hasErrored(event);};
         this.onended =  function() {this.hasEnded();};
         //this.onpause = function() {this.hasEnded();};
         this.media = media;
         this.soloLoop = media.loop;
+        this.startTime = 0;
         this.endTime = false;
         this.didEnd = false;
         this.setVolume(volume * trackVolume);
@@ -235,6 +245,11 @@ function modifyYouTubePlayerPrototype() {
             var params = {
                 "videoId": this.media.src
             }
+            var start = this.media.start;
+            if (start && start.length > 0) {
+                params.startSeconds = parseFloat(start);
+                this.startTime = params.startSeconds;
+            }
             var end = this.media.end;
             if (end.length > 0) {
                 params.endSeconds = parseFloat(end);
@@ -454,6 +469,11 @@ function modifyVimeoPlayerPrototype() {
         if (this.isReady) {
 
             // if the player is ready
+            var start = this.media.start;
+            if (start && start.length > 0) {
+                // vimeo requires a positive float
+                this.startTime = Math.max(parseFloat(start), 0.001);
+            }
             var end = this.media.end;
             if (end.length > 0) {
                 this.endTime = end;
@@ -669,6 +689,10 @@ function modifySoundCloudPlayerPrototype(widget) {
             this.destroy();
         }
     };
+    widget.seekToStart = function() {
+        // SoundCloud widget expects milliseconds
+        this.seekTo(this.startTime * 1000);
+    };
     widget.onPlayProgress = function(event) {
         this.currentTime = event.currentPosition / 1000;
         if (this.currentTime < this.startTime) {
@@ -698,6 +722,10 @@ function modifySoundCloudPlayerPrototype(widget) {
     widget.loadMedia = function() {
         if (this.isReady) {
             // if the player is ready
+            var start = this.media.start;
+            if (start && start.length > 0) {
+                this.startTime = parseFloat(start);
+            }
             var end = this.media.end;
             if (end.length > 0) {
                 this.endTime = end;